@@ -0,0 +1,112 @@
+// Package app wires the API together from its configuration: the database,
+// token keys, mailer, login providers, readiness checks and router. The
+// server and the end-to-end tests both build it here, so they run the same
+// wiring.
+package app
+
+import (
+	"context"
+	"ecommerce-api/config"
+	"ecommerce-api/migrations"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/routes"
+	"ecommerce-api/utils"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// App is a configured API, ready to serve
+type App struct {
+	Config *config.Config
+	DB     *gorm.DB
+	Store  repositories.Store
+	Tokens *utils.TokenService
+	Health *utils.HealthRegistry
+	Router *gin.Engine
+}
+
+// Options replace parts of the wiring
+type Options struct {
+	// Mailer replaces the mailer selected by the mail driver
+	Mailer utils.Mailer
+
+	// Migrate applies pending migrations even when auto-migration is off
+	Migrate bool
+}
+
+// New connects to the database and builds the API from cfg. The caller
+// closes the App once done.
+func New(ctx context.Context, cfg *config.Config, opts Options) (*App, error) {
+	db, err := models.ConnectDatabase(cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("initialize database: %w", err)
+	}
+	a := &App{Config: cfg, DB: db, Store: repositories.NewGormStore(db), Health: utils.NewHealthRegistry()}
+	if err := a.init(ctx, opts); err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// init loads the rest of the configuration once the database is open
+func (a *App) init(ctx context.Context, opts Options) error {
+	cfg := a.Config
+
+	// Load the JWT signing and verification keys
+	tokens, err := utils.LoadTokenService(cfg.JWT)
+	if err != nil {
+		return fmt.Errorf("initialize token service: %w", err)
+	}
+	a.Tokens = tokens
+
+	// Outgoing email
+	mailer := opts.Mailer
+	if mailer == nil {
+		if mailer, err = utils.NewMailer(cfg.Mail); err != nil {
+			return fmt.Errorf("initialize mailer: %w", err)
+		}
+	}
+
+	// Social login providers
+	providers, err := utils.LoadOIDCProviders(cfg.Auth.OIDCProvidersFile)
+	if err != nil {
+		return fmt.Errorf("load OIDC providers: %w", err)
+	}
+
+	// Apply pending migrations; instances starting together wait on a lock
+	migrator := migrations.New(a.DB)
+	migrator.Out = io.Discard
+	if cfg.Database.AutoMigrate || opts.Migrate {
+		n, err := migrator.Up(ctx, 0)
+		if err != nil {
+			return fmt.Errorf("migrate database: %w", err)
+		}
+		slog.Info("Database migrated", "applied", n)
+	}
+
+	// Readiness checks served at /readyz
+	a.Health.Register("database", models.CheckDatabase(a.DB))
+	a.Health.Register("migrations", migrator.Check)
+
+	// Set up the handlers, middlewares and routes
+	a.Router = routes.NewRouter(cfg.Server, routes.NewHandlers(routes.Dependencies{
+		Config:    cfg,
+		Store:     a.Store,
+		Tokens:    tokens,
+		Mailer:    mailer,
+		Providers: providers,
+		Health:    a.Health,
+	}))
+	return nil
+}
+
+// Close closes the database connection pool
+func (a *App) Close() error {
+	return models.Close(a.DB)
+}
@@ -0,0 +1,233 @@
+// Package catalog reads and writes product files in CSV and JSON, the
+// formats used to import and export the catalog.
+//
+// A CSV file starts with a header naming its columns: name, price and stock
+// are required, sku and description are optional. A JSON file is an array of
+// objects with the same keys.
+package catalog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Format is a product file format
+type Format string
+
+// Supported formats
+const (
+	CSV  Format = "csv"
+	JSON Format = "json"
+)
+
+// ParseFormat returns the format named by s
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case CSV, JSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported format %q, use csv or json", s)
+}
+
+// FormatOf guesses the format of a file from its extension, and returns ""
+// when the extension is not known
+func FormatOf(path string) Format {
+	f, err := ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return ""
+	}
+	return f
+}
+
+// MaxSKULength is the longest SKU the database stores
+const MaxSKULength = 64
+
+// Row is a product read from a file
+type Row struct {
+	// Line is the line of the row in a CSV file, counting the header, or the
+	// position of the object in a JSON array, counting from 1
+	Line int
+
+	SKU         string
+	Name        string
+	Description *string // nil when the file has no description
+	Price       float64
+	Stock       int
+}
+
+// RowError reports a row that could not be read. Reading can continue with
+// the next row.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads rows one at a time. Read returns io.EOF after the last row,
+// a *RowError for a row that is invalid, and any other error when the file
+// cannot be read further.
+type Reader interface {
+	Read() (Row, error)
+}
+
+// NewReader returns a reader of r in format
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case CSV:
+		return newCSVReader(r)
+	case JSON:
+		return newJSONReader(r)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// validate checks that a row describes a product. The values themselves are
+// checked by the product service.
+func (row Row) validate() error {
+	switch {
+	case strings.TrimSpace(row.Name) == "":
+		return errors.New("name is required")
+	case len(row.SKU) > MaxSKULength:
+		return fmt.Errorf("sku must not be longer than %d characters", MaxSKULength)
+	}
+	return nil
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, dup := columns[name]; dup {
+			return nil, fmt.Errorf("column %q appears twice", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"name", "price", "stock"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("column %q is missing", name)
+		}
+	}
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (r *csvReader) Read() (Row, error) {
+	record, err := r.r.Read()
+	if err != nil {
+		// Malformed quoting leaves the reader at an unknown position
+		return Row{}, err
+	}
+	line, _ := r.r.FieldPos(0)
+	row := Row{Line: line}
+
+	field := func(name string) string {
+		if i, ok := r.columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	row.SKU = field("sku")
+	row.Name = field("name")
+	if _, ok := r.columns["description"]; ok {
+		description := field("description")
+		row.Description = &description
+	}
+	// ParseFloat accepts NaN and Inf, which are no price
+	row.Price, err = strconv.ParseFloat(field("price"), 64)
+	if err != nil || math.IsNaN(row.Price) || math.IsInf(row.Price, 0) {
+		return row, &RowError{Line: line, Err: fmt.Errorf("price %q is not a number", field("price"))}
+	}
+	if row.Stock, err = strconv.Atoi(field("stock")); err != nil {
+		return row, &RowError{Line: line, Err: fmt.Errorf("stock %q is not a whole number", field("stock"))}
+	}
+	if err := row.validate(); err != nil {
+		return row, &RowError{Line: line, Err: err}
+	}
+	return row, nil
+}
+
+type jsonReader struct {
+	d    *json.Decoder
+	line int
+}
+
+// jsonRow is the JSON form of a row and of an exported product
+type jsonRow struct {
+	SKU         string  `json:"sku,omitempty"`
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+	Price       float64 `json:"price"`
+	Stock       int     `json:"stock"`
+}
+
+func newJSONReader(r io.Reader) (*jsonReader, error) {
+	d := json.NewDecoder(r)
+	token, err := d.Token()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if token != json.Delim('[') {
+		return nil, errors.New("the file must contain a JSON array of products")
+	}
+	return &jsonReader{d: d}, nil
+}
+
+func (r *jsonReader) Read() (Row, error) {
+	if !r.d.More() {
+		// Consume the closing bracket so truncated files are reported
+		if _, err := r.d.Token(); err != nil {
+			return Row{}, err
+		}
+		return Row{}, io.EOF
+	}
+	r.line++
+
+	var value jsonRow
+	err := r.d.Decode(&value)
+	row := Row{Line: r.line, SKU: strings.TrimSpace(value.SKU), Name: strings.TrimSpace(value.Name), Description: value.Description, Price: value.Price, Stock: value.Stock}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		// The decoder has skipped the whole object and can go on
+		return row, &RowError{Line: r.line, Err: fmt.Errorf("%s must not be a %s", typeErr.Field, typeErr.Value)}
+	}
+	if err != nil {
+		return row, err
+	}
+	if err := row.validate(); err != nil {
+		return row, &RowError{Line: r.line, Err: err}
+	}
+	return row, nil
+}
@@ -0,0 +1,89 @@
+package catalog
+
+import (
+	"bufio"
+	"ecommerce-api/models"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Writer writes products as they come, so that a catalog of any size can be
+// exported without holding it in memory. Close must be called after the last
+// product.
+type Writer struct {
+	format Format
+	csv    *csv.Writer
+	json   *bufio.Writer
+	count  int
+}
+
+// NewWriter returns a writer of products to w in format
+func NewWriter(w io.Writer, format Format) (*Writer, error) {
+	switch format {
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"sku", "name", "description", "price", "stock"}); err != nil {
+			return nil, err
+		}
+		return &Writer{format: format, csv: cw}, nil
+	case JSON:
+		return &Writer{format: format, json: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// Write writes a product
+func (w *Writer) Write(product *models.Product) error {
+	w.count++
+	if w.format == CSV {
+		return w.csv.Write([]string{
+			sku(product),
+			product.Name,
+			product.Description,
+			strconv.FormatFloat(product.Price, 'f', -1, 64),
+			strconv.Itoa(product.Stock),
+		})
+	}
+
+	data, err := json.Marshal(jsonRow{SKU: sku(product), Name: product.Name, Description: &product.Description, Price: product.Price, Stock: product.Stock})
+	if err != nil {
+		return err
+	}
+	separator := ",\n  "
+	if w.count == 1 {
+		separator = "[\n  "
+	}
+	w.json.WriteString(separator)
+	_, err = w.json.Write(data)
+	return err
+}
+
+// Count returns the number of products written
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Close finishes the file and flushes it
+func (w *Writer) Close() error {
+	if w.format == CSV {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	end := "\n]\n"
+	if w.count == 0 {
+		end = "[]\n"
+	}
+	w.json.WriteString(end)
+	return w.json.Flush()
+}
+
+// sku returns the SKU of product, or "" when it has none
+func sku(product *models.Product) string {
+	if product.SKU == nil {
+		return ""
+	}
+	return *product.SKU
+}
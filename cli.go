@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"ecommerce-api/config"
+	"ecommerce-api/migrations"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/utils"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"gorm.io/gorm"
+)
+
+const usage = `usage: ecommerce-api [command] [flags]
+
+commands:
+  serve                   run the HTTP server (the default)
+  migrate up|down|status  manage the database schema
+  seed                    create a demo catalog and demo users
+  user create-admin       create an administrator
+  user reset-password     set a new password for a user
+  products import|export  load or save the catalog as CSV or JSON
+  orders export           save the orders as CSV or JSON
+
+Every command reads the configuration of the server: the configuration file,
+the environment and the flags listed by "ecommerce-api <command> -h".
+`
+
+// loadConfig parses the arguments of a command, whose own flags are declared
+// on flagSet, together with the configuration flags. It returns the exit
+// code to stop with when the command must not run.
+func loadConfig(flagSet *flag.FlagSet, args []string) (*config.Config, int, bool) {
+	flagSet.SetOutput(os.Stderr)
+	cfg, err := config.LoadWith(flagSet, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil, 0, false
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, 2, false
+	}
+	if flagSet.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected argument %q\n", flagSet.Arg(0))
+		return nil, 2, false
+	}
+	return cfg, 0, true
+}
+
+// connect sets up logging and opens the database. The returned function
+// closes it.
+func connect(cfg *config.Config) (*gorm.DB, func(), error) {
+	if err := utils.InitLogger(cfg.Log); err != nil {
+		return nil, nil, fmt.Errorf("initialize logger: %w", err)
+	}
+	db, err := models.ConnectDatabase(cfg.Database)
+	if err != nil {
+		return nil, nil, fmt.Errorf("initialize database: %w", err)
+	}
+	return db, func() { models.Close(db) }, nil
+}
+
+// openStore opens the database for a command that reads or writes data. The
+// schema is migrated first when the server would migrate it, and otherwise
+// must be current.
+func openStore(ctx context.Context, cfg *config.Config) (repositories.Store, func(), error) {
+	db, closeDB, err := connect(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	migrator := migrations.New(db)
+	migrator.Out = io.Discard
+	if cfg.Database.AutoMigrate {
+		_, err = migrator.Up(ctx, 0)
+	} else {
+		err = migrator.Check(ctx)
+	}
+	if err != nil {
+		closeDB()
+		return nil, nil, fmt.Errorf("database schema: %w", err)
+	}
+	return repositories.NewGormStore(db), closeDB, nil
+}
+
+// commandContext is canceled by SIGINT or SIGTERM
+func commandContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
+
+// subcommand returns the command named by args[0] if it is one of names
+func subcommand(args []string, names ...string) (string, bool) {
+	if len(args) == 0 {
+		return "", false
+	}
+	for _, name := range names {
+		if args[0] == name {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// createFile opens path for writing, or returns stdout for "" and "-"
+func createFile(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+// openFile opens path for reading, or returns stdin for "" and "-"
+func openFile(path string) (io.ReadCloser, error) {
+	if path == "" || path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
@@ -1,24 +1,171 @@
+// Package config loads the typed application configuration. Values come from
+// defaults, an optional YAML or TOML file, environment variables and command
+// line flags, each overriding the previous one.
+//
+// Every setting is declared once on the structs below: `config` is its key in
+// the file (nested under its section), `env` the environment variable, from
+// which the flag name is derived (DB_HOST becomes --db-host), `validate` the
+// rules checked at startup and `secret` marks values that are never printed.
 package config
 
-import (
-	"fmt"
-	"os"
+import "time"
 
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
-)
+// Config is the complete application configuration
+type Config struct {
+	Server   ServerConfig   `config:"server"`
+	Database DatabaseConfig `config:"database"`
+	Log      LogConfig      `config:"log"`
+	Tracing  TracingConfig  `config:"tracing"`
+	Metrics  MetricsConfig  `config:"metrics"`
+	JWT      JWTConfig      `config:"jwt"`
+	Mail     MailConfig     `config:"mail"`
+	Auth     AuthConfig     `config:"auth"`
+}
 
-var DB *gorm.DB
+// ServerConfig configures the HTTP server and public URLs
+type ServerConfig struct {
+	Addr               string        `config:"addr" env:"HTTP_ADDR" validate:"required"`
+	ReadTimeout        time.Duration `config:"read_timeout" env:"HTTP_READ_TIMEOUT" validate:"gt=0"`
+	ReadHeaderTimeout  time.Duration `config:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" validate:"gt=0"`
+	WriteTimeout       time.Duration `config:"write_timeout" env:"HTTP_WRITE_TIMEOUT" validate:"gt=0"`
+	IdleTimeout        time.Duration `config:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" validate:"gt=0"`
+	MaxHeaderBytes     int           `config:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES" validate:"gt=0"`
+	TLSCertFile        string        `config:"tls_cert_file" env:"TLS_CERT_FILE" validate:"required_with=TLSKeyFile"`
+	TLSKeyFile         string        `config:"tls_key_file" env:"TLS_KEY_FILE" validate:"required_with=TLSCertFile"`
+	ShutdownDrainDelay time.Duration `config:"shutdown_drain_delay" env:"SHUTDOWN_DRAIN_DELAY" validate:"gte=0"`
+	ShutdownTimeout    time.Duration `config:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" validate:"gt=0"`
+	HealthCheckTimeout time.Duration `config:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT" validate:"gt=0"`
 
-func ConnectDB() {
-	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_NAME"),
-	)
-	database, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		panic("Failed to connect to database")
-	}
+	// BaseURL is where the API is reachable, used in links sent by email
+	BaseURL            string `config:"base_url" env:"APP_BASE_URL" validate:"required,url"`
+	ProblemTypeBaseURL string `config:"problem_type_base_url" env:"PROBLEM_TYPE_BASE_URL" validate:"omitempty,url"`
+}
+
+// DatabaseConfig selects and configures the database. SQLite only needs a
+// path; ":memory:" gives a private in-memory database, e.g. for tests.
+type DatabaseConfig struct {
+	Driver string `config:"driver" env:"DB_DRIVER" validate:"oneof=mysql postgres sqlite"`
+
+	// Server connection, for mysql and postgres. Port defaults to the
+	// driver's standard port; SSLMode only applies to postgres.
+	Host     string `config:"host" env:"DB_HOST" validate:"required_unless=Driver sqlite"`
+	Port     int    `config:"port" env:"DB_PORT" validate:"omitempty,min=1,max=65535"`
+	User     string `config:"user" env:"DB_USER" validate:"required_unless=Driver sqlite"`
+	Password string `config:"password" env:"DB_PASSWORD" secret:"true"`
+	Name     string `config:"name" env:"DB_NAME" validate:"required_unless=Driver sqlite"`
+	SSLMode  string `config:"sslmode" env:"DB_SSLMODE" validate:"omitempty,oneof=disable allow prefer require verify-ca verify-full"`
+
+	// Path is the SQLite database file
+	Path string `config:"path" env:"DB_PATH" validate:"required_if=Driver sqlite"`
 
-	DB = database
+	SlowQueryThreshold time.Duration `config:"slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD" validate:"gt=0"`
+
+	// AutoMigrate applies pending migrations when the server starts. When
+	// disabled, run "ecommerce-api migrate up" before deploying.
+	AutoMigrate bool `config:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+}
+
+// LogConfig configures the application log
+type LogConfig struct {
+	Level  string `config:"level" env:"LOG_LEVEL" validate:"oneof=debug info warn error"`
+	Format string `config:"format" env:"LOG_FORMAT" validate:"oneof=json text"`
+}
+
+// TracingConfig selects the trace exporter. The otlp exporter is configured
+// through the standard OTEL_EXPORTER_OTLP_* variables.
+type TracingConfig struct {
+	Exporter    string  `config:"exporter" env:"TRACING_EXPORTER" validate:"oneof=none stdout file otlp"`
+	File        string  `config:"file" env:"TRACING_FILE" validate:"required_if=Exporter file"`
+	SampleRatio float64 `config:"sample_ratio" env:"TRACING_SAMPLE_RATIO" validate:"gte=0,lte=1"`
+}
+
+// MetricsConfig configures the Prometheus endpoint
+type MetricsConfig struct {
+	// Addr serves metrics on a separate listener instead of the API port
+	Addr  string `config:"addr" env:"METRICS_ADDR"`
+	Token string `config:"token" env:"METRICS_TOKEN" secret:"true"`
+}
+
+// JWTConfig configures the keys that sign and verify tokens
+type JWTConfig struct {
+	Issuer         string `config:"issuer" env:"JWT_ISSUER" validate:"required"`
+	Audience       string `config:"audience" env:"JWT_AUDIENCE" validate:"required"`
+	Secret         string `config:"secret" env:"JWT_SECRET" validate:"required_without=PrivateKeyFile" secret:"true"`
+	PrivateKeyFile string `config:"private_key_file" env:"JWT_PRIVATE_KEY_FILE"`
+	KeyID          string `config:"key_id" env:"JWT_KEY_ID"`
+
+	// VerificationKeys maps a kid to a key file that is still accepted. The
+	// environment variable takes "kid=path,kid=path".
+	VerificationKeys map[string]string `config:"verification_keys" env:"JWT_VERIFICATION_KEYS"`
+}
+
+// MailConfig selects how emails are delivered
+type MailConfig struct {
+	Driver       string `config:"driver" env:"MAIL_DRIVER" validate:"oneof=log smtp"`
+	From         string `config:"from" env:"MAIL_FROM" validate:"required"`
+	SMTPHost     string `config:"smtp_host" env:"SMTP_HOST" validate:"required_if=Driver smtp"`
+	SMTPPort     int    `config:"smtp_port" env:"SMTP_PORT" validate:"min=1,max=65535"`
+	SMTPUsername string `config:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `config:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// AuthConfig holds the account and login policies
+type AuthConfig struct {
+	RequireVerifiedEmailForOrders bool          `config:"require_verified_email_for_orders" env:"REQUIRE_VERIFIED_EMAIL_FOR_ORDERS"`
+	RequireAdminMFA               bool          `config:"require_admin_mfa" env:"REQUIRE_ADMIN_MFA"`
+	OIDCProvidersFile             string        `config:"oidc_providers_file" env:"OIDC_PROVIDERS_FILE"`
+	LoginMaxFailures              int           `config:"login_max_failures" env:"LOGIN_MAX_FAILURES" validate:"gt=0"`
+	LoginLockoutBase              time.Duration `config:"login_lockout_base" env:"LOGIN_LOCKOUT_BASE" validate:"gt=0"`
+	LoginLockoutMax               time.Duration `config:"login_lockout_max" env:"LOGIN_LOCKOUT_MAX" validate:"gtefield=LoginLockoutBase"`
+	LoginIPMaxFailures            int           `config:"login_ip_max_failures" env:"LOGIN_IP_MAX_FAILURES" validate:"gt=0"`
+	LoginIPWindow                 time.Duration `config:"login_ip_window" env:"LOGIN_IP_WINDOW" validate:"gt=0"`
+}
+
+// Defaults returns the configuration used for settings that are not set
+func Defaults() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:               ":8080",
+			ReadTimeout:        15 * time.Second,
+			ReadHeaderTimeout:  5 * time.Second,
+			WriteTimeout:       30 * time.Second,
+			IdleTimeout:        2 * time.Minute,
+			MaxHeaderBytes:     1 << 20,
+			ShutdownDrainDelay: 5 * time.Second,
+			ShutdownTimeout:    20 * time.Second,
+			HealthCheckTimeout: 2 * time.Second,
+			BaseURL:            "http://localhost:8080",
+		},
+		Database: DatabaseConfig{
+			Driver:             "mysql",
+			Host:               "localhost",
+			SSLMode:            "prefer",
+			Path:               "ecommerce.db",
+			SlowQueryThreshold: 200 * time.Millisecond,
+			AutoMigrate:        true,
+		},
+		Log: LogConfig{Level: "info", Format: "json"},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			File:        "traces.json",
+			SampleRatio: 1,
+		},
+		JWT: JWTConfig{
+			Issuer:           "ecommerce-api",
+			Audience:         "ecommerce-api",
+			VerificationKeys: map[string]string{},
+		},
+		Mail: MailConfig{
+			Driver:   "log",
+			From:     "no-reply@localhost",
+			SMTPPort: 587,
+		},
+		Auth: AuthConfig{
+			LoginMaxFailures:   5,
+			LoginLockoutBase:   time.Minute,
+			LoginLockoutMax:    time.Hour,
+			LoginIPMaxFailures: 20,
+			LoginIPWindow:      15 * time.Minute,
+		},
+	}
 }
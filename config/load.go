@@ -0,0 +1,259 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the configuration file when --config is not given
+const FileEnv = "CONFIG_FILE"
+
+// field is one setting of the configuration
+type field struct {
+	path   string // key in the file, e.g. "database.host"
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// flagName derives the command line flag of a setting from its variable
+func (f field) flagName() string {
+	return strings.ToLower(strings.ReplaceAll(f.env, "_", "-"))
+}
+
+// fields lists the settings of cfg in declaration order
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			path := sf.Tag.Get("config")
+			if prefix != "" {
+				path = prefix + "." + path
+			}
+			if sf.Type.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(v.Field(i), path)
+				continue
+			}
+			out = append(out, field{
+				path:   path,
+				env:    sf.Tag.Get("env"),
+				secret: sf.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return out
+}
+
+// Load builds the configuration from, in increasing precedence, the defaults,
+// the file named by --config or CONFIG_FILE, the environment (including a .env
+// file in the working directory, if any) and the flags in args, then validates
+// it. flag.ErrHelp is returned when args ask for usage.
+func Load(args []string, output io.Writer) (*Config, error) {
+	flagSet := flag.NewFlagSet("ecommerce-api", flag.ContinueOnError)
+	flagSet.SetOutput(output)
+	return LoadWith(flagSet, args)
+}
+
+// LoadWith is Load with a caller's flag set, so that commands can declare
+// their own flags next to the configuration flags
+func LoadWith(flagSet *flag.FlagSet, args []string) (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("load .env: %w", err)
+	}
+
+	cfg := Defaults()
+	settings := fields(cfg)
+
+	// Flags are parsed first to find the file, and applied last
+	file := flagSet.String("config", os.Getenv(FileEnv), "YAML or TOML configuration `file`")
+	flagValues := map[string]string{}
+	for _, f := range settings {
+		f := f
+		usage := fmt.Sprintf("%s (env %s)", f.path, f.env)
+		set := func(raw string) error {
+			flagValues[f.path] = raw
+			return nil
+		}
+		if f.value.Kind() == reflect.Bool {
+			flagSet.BoolFunc(f.flagName(), usage, set)
+		} else {
+			flagSet.Func(f.flagName(), usage, set)
+		}
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *file != "" {
+		if err := loadFile(cfg, *file); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range settings {
+		if raw, ok := os.LookupEnv(f.env); ok && raw != "" {
+			if err := setValue(f.value, raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.env, err)
+			}
+		}
+	}
+	for _, f := range settings {
+		if raw, ok := flagValues[f.path]; ok {
+			if err := setValue(f.value, raw); err != nil {
+				return nil, fmt.Errorf("--%s: %w", f.flagName(), err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile applies a YAML (.yaml, .yml) or TOML (.toml) file. Unknown keys
+// are rejected so that typos do not go unnoticed.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var doc map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return fmt.Errorf("config file %s: unsupported format %q, use .yaml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	byPath := map[string]field{}
+	for _, f := range fields(cfg) {
+		byPath[f.path] = f
+	}
+	return applyDocument(byPath, doc, "", path)
+}
+
+func applyDocument(byPath map[string]field, doc map[string]interface{}, prefix, file string) error {
+	for key, raw := range doc {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		f, ok := byPath[path]
+		if !ok {
+			section, isSection := raw.(map[string]interface{})
+			if !isSection || prefix != "" || !hasSection(byPath, path) {
+				return fmt.Errorf("config file %s: unknown setting %q", file, path)
+			}
+			if err := applyDocument(byPath, section, path, file); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if f.value.Kind() == reflect.Map {
+			entries, isMap := raw.(map[string]interface{})
+			if !isMap {
+				return fmt.Errorf("config file %s: %s must be a table of strings", file, path)
+			}
+			values := reflect.MakeMap(f.value.Type())
+			for k, v := range entries {
+				values.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(fmt.Sprint(v)))
+			}
+			f.value.Set(values)
+			continue
+		}
+		if err := setValue(f.value, fmt.Sprint(raw)); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", file, path, err)
+		}
+	}
+	return nil
+}
+
+// hasSection reports whether any setting lives under name
+func hasSection(byPath map[string]field, name string) bool {
+	for path := range byPath {
+		if strings.HasPrefix(path, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// setValue parses raw into v according to its type
+func setValue(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(n)
+	case reflect.Map:
+		// kid=path,kid=path
+		values := reflect.MakeMap(v.Type())
+		for _, entry := range strings.Split(raw, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok {
+				if entry = strings.TrimSpace(entry); entry != "" {
+					return fmt.Errorf("invalid entry %q, expected key=value", entry)
+				}
+				continue
+			}
+			values.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+		}
+		v.Set(values)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
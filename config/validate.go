@@ -0,0 +1,149 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Redacted replaces the value of secret settings when printed
+const Redacted = "********"
+
+// ValidationError lists every invalid setting
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate checks every setting and reports all problems at once, naming each
+// setting by its file key and environment variable
+func (c *Config) Validate() error {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(sf reflect.StructField) string {
+		return sf.Tag.Get("config")
+	})
+
+	err := validate.Struct(c)
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+
+	byPath := map[string]field{}
+	for _, f := range fields(c) {
+		byPath[f.path] = f
+	}
+	problems := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		// Namespace is "Config.section.key"; the first segment is the type
+		_, path, _ := strings.Cut(fe.Namespace(), ".")
+		section, _, _ := strings.Cut(path, ".")
+		problems = append(problems, fmt.Sprintf("%s (%s): %s", path, byPath[path].env, problem(fe, section, c)))
+	}
+	return &ValidationError{Problems: problems}
+}
+
+// problem describes a failed rule in words
+func problem(fe validator.FieldError, section string, c *Config) string {
+	param := fe.Param()
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_if":
+		other, value, _ := strings.Cut(param, " ")
+		return fmt.Sprintf("is required when %s is %q", siblingPath(c, section, other), value)
+	case "required_unless":
+		other, value, _ := strings.Cut(param, " ")
+		return fmt.Sprintf("is required unless %s is %q", siblingPath(c, section, other), value)
+	case "required_with":
+		return fmt.Sprintf("is required when %s is set", siblingPath(c, section, param))
+	case "required_without":
+		return fmt.Sprintf("is required unless %s is set", siblingPath(c, section, param))
+	case "gtefield":
+		return fmt.Sprintf("must not be less than %s", siblingPath(c, section, param))
+	case "oneof":
+		return fmt.Sprintf("must be one of %s, got %q", strings.ReplaceAll(param, " ", ", "), fmt.Sprint(fe.Value()))
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", param)
+	case "gte", "min":
+		return fmt.Sprintf("must be at least %s", param)
+	case "lte", "max":
+		return fmt.Sprintf("must be at most %s", param)
+	case "url":
+		return "must be an absolute URL"
+	}
+	return fmt.Sprintf("failed the %q rule", fe.Tag())
+}
+
+// siblingPath turns the Go name of a field in section into its file key
+func siblingPath(c *Config, section, name string) string {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("config") != section {
+			continue
+		}
+		if sf, ok := v.Field(i).Type().FieldByName(name); ok {
+			return section + "." + sf.Tag.Get("config")
+		}
+	}
+	return name
+}
+
+// display formats a setting for printing, hiding secrets that are set
+func (f field) display() string {
+	if f.secret && !f.value.IsZero() {
+		return Redacted
+	}
+	if f.value.Kind() == reflect.Map {
+		keys := make([]string, 0, f.value.Len())
+		for _, k := range f.value.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			entries[i] = k + "=" + f.value.MapIndex(reflect.ValueOf(k)).String()
+		}
+		return strings.Join(entries, ",")
+	}
+	return fmt.Sprint(f.value.Interface())
+}
+
+// String lists every setting, one "key = value" per line, with secrets redacted
+func (c *Config) String() string {
+	var b strings.Builder
+	for _, f := range fields(c) {
+		fmt.Fprintf(&b, "%s = %s\n", f.path, f.display())
+	}
+	return b.String()
+}
+
+// LogValue logs the configuration grouped by section, with secrets redacted
+func (c *Config) LogValue() slog.Value {
+	var sections []slog.Attr
+	var current string
+	var attrs []slog.Attr
+	flush := func() {
+		if current != "" {
+			sections = append(sections, slog.Attr{Key: current, Value: slog.GroupValue(attrs...)})
+		}
+	}
+	for _, f := range fields(c) {
+		section, key, _ := strings.Cut(f.path, ".")
+		if section != current {
+			flush()
+			current, attrs = section, nil
+		}
+		attrs = append(attrs, slog.String(key, f.display()))
+	}
+	flush()
+	return slog.GroupValue(sections...)
+}
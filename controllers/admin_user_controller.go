@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnlockUser clears the login lockout of an account (admin only)
+// @Summary Unlock a user account
+// @Description Reset the failed login counter and lift a temporary lockout
+// @Tags Admin
+// @Param id path string true "User ID"
+// @Failure 404 {object} utils.ErrorResponse "User not found"
+// @Failure 500 {object} utils.ErrorResponse "Failed to unlock user"
+// @Security BearerAuth
+// @Router /admin/users/{id}/unlock [post]
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	userID, ok := paramID(c, "id")
+	if !ok {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeUserNotFound, "User not found")
+		return
+	}
+
+	err := h.users.Unlock(c.Request.Context(), actor(c), userID)
+	if errors.Is(err, services.ErrUserNotFound) {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeUserNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to unlock user")
+		return
+	}
+
+	utils.RespondSuccess(c, "User unlocked successfully", nil)
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler serves the API key administration endpoints
+type APIKeyHandler struct {
+	keys *services.APIKeyService
+}
+
+// NewAPIKeyHandler returns the API key handlers for an API key service
+func NewAPIKeyHandler(keys *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys}
+}
+
+// CreateAPIKey creates an API key (admin only)
+// @Summary Create an API key
+// @Description Create an API key for a server-to-server integration. The key is only shown in this response.
+// @Description Scopes: products:read, products:write, orders:read, orders:write, orders:manage. The key acts as user_id (default: the calling admin).
+// @Tags API Keys
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 500 {object} utils.ErrorResponse "Failed to create API key"
+// @Security BearerAuth
+// @Router /admin/api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var input struct {
+		Name      string     `json:"name" binding:"required,max=100"`
+		Scopes    []string   `json:"scopes" binding:"required,min=1"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		UserID    uint       `json:"user_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	key, apiKey, err := h.keys.Create(c.Request.Context(), actor(c), services.NewAPIKey{
+		Name:      input.Name,
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+		UserID:    input.UserID,
+	})
+	var scopeErr *services.ScopeError
+	switch {
+	case errors.As(err, &scopeErr):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeScopeUnknown, "Unknown scope: "+scopeErr.Scope)
+		return
+	case errors.Is(err, services.ErrExpiryInPast):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeBadRequest, "Expiry must be in the future")
+		return
+	case errors.Is(err, services.ErrUserNotFound):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeUserNotFound, "User not found")
+		return
+	case err != nil:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to create API key")
+		return
+	}
+
+	utils.RespondSuccess(c, "API key created successfully, store it now as it cannot be shown again", gin.H{
+		"key":     key,
+		"api_key": apiKey,
+	})
+}
+
+// GetAPIKeys lists API keys (admin only)
+// @Summary List API keys
+// @Description List API keys with their prefix, scopes, expiry and last use. Secrets are never returned.
+// @Tags API Keys
+// @Produce  json
+// @Failure 500 {object} utils.ErrorResponse "Failed to fetch API keys"
+// @Security BearerAuth
+// @Router /admin/api-keys [get]
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	keys, err := h.keys.List(c.Request.Context())
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to fetch API keys")
+		return
+	}
+
+	utils.RespondSuccess(c, "API keys fetched successfully", keys)
+}
+
+// RevokeAPIKey revokes an API key (admin only)
+// @Summary Revoke an API key
+// @Description Revoke an API key; it is rejected immediately
+// @Tags API Keys
+// @Param id path string true "API key ID"
+// @Failure 404 {object} utils.ErrorResponse "API key not found"
+// @Failure 500 {object} utils.ErrorResponse "Failed to revoke API key"
+// @Security BearerAuth
+// @Router /admin/api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeAPIKeyNotFound, "API key not found")
+		return
+	}
+
+	apiKey, err := h.keys.Revoke(c.Request.Context(), actor(c), id)
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeAPIKeyNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to revoke API key")
+		return
+	}
+
+	utils.RespondSuccess(c, "API key revoked successfully", apiKey)
+}
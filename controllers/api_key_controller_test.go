@@ -0,0 +1,160 @@
+package controllers_test
+
+import (
+	"ecommerce-api/models"
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	post := func(token string, body gin.H) testutil.Request {
+		return testutil.Request{Method: http.MethodPost, Path: "/api/v1/admin/api-keys", Token: token, Body: body}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "returns a key that authenticates",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Admin()), gin.H{"name": "shop", "scopes": []string{models.ScopeOrdersRead}})
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var created struct {
+					Key string `json:"key"`
+				}
+				res.Data(&created)
+				env.Do(testutil.Request{Method: http.MethodGet, Path: "/api/v1/orders", APIKey: created.Key}).AssertStatus(http.StatusOK)
+			},
+		},
+		{
+			Name: "acts as another user",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Admin()), gin.H{"name": "shop", "scopes": []string{models.ScopeOrdersRead}, "user_id": env.Customer().ID})
+			},
+			Status: http.StatusOK,
+		},
+		{
+			Name: "rejects an unknown scope",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Admin()), gin.H{"name": "shop", "scopes": []string{"orders:delete"}})
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeScopeUnknown,
+		},
+		{
+			Name: "rejects an expiry in the past",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Admin()), gin.H{"name": "shop", "scopes": []string{models.ScopeOrdersRead}, "expires_at": time.Now().Add(-time.Hour)})
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeBadRequest,
+		},
+		{
+			Name: "rejects an unknown user",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Admin()), gin.H{"name": "shop", "scopes": []string{models.ScopeOrdersRead}, "user_id": 999})
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeUserNotFound,
+		},
+		{
+			Name: "requires scopes",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Admin()), gin.H{"name": "shop"})
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeValidationFailed,
+		},
+		{
+			Name: "is not available to API keys",
+			Setup: func(env *testutil.Env) testutil.Request {
+				req := post("", gin.H{"name": "shop", "scopes": []string{models.ScopeOrdersRead}})
+				req.APIKey = env.APIKey(env.Admin(), models.ScopeOrdersManage)
+				return req
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAdminRequired,
+		},
+		{
+			Name: "is for admins only",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Customer()), gin.H{"name": "shop", "scopes": []string{models.ScopeOrdersRead}})
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAdminRequired,
+		},
+	})
+}
+
+func TestGetAPIKeys(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "lists the keys without their secrets",
+			Setup: func(env *testutil.Env) testutil.Request {
+				admin := env.Admin()
+				env.APIKey(admin, models.ScopeOrdersRead)
+				return testutil.Request{Method: http.MethodGet, Path: "/api/v1/admin/api-keys", Token: env.Token(admin)}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var keys []map[string]interface{}
+				res.Data(&keys)
+				if len(keys) != 1 {
+					t.Fatalf("%d keys, want 1", len(keys))
+				}
+				for name := range keys[0] {
+					if name == "KeyHash" || name == "key_hash" {
+						t.Errorf("key exposes %s", name)
+					}
+				}
+			},
+		},
+	})
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "stops the key from authenticating",
+			Setup: func(env *testutil.Env) testutil.Request {
+				admin := env.Admin()
+				env.APIKey(admin, models.ScopeOrdersRead)
+				var key models.APIKey
+				env.DB.First(&key)
+				return testutil.Request{Method: http.MethodDelete, Path: fmt.Sprintf("/api/v1/admin/api-keys/%d", key.ID), Token: env.Token(admin)}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var key models.APIKey
+				env.DB.First(&key)
+				if key.RevokedAt == nil {
+					t.Error("key is not revoked")
+				}
+			},
+		},
+		{
+			Name: "rejects an unknown key",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodDelete, Path: "/api/v1/admin/api-keys/999", Token: env.Token(env.Admin())}
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeAPIKeyNotFound,
+		},
+	})
+
+	t.Run("rejects requests with a revoked key", func(t *testing.T) {
+		env := testutil.New(t)
+		admin := env.Admin()
+		apiKey := env.APIKey(admin, models.ScopeOrdersRead)
+		var key models.APIKey
+		env.DB.First(&key)
+		env.DELETE(fmt.Sprintf("/api/v1/admin/api-keys/%d", key.ID), env.Token(admin)).AssertStatus(http.StatusOK)
+		env.Do(testutil.Request{Method: http.MethodGet, Path: "/api/v1/orders", APIKey: apiKey}).AssertStatus(http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"ecommerce-api/repositories"
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditLogHandler serves the audit log endpoint
+type AuditLogHandler struct {
+	logs *services.AuditLogService
+}
+
+// NewAuditLogHandler returns the audit log handlers for an audit log service
+func NewAuditLogHandler(logs *services.AuditLogService) *AuditLogHandler {
+	return &AuditLogHandler{logs: logs}
+}
+
+// GetAuditLogs lists audit log entries (admin only)
+// @Summary List audit log entries
+// @Description List administrative changes, newest first. Filters: actor_id, action, target_type, target_id, since, until (RFC 3339).
+// @Tags Admin
+// @Produce  json
+// @Param actor_id query string false "Actor user ID"
+// @Param action query string false "Action, e.g. product.update"
+// @Param target_type query string false "Target type, e.g. product"
+// @Param target_id query string false "Target ID"
+// @Param since query string false "Earliest time (RFC 3339)"
+// @Param until query string false "Latest time (RFC 3339)"
+// @Param limit query int false "Maximum entries (default 100, max 500)"
+// @Param offset query int false "Entries to skip"
+// @Failure 400 {object} utils.ErrorResponse "Invalid filter"
+// @Failure 500 {object} utils.ErrorResponse "Failed to fetch audit logs"
+// @Security BearerAuth
+// @Router /admin/audit-logs [get]
+func (h *AuditLogHandler) GetAuditLogs(c *gin.Context) {
+	filter := repositories.AuditLogFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+	}
+
+	for param, field := range map[string]*uint{"actor_id": &filter.ActorID, "target_id": &filter.TargetID} {
+		if value := c.Query(param); value != "" {
+			id, err := strconv.ParseUint(value, 10, 0)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, utils.CodeBadRequest, "Invalid "+param)
+				return
+			}
+			*field = uint(id)
+		}
+	}
+	for param, field := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, utils.CodeBadRequest, "Invalid "+param+" time, use RFC 3339")
+				return
+			}
+			*field = t
+		}
+	}
+
+	var err error
+	filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || filter.Limit < 1 || filter.Limit > 500 {
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeBadRequest, "Invalid limit, use 1 to 500")
+		return
+	}
+	filter.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || filter.Offset < 0 {
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeBadRequest, "Invalid offset")
+		return
+	}
+
+	logs, err := h.logs.List(c.Request.Context(), filter)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to fetch audit logs")
+		return
+	}
+
+	utils.RespondSuccess(c, "Audit logs fetched successfully", logs)
+}
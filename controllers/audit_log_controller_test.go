@@ -0,0 +1,58 @@
+package controllers_test
+
+import (
+	"ecommerce-api/models"
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"net/http"
+	"testing"
+)
+
+func TestGetAuditLogs(t *testing.T) {
+	get := func(token, query string) testutil.Request {
+		return testutil.Request{Method: http.MethodGet, Path: "/api/v1/admin/audit-logs" + query, Token: token}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "lists the entries of an action",
+			Setup: func(env *testutil.Env) testutil.Request {
+				token := env.Token(env.Admin())
+				env.POST("/api/v1/products", token, map[string]interface{}{"name": "Mug", "price": 4.5, "stock": 2}).AssertStatus(http.StatusOK)
+				return get(token, "?action=product.create")
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var entries []models.AuditLog
+				res.Data(&entries)
+				if len(entries) != 1 || entries[0].Action != "product.create" {
+					t.Errorf("entries = %+v, want one product.create", entries)
+				}
+			},
+		},
+		{
+			Name: "rejects an invalid limit",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return get(env.Token(env.Admin()), "?limit=1000")
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeBadRequest,
+		},
+		{
+			Name: "rejects an invalid time",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return get(env.Token(env.Admin()), "?since=yesterday")
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeBadRequest,
+		},
+		{
+			Name: "is for admins only",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return get(env.Token(env.Customer()), "")
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAdminRequired,
+		},
+	})
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthHandler serves the login, token and account recovery endpoints
+type AuthHandler struct {
+	auth     *services.AuthService
+	sessions *services.SessionService
+	users    *services.UserService
+
+	// secureCookies marks cookies Secure when the API is served over HTTPS
+	secureCookies bool
+}
+
+// NewAuthHandler returns the auth handlers for the auth, session and user
+// services of the API served at baseURL
+func NewAuthHandler(auth *services.AuthService, sessions *services.SessionService, users *services.UserService, baseURL string) *AuthHandler {
+	return &AuthHandler{auth: auth, sessions: sessions, users: users, secureCookies: strings.HasPrefix(baseURL, "https://")}
+}
+
+// respondLogin sends the tokens of a completed login, or the challenge to
+// complete at /auth/login/mfa for accounts with two-factor authentication
+func respondLogin(c *gin.Context, result *services.LoginResult) {
+	if result.Challenge != "" {
+		utils.RespondSuccess(c, "Two-factor authentication required", gin.H{
+			"mfa_required": true,
+			"mfa_token":    result.Challenge,
+			"expires_in":   int(services.MFAChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	utils.RespondSuccess(c, "Login successful", result.Tokens)
+}
+
+// respondRetry answers a login refused by the brute-force protection and
+// reports whether err was such a refusal
+func respondRetry(c *gin.Context, err error) bool {
+	var retry *services.RetryError
+	if !errors.As(err, &retry) {
+		return false
+	}
+	if errors.Is(err, services.ErrAccountLocked) {
+		respondRetryLater(c, retry.Wait, utils.CodeAccountLocked, "Account temporarily locked after repeated failed logins")
+	} else {
+		respondRetryLater(c, retry.Wait, utils.CodeTooManyRequests, "Too many failed login attempts, please try again later")
+	}
+	return true
+}
+
+// respondRetryLater sends 429 with a Retry-After header
+func respondRetryLater(c *gin.Context, wait time.Duration, code utils.ErrorCode, message string) {
+	c.Header("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+	utils.RespondError(c, http.StatusTooManyRequests, code, message)
+}
+
+// Login authenticates a user and generates a JWT
+// @Summary Login and get JWT token
+// @Description Authenticate a user with email and password, then generate a short-lived JWT and a refresh token.
+// @Description Accounts with two-factor authentication receive an mfa_token to complete at /auth/login/mfa instead.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Invalid credentials"
+// @Failure 429 {object} utils.ErrorResponse "Too many failed attempts or account locked"
+// @Failure 500 {object} utils.ErrorResponse "Failed to generate token"
+// @Router /login [post]
+func (h *AuthHandler) Login(c *gin.Context) {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	result, err := h.auth.Login(c.Request.Context(), input.Email, input.Password, client(c))
+	if respondRetry(c, err) {
+		return
+	}
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeInvalidCredentials, "Invalid credentials")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to generate token")
+		return
+	}
+
+	respondLogin(c, result)
+}
+
+// RefreshToken exchanges a refresh token for a new access token
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token. The refresh token is rotated; presenting a used token revokes the whole session.
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Invalid or expired refresh token"
+// @Failure 500 {object} utils.ErrorResponse "Failed to refresh token"
+// @Router /auth/refresh [post]
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	tokens, err := h.sessions.Refresh(c.Request.Context(), input.RefreshToken)
+	switch {
+	case errors.Is(err, services.ErrRefreshTokenReused):
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeRefreshTokenReused, "Refresh token reuse detected, session revoked")
+		return
+	case errors.Is(err, services.ErrRefreshTokenInvalid):
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeRefreshTokenInvalid, "Invalid or expired refresh token")
+		return
+	case err != nil:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to refresh token")
+		return
+	}
+
+	utils.RespondSuccess(c, "Token refreshed successfully", tokens)
+}
+
+// Logout revokes the session of the current access token
+// @Summary Logout
+// @Description Revoke the current session, or every session of the user when all_sessions is true
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized user"
+// @Failure 500 {object} utils.ErrorResponse "Failed to logout"
+// @Security BearerAuth
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var input struct {
+		AllSessions bool `json:"all_sessions"`
+	}
+	// The body is optional
+	_ = c.ShouldBindJSON(&input)
+
+	userID, sessionID := c.GetUint("user_id"), c.GetUint("session_id")
+	if userID == 0 || sessionID == 0 {
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized user not found")
+		return
+	}
+
+	var err error
+	if input.AllSessions {
+		err = h.sessions.RevokeAll(c.Request.Context(), userID)
+	} else {
+		err = h.sessions.Revoke(c.Request.Context(), userID, sessionID)
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to logout")
+		return
+	}
+
+	utils.RespondSuccess(c, "Logged out successfully", nil)
+}
@@ -0,0 +1,185 @@
+package controllers_test
+
+import (
+	"ecommerce-api/models"
+	"ecommerce-api/services"
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// login signs user in with the default password and returns the tokens
+func login(t *testing.T, env *testutil.Env, user *models.User) services.Tokens {
+	t.Helper()
+	var tokens services.Tokens
+	env.POST("/api/v1/login", "", gin.H{"email": user.Email, "password": testutil.DefaultPassword}).
+		AssertStatus(http.StatusOK).Data(&tokens)
+	return tokens
+}
+
+func TestLogin(t *testing.T) {
+	post := func(email, password string) testutil.Request {
+		return testutil.Request{Method: http.MethodPost, Path: "/api/v1/login", Body: gin.H{"email": email, "password": password}}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "returns a token pair",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Customer().Email, testutil.DefaultPassword)
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var tokens services.Tokens
+				res.Data(&tokens)
+				if tokens.Token == "" || tokens.RefreshToken == "" || tokens.TokenType != "Bearer" {
+					t.Fatalf("tokens = %+v", tokens)
+				}
+				env.GET("/api/v1/me", tokens.Token).AssertStatus(http.StatusOK)
+			},
+		},
+		{
+			Name: "asks for the second factor of two-factor accounts",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				enableMFA(t, env, user)
+				return post(user.Email, testutil.DefaultPassword)
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var challenge struct {
+					MFARequired bool   `json:"mfa_required"`
+					MFAToken    string `json:"mfa_token"`
+				}
+				res.Data(&challenge)
+				if !challenge.MFARequired || challenge.MFAToken == "" {
+					t.Errorf("challenge = %+v", challenge)
+				}
+			},
+		},
+		{
+			Name: "rejects a wrong password",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Customer().Email, "wrong-password")
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeInvalidCredentials,
+		},
+		{
+			Name: "rejects an unknown email",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post("nobody@example.com", testutil.DefaultPassword)
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeInvalidCredentials,
+		},
+		{
+			Name: "locks the account after repeated failures",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				for i := 0; i < env.Config.Auth.LoginMaxFailures; i++ {
+					env.Do(post(user.Email, "wrong-password")).AssertError(http.StatusUnauthorized, utils.CodeInvalidCredentials)
+				}
+				return post(user.Email, testutil.DefaultPassword)
+			},
+			Status: http.StatusTooManyRequests,
+			Code:   utils.CodeAccountLocked,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				if res.Header().Get("Retry-After") == "" {
+					t.Error("no Retry-After header")
+				}
+			},
+		},
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	post := func(refreshToken string) testutil.Request {
+		return testutil.Request{Method: http.MethodPost, Path: "/api/v1/auth/refresh", Body: gin.H{"refresh_token": refreshToken}}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "rotates the refresh token",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(login(t, env, env.Customer()).RefreshToken)
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var tokens services.Tokens
+				res.Data(&tokens)
+				env.GET("/api/v1/me", tokens.Token).AssertStatus(http.StatusOK)
+			},
+		},
+		{
+			Name: "revokes the session when a used token comes back",
+			Setup: func(env *testutil.Env) testutil.Request {
+				first := login(t, env, env.Customer())
+				env.Do(post(first.RefreshToken)).AssertStatus(http.StatusOK)
+				return post(first.RefreshToken)
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeRefreshTokenReused,
+		},
+		{
+			Name: "rejects an unknown token",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post("unknown")
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeRefreshTokenInvalid,
+		},
+		{
+			Name: "requires a token",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodPost, Path: "/api/v1/auth/refresh", Body: gin.H{}}
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeValidationFailed,
+		},
+	})
+}
+
+func TestLogout(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "revokes the current session",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodPost, Path: "/api/v1/auth/logout", Token: env.Token(env.Customer())}
+			},
+			Status: http.StatusOK,
+		},
+		{
+			Name: "is not available to API keys",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodPost, Path: "/api/v1/auth/logout", APIKey: env.APIKey(env.Customer(), models.ScopeOrdersRead)}
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAPIKeyNotAllowed,
+		},
+		{
+			Name: "requires a token",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodPost, Path: "/api/v1/auth/logout"}
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeTokenMissing,
+		},
+	})
+
+	t.Run("rejects the tokens of signed out sessions", func(t *testing.T) {
+		env := testutil.New(t)
+		user := env.Customer()
+		current, other := env.Token(user), env.Token(user)
+
+		env.POST("/api/v1/auth/logout", current, nil).AssertStatus(http.StatusOK)
+		env.GET("/api/v1/me", current).AssertError(http.StatusUnauthorized, utils.CodeTokenRevoked)
+		env.GET("/api/v1/me", other).AssertStatus(http.StatusOK)
+
+		env.POST("/api/v1/auth/logout", other, gin.H{"all_sessions": true}).AssertStatus(http.StatusOK)
+		env.GET("/api/v1/me", other).AssertError(http.StatusUnauthorized, utils.CodeTokenRevoked)
+	})
+}
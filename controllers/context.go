@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"ecommerce-api/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// actor identifies the caller for the audit log of a service
+func actor(c *gin.Context) services.Actor {
+	a := services.Actor{
+		UserID:    c.GetUint("user_id"),
+		IP:        c.ClientIP(),
+		RequestID: c.GetString("request_id"),
+	}
+	if keyID, ok := c.Get("api_key_id"); ok {
+		id := keyID.(uint)
+		a.APIKeyID = &id
+	}
+	return a
+}
+
+// client describes the device a request comes from, for the session list
+func client(c *gin.Context) services.Client {
+	return services.Client{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
+
+// paramID parses a numeric ID from the URL; ok is false when it is not one
+func paramID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 0)
+	return uint(id), err == nil && id > 0
+}
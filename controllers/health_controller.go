@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"ecommerce-api/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	health  *utils.HealthRegistry
+	timeout time.Duration
+}
+
+// NewHealthHandler returns the probes for the checks in health, each bounded
+// by timeout
+func NewHealthHandler(health *utils.HealthRegistry, timeout time.Duration) *HealthHandler {
+	return &HealthHandler{health: health, timeout: timeout}
+}
+
+// Liveness reports that the process is up and serving requests
+// @Summary Liveness probe
+// @Description Always succeeds while the process can handle requests; it checks no dependencies
+// @Tags Health
+// @Produce  json
+// @Success 200 {object} map[string]string "Alive"
+// @Router /healthz [get]
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness runs the registered health checks and reports each result
+// @Summary Readiness probe
+// @Description Checks the database connection, the schema and the other registered subsystems. Responds 503 if any check fails.
+// @Tags Health
+// @Produce  json
+// @Success 200 {object} map[string]interface{} "Ready, with the result of each check"
+// @Failure 503 {object} map[string]interface{} "Not ready, with the result of each check"
+// @Router /readyz [get]
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	healthy, checks := h.health.Check(c.Request.Context(), h.timeout)
+
+	status, code := "ok", http.StatusOK
+	if !healthy {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(code, gin.H{"status": status, "checks": checks})
+}
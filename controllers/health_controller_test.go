@@ -0,0 +1,116 @@
+package controllers_test
+
+import (
+	"ecommerce-api/config"
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestProbes(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "reports liveness",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodGet, Path: "/healthz"}
+			},
+			Status: http.StatusOK,
+		},
+		{
+			Name: "reports the database and migrations ready",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodGet, Path: "/readyz"}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				// The endpoint tests need no database server
+				if name := env.DB.Dialector.Name(); name != "sqlite" {
+					t.Errorf("database = %s, want sqlite", name)
+				}
+				var body struct {
+					Status string                     `json:"status"`
+					Checks map[string]json.RawMessage `json:"checks"`
+				}
+				res.Decode(&body)
+				if body.Status != "ok" {
+					t.Errorf("status = %q, want ok", body.Status)
+				}
+				for _, name := range []string{"database", "migrations"} {
+					if _, ok := body.Checks[name]; !ok {
+						t.Errorf("no %s check", name)
+					}
+				}
+			},
+		},
+		{
+			Name: "reports not ready when the database is closed",
+			Setup: func(env *testutil.Env) testutil.Request {
+				db, err := env.DB.DB()
+				if err != nil {
+					t.Fatal(err)
+				}
+				db.Close()
+				return testutil.Request{Method: http.MethodGet, Path: "/readyz"}
+			},
+			Status: http.StatusServiceUnavailable,
+		},
+		{
+			Name: "publishes the signing keys",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodGet, Path: "/.well-known/jwks.json"}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var jwks struct {
+					Keys []interface{} `json:"keys"`
+				}
+				res.Decode(&jwks)
+				if jwks.Keys == nil {
+					t.Error("no keys member")
+				}
+			},
+		},
+	})
+}
+
+func TestErrorFormat(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "answers unknown routes with an API error",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodGet, Path: "/api/v1/nothing"}
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeNotFound,
+		},
+		{
+			Name: "answers unsupported methods with an API error",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodPatch, Path: "/api/v1/products"}
+			},
+			Status: http.StatusMethodNotAllowed,
+			Code:   utils.CodeMethodNotAllowed,
+		},
+		{
+			Name: "links problem types to the configured base URL",
+			Configure: func(cfg *config.Config) {
+				cfg.Server.ProblemTypeBaseURL = "https://example.com/problems"
+			},
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodGet, Path: "/api/v1/me", Header: http.Header{"Accept": {"application/problem+json"}}}
+			},
+			Status: http.StatusUnauthorized,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var problem struct {
+					Type string `json:"type"`
+				}
+				res.Decode(&problem)
+				if problem.Type != "https://example.com/problems/token-missing" {
+					t.Errorf("type = %q", problem.Type)
+				}
+			},
+		},
+	})
+}
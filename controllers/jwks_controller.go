@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"ecommerce-api/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publishes the keys of the token service
+type JWKSHandler struct {
+	tokens *utils.TokenService
+}
+
+// NewJWKSHandler returns the key set handler for tokens
+func NewJWKSHandler(tokens *utils.TokenService) *JWKSHandler {
+	return &JWKSHandler{tokens: tokens}
+}
+
+// JWKS publishes the public keys used to sign access tokens
+// @Summary JSON Web Key Set
+// @Description Public keys (RS256, ES256, EdDSA) that verify tokens issued by this API, identified by kid
+// @Tags Auth
+// @Produce  json
+// @Success 200 {object} utils.JWKSet "Key set"
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) JWKS(c *gin.Context) {
+	// Keys rotate rarely; let clients cache the set for a few minutes
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.tokens.JWKS())
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginMFA completes a login for accounts with two-factor authentication
+// @Summary Complete two-factor login
+// @Description Exchange the mfa_token from /login and a TOTP code (or a recovery code) for the access and refresh tokens. The mfa_token is single-use: after a wrong code, log in again.
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Invalid two-factor code"
+// @Failure 429 {object} utils.ErrorResponse "Too many failed attempts or account locked"
+// @Failure 500 {object} utils.ErrorResponse "Failed to generate token"
+// @Router /auth/login/mfa [post]
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var input struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	tokens, err := h.auth.LoginMFA(c.Request.Context(), input.MFAToken, input.Code, input.RecoveryCode, client(c))
+	if respondRetry(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrMFAChallengeInvalid):
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeMFATokenInvalid, "Invalid or expired MFA token")
+		return
+	case errors.Is(err, services.ErrMFACodeInvalid):
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeMFACodeInvalid, "Invalid two-factor code")
+		return
+	case err != nil:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to generate token")
+		return
+	}
+
+	utils.RespondSuccess(c, "Login successful", tokens)
+}
+
+// EnrollMFA starts two-factor enrolment for the authenticated user
+// @Summary Start two-factor enrolment
+// @Description Generate a TOTP secret and otpauth URI. Two-factor authentication is enabled once a code is confirmed.
+// @Tags Users
+// @Produce  json
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized user"
+// @Failure 409 {object} utils.ErrorResponse "Two-factor authentication already enabled"
+// @Failure 500 {object} utils.ErrorResponse "Failed to start enrolment"
+// @Security BearerAuth
+// @Router /me/2fa/enroll [post]
+func (h *AccountHandler) EnrollMFA(c *gin.Context) {
+	secret, uri, err := h.mfa.Enroll(c.Request.Context(), c.GetUint("user_id"))
+	if respondNoUser(c, err) {
+		return
+	}
+	if errors.Is(err, services.ErrMFAAlreadyEnabled) {
+		utils.RespondError(c, http.StatusConflict, utils.CodeMFAAlreadyEnabled, "Two-factor authentication is already enabled")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to start enrolment")
+		return
+	}
+
+	utils.RespondSuccess(c, "Scan the URI with an authenticator app and confirm with a code", gin.H{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+// ConfirmMFA enables two-factor authentication with a first code
+// @Summary Confirm two-factor enrolment
+// @Description Enable two-factor authentication with a code from the authenticator app. Every existing session is revoked; returns single-use recovery codes and a new token pair.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid two-factor code"
+// @Failure 409 {object} utils.ErrorResponse "Two-factor authentication already enabled"
+// @Failure 500 {object} utils.ErrorResponse "Failed to enable two-factor authentication"
+// @Security BearerAuth
+// @Router /me/2fa/confirm [post]
+func (h *AccountHandler) ConfirmMFA(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	user, codes, err := h.mfa.Confirm(c.Request.Context(), c.GetUint("user_id"), input.Code)
+	if respondNoUser(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrMFAAlreadyEnabled):
+		utils.RespondError(c, http.StatusConflict, utils.CodeMFAAlreadyEnabled, "Two-factor authentication is already enabled")
+		return
+	case errors.Is(err, services.ErrMFANotEnrolled):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeMFANotEnrolled, "Start enrolment first")
+		return
+	case errors.Is(err, services.ErrMFACodeInvalid):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeMFACodeInvalid, "Invalid two-factor code")
+		return
+	case err != nil:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to enable two-factor authentication")
+		return
+	}
+
+	// The new session counts as two-factor authenticated
+	tokens, err := h.sessions.Start(c.Request.Context(), user, true, client(c))
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to generate token")
+		return
+	}
+
+	utils.RespondSuccess(c, "Two-factor authentication enabled, store the recovery codes safely", struct {
+		*services.Tokens
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{tokens, codes})
+}
+
+// DisableMFA turns off two-factor authentication
+// @Summary Disable two-factor authentication
+// @Description Disable two-factor authentication after confirming the password and a current code or recovery code
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Invalid password or two-factor code"
+// @Failure 429 {object} utils.ErrorResponse "Too many failed attempts or account locked"
+// @Failure 500 {object} utils.ErrorResponse "Failed to disable two-factor authentication"
+// @Security BearerAuth
+// @Router /me/2fa [delete]
+func (h *AccountHandler) DisableMFA(c *gin.Context) {
+	var input struct {
+		Password     string `json:"password" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	err := h.mfa.Disable(c.Request.Context(), c.GetUint("user_id"), input.Password, input.Code, input.RecoveryCode, client(c))
+	if respondNoUser(c, err) || respondRetry(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrMFANotEnabled):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeMFANotEnabled, "Two-factor authentication is not enabled")
+		return
+	case errors.Is(err, services.ErrInvalidCredentials):
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeInvalidCredentials, "Invalid password or two-factor code")
+		return
+	case err != nil:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to disable two-factor authentication")
+		return
+	}
+
+	utils.RespondSuccess(c, "Two-factor authentication disabled", nil)
+}
@@ -0,0 +1,250 @@
+package controllers_test
+
+import (
+	"ecommerce-api/models"
+	"ecommerce-api/services"
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// totp returns the code of secret offset steps from now. Each code is
+// accepted once, so successive calls within a test use increasing offsets.
+func totp(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := utils.TOTPCode(secret, time.Now().Unix()/30+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// enableMFA enrols user in two-factor authentication with the code of the
+// previous step and returns the secret and recovery codes
+func enableMFA(t *testing.T, env *testutil.Env, user *models.User) (string, []string) {
+	t.Helper()
+	token := env.Token(user)
+	var enrolment struct {
+		Secret string `json:"secret"`
+	}
+	env.POST("/api/v1/me/2fa/enroll", token, nil).AssertStatus(http.StatusOK).Data(&enrolment)
+
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	env.POST("/api/v1/me/2fa/confirm", token, gin.H{"code": totp(t, enrolment.Secret, -1)}).
+		AssertStatus(http.StatusOK).Data(&confirmed)
+	return enrolment.Secret, confirmed.RecoveryCodes
+}
+
+// challenge signs a two-factor user in with the password and returns the MFA token
+func challenge(t *testing.T, env *testutil.Env, user *models.User) string {
+	t.Helper()
+	var result struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	env.POST("/api/v1/login", "", gin.H{"email": user.Email, "password": testutil.DefaultPassword}).
+		AssertStatus(http.StatusOK).Data(&result)
+	return result.MFAToken
+}
+
+func TestEnrollMFA(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "returns a secret and its URI",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodPost, Path: "/api/v1/me/2fa/enroll", Token: env.Token(env.Customer())}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var enrolment struct {
+					Secret string `json:"secret"`
+					URI    string `json:"otpauth_uri"`
+				}
+				res.Data(&enrolment)
+				if enrolment.Secret == "" || enrolment.URI == "" {
+					t.Errorf("enrolment = %+v", enrolment)
+				}
+			},
+		},
+		{
+			Name: "rejects users who have two-factor authentication",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				enableMFA(t, env, user)
+				return testutil.Request{Method: http.MethodPost, Path: "/api/v1/me/2fa/enroll", Token: env.Token(user)}
+			},
+			Status: http.StatusConflict,
+			Code:   utils.CodeMFAAlreadyEnabled,
+		},
+	})
+}
+
+func TestConfirmMFA(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "enables two-factor authentication",
+			Setup: func(env *testutil.Env) testutil.Request {
+				token := env.Token(env.Customer())
+				var enrolment struct {
+					Secret string `json:"secret"`
+				}
+				env.POST("/api/v1/me/2fa/enroll", token, nil).AssertStatus(http.StatusOK).Data(&enrolment)
+				return testutil.Request{Method: http.MethodPost, Path: "/api/v1/me/2fa/confirm", Token: token, Body: gin.H{"code": totp(t, enrolment.Secret, 0)}}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var confirmed struct {
+					services.Tokens
+					RecoveryCodes []string `json:"recovery_codes"`
+				}
+				res.Data(&confirmed)
+				if len(confirmed.RecoveryCodes) == 0 {
+					t.Error("no recovery codes")
+				}
+				env.GET("/api/v1/me", confirmed.Token).AssertStatus(http.StatusOK)
+			},
+		},
+		{
+			Name: "requires an enrolment",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodPost, Path: "/api/v1/me/2fa/confirm", Token: env.Token(env.Customer()), Body: gin.H{"code": "123456"}}
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeMFANotEnrolled,
+		},
+		{
+			Name: "rejects a wrong code",
+			Setup: func(env *testutil.Env) testutil.Request {
+				token := env.Token(env.Customer())
+				env.POST("/api/v1/me/2fa/enroll", token, nil).AssertStatus(http.StatusOK)
+				return testutil.Request{Method: http.MethodPost, Path: "/api/v1/me/2fa/confirm", Token: token, Body: gin.H{"code": "abcdef"}}
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeMFACodeInvalid,
+		},
+	})
+}
+
+func TestLoginMFA(t *testing.T) {
+	post := func(mfaToken string, body gin.H) testutil.Request {
+		body["mfa_token"] = mfaToken
+		return testutil.Request{Method: http.MethodPost, Path: "/api/v1/auth/login/mfa", Body: body}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "completes the login with a code",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				secret, _ := enableMFA(t, env, user)
+				return post(challenge(t, env, user), gin.H{"code": totp(t, secret, 0)})
+			},
+			Status: http.StatusOK,
+		},
+		{
+			Name: "completes the login with a recovery code",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				_, codes := enableMFA(t, env, user)
+				return post(challenge(t, env, user), gin.H{"recovery_code": codes[0]})
+			},
+			Status: http.StatusOK,
+		},
+		{
+			Name: "rejects a code used before",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				secret, _ := enableMFA(t, env, user)
+				return post(challenge(t, env, user), gin.H{"code": totp(t, secret, -1)})
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeMFACodeInvalid,
+		},
+		{
+			Name: "allows one attempt per challenge",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				secret, _ := enableMFA(t, env, user)
+				mfaToken := challenge(t, env, user)
+				env.Do(post(mfaToken, gin.H{"code": "000000"})).AssertStatus(http.StatusUnauthorized)
+				return post(mfaToken, gin.H{"code": totp(t, secret, 0)})
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeMFATokenInvalid,
+		},
+		{
+			Name: "rejects an invalid challenge",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post("not-a-token", gin.H{"code": "123456"})
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeMFATokenInvalid,
+		},
+	})
+}
+
+func TestDisableMFA(t *testing.T) {
+	del := func(token string, body gin.H) testutil.Request {
+		return testutil.Request{Method: http.MethodDelete, Path: "/api/v1/me/2fa", Token: token, Body: body}
+	}
+
+	var locked *models.User
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "disables two-factor authentication",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				secret, _ := enableMFA(t, env, user)
+				return del(env.Token(user), gin.H{"password": testutil.DefaultPassword, "code": totp(t, secret, 0)})
+			},
+			Status: http.StatusOK,
+		},
+		{
+			Name: "rejects a wrong password",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				secret, _ := enableMFA(t, env, user)
+				return del(env.Token(user), gin.H{"password": "wrong-password", "code": totp(t, secret, 0)})
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeInvalidCredentials,
+		},
+		{
+			Name: "locks the account after repeated wrong passwords",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				locked = user
+				secret, _ := enableMFA(t, env, user)
+				for i := 0; i < env.Config.Auth.LoginMaxFailures; i++ {
+					env.Do(del(env.Token(user), gin.H{"password": "wrong-password", "code": totp(t, secret, 0)})).
+						AssertError(http.StatusUnauthorized, utils.CodeInvalidCredentials)
+				}
+				return del(env.Token(user), gin.H{"password": testutil.DefaultPassword, "code": totp(t, secret, 0)})
+			},
+			Status: http.StatusTooManyRequests,
+			Code:   utils.CodeAccountLocked,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var user models.User
+				if err := env.DB.First(&user, locked.ID).Error; err != nil {
+					t.Fatal(err)
+				}
+				if !user.MFAEnabled() {
+					t.Error("two-factor authentication was disabled")
+				}
+			},
+		},
+		{
+			Name: "rejects users without two-factor authentication",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return del(env.Token(env.Customer()), gin.H{"password": testutil.DefaultPassword, "code": "123456"})
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeMFANotEnabled,
+		},
+	})
+}
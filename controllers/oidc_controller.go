@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oauthStateCookie carries the state of a login to the callback, binding the
+// flow to the browser that started it
+const oauthStateCookie = "oauth_state"
+
+// setStateCookie stores state in the browser, or clears the cookie when
+// maxAge is negative. It is only sent back to the OIDC endpoints.
+func (h *AuthHandler) setStateCookie(c *gin.Context, state string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state, maxAge, "/api/v1/auth/oidc/", "",
+		h.secureCookies, true)
+}
+
+// ListOIDCProviders lists the configured external login providers
+// @Summary List login providers
+// @Description Names of the OpenID Connect providers available for social login
+// @Tags Auth
+// @Produce  json
+// @Router /auth/oidc/providers [get]
+func (h *AuthHandler) ListOIDCProviders(c *gin.Context) {
+	utils.RespondSuccess(c, "Providers fetched successfully", h.auth.OIDCProviders())
+}
+
+// OIDCLogin starts an authorization code flow with PKCE
+// @Summary Start social login
+// @Description Redirect to the provider's authorization endpoint
+// @Tags Auth
+// @Param provider path string true "Provider name"
+// @Success 302 {string} string "Redirect to the provider"
+// @Failure 404 {object} utils.ErrorResponse "Unknown provider"
+// @Failure 502 {object} utils.ErrorResponse "Provider unavailable"
+// @Router /auth/oidc/{provider}/login [get]
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	authURL, state, err := h.auth.StartOIDC(c.Request.Context(), c.Param("provider"))
+	switch {
+	case errors.Is(err, services.ErrLoginProviderNotFound):
+		utils.RespondError(c, http.StatusNotFound, utils.CodeLoginProviderNotFound, "Unknown login provider")
+		return
+	case errors.Is(err, services.ErrLoginProviderUnavailable):
+		utils.Logger(c.Request.Context()).Error("OIDC discovery failed", "error", err)
+		utils.RespondError(c, http.StatusBadGateway, utils.CodeLoginProviderUnavailable, "Login provider unavailable")
+		return
+	case err != nil:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to start login")
+		return
+	}
+
+	h.setStateCookie(c, state, int(services.OAuthStateTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback completes the authorization code flow and signs the user in
+// @Summary Complete social login
+// @Description Handle the provider redirect, verify the ID token and issue our tokens. Identities are linked by verified email; unknown emails create a customer account. Linking an unverified account resets its password, two-factor setup and sessions.
+// @Tags Auth
+// @Produce  json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Failure 400 {object} utils.ErrorResponse "Invalid login state"
+// @Failure 401 {object} utils.ErrorResponse "Login rejected"
+// @Failure 404 {object} utils.ErrorResponse "Unknown provider"
+// @Failure 500 {object} utils.ErrorResponse "Failed to complete login"
+// @Router /auth/oidc/{provider}/callback [get]
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	if errParam := c.Query("error"); errParam != "" {
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeLoginRejected, "Login rejected by provider: "+errParam)
+		return
+	}
+
+	// The callback must arrive in the browser that started the login, or an
+	// attacker could have the victim complete a login the attacker started
+	cookie, err := c.Cookie(oauthStateCookie)
+	h.setStateCookie(c, "", -1)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(c.Query("state"))) != 1 {
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeLoginStateInvalid, "Invalid login state")
+		return
+	}
+
+	result, err := h.auth.FinishOIDC(c.Request.Context(), c.Param("provider"), c.Query("state"), c.Query("code"), client(c))
+	switch {
+	case errors.Is(err, services.ErrLoginProviderNotFound):
+		utils.RespondError(c, http.StatusNotFound, utils.CodeLoginProviderNotFound, "Unknown login provider")
+		return
+	case errors.Is(err, services.ErrLoginStateInvalid):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeLoginStateInvalid, "Invalid login state")
+		return
+	case errors.Is(err, services.ErrLoginStateExpired):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeLoginStateInvalid, "Login state expired, please start again")
+		return
+	case errors.Is(err, services.ErrLoginRejected):
+		utils.Logger(c.Request.Context()).Error("OIDC code exchange failed", "error", err)
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeLoginRejected, "Login rejected")
+		return
+	case errors.Is(err, services.ErrIdentityEmailUnverified):
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeEmailNotVerified, "The provider account has no verified email address")
+		return
+	case err != nil:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to complete login")
+		return
+	}
+
+	respondLogin(c, result)
+}
@@ -0,0 +1,55 @@
+package controllers_test
+
+import (
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"net/http"
+	"testing"
+)
+
+func TestOIDC(t *testing.T) {
+	get := func(path string) testutil.Request {
+		return testutil.Request{Method: http.MethodGet, Path: "/api/v1/auth/oidc" + path}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "lists no providers when none are configured",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return get("/providers")
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var providers []interface{}
+				res.Data(&providers)
+				if len(providers) != 0 {
+					t.Errorf("providers = %v, want none", providers)
+				}
+			},
+		},
+		{
+			Name: "rejects the login with an unknown provider",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return get("/example/login")
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeLoginProviderNotFound,
+		},
+		{
+			Name: "reports a login the provider rejected",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return get("/example/callback?error=access_denied")
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeLoginRejected,
+		},
+		{
+			Name: "requires the state cookie",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return get("/example/callback?state=abc&code=xyz")
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeLoginStateInvalid,
+		},
+	})
+}
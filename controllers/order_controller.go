@@ -1,88 +1,89 @@
 package controllers
 
 import (
-	"ecommerce-api/models"
+	"ecommerce-api/services"
 	"ecommerce-api/utils"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+// OrderHandler serves the order endpoints
+type OrderHandler struct {
+	orders *services.OrderService
+}
+
+// NewOrderHandler returns the order handlers for an order service
+func NewOrderHandler(orders *services.OrderService) *OrderHandler {
+	return &OrderHandler{orders: orders}
+}
+
 // CreateOrder places a new order for the authenticated user
 // @Summary Place a new order
-// @Description Place a new order for the authenticated user
+// @Description Place a new order for the authenticated user. The total is computed from the current prices; when
+// @Description the request includes a total it must match. The ordered quantities are taken from stock.
 // @Accept json
 // @Produce json
 // @Failure 400 {object} utils.ErrorResponse "Invalid order data"
+// @Failure 403 {object} utils.ErrorResponse "Email address not verified"
+// @Failure 404 {object} utils.ErrorResponse "Product not found"
+// @Failure 409 {object} utils.ErrorResponse "Insufficient stock or total does not match current prices"
 // @Failure 500 {object} utils.ErrorResponse "Internal server error"
 // @Router /orders [post]
-func CreateOrder(c *gin.Context) {
+func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	// Get user ID from the context (set by the AuthMiddleware)
-	userID, exists := c.Get("user_id")
-	if !exists {
-		utils.RespondError(c, http.StatusUnauthorized, "Unauthorized user not found")
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized user not found")
 		return
 	}
 
-	// Convert userID from float64 to uint
-	userIDUint, ok := userID.(float64)
-	if !ok {
-		utils.RespondError(c, http.StatusUnauthorized, "Invalid user ID")
-		return
-	}
-
-	convertedUserID := uint(userIDUint)
-
 	// Define the structure for the incoming order request
 	var orderInput struct {
 		Items []struct {
 			ProductID uint `json:"product_id" binding:"required"`
-			Quantity  uint `json:"quantity" binding:"required"`
-		} `json:"items" binding:"required"`
-		Total float64 `json:"total" binding:"required"`
+			Quantity  int  `json:"quantity" binding:"required,min=1,max=10000"`
+		} `json:"items" binding:"required,min=1,dive"`
+		Total *float64 `json:"total"`
 	}
 
 	// Bind the incoming JSON to the orderInput struct
 	if err := c.ShouldBindJSON(&orderInput); err != nil {
-		utils.RespondError(c, http.StatusBadRequest, "Invalid order data")
+		utils.RespondValidationError(c, err)
 		return
 	}
 
-	// Create a new order record
-	order := models.Order{
-		UserID: convertedUserID,
-		Total:  orderInput.Total,
-		Status: "Pending",
+	items := make([]services.OrderItemInput, len(orderInput.Items))
+	for i, item := range orderInput.Items {
+		items[i] = services.OrderItemInput{ProductID: item.ProductID, Quantity: item.Quantity}
 	}
 
-	// Save the order to the database
-	if err := models.DB.Create(&order).Error; err != nil {
-		utils.RespondError(c, http.StatusInternalServerError, "Failed to create order")
+	order, err := h.orders.Place(c.Request.Context(), userID, items, orderInput.Total)
+	var itemErr *services.ItemError
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized user not found")
+		return
+	case errors.Is(err, services.ErrEmailNotVerified):
+		utils.RespondError(c, http.StatusForbidden, utils.CodeEmailNotVerified, "Please verify your email address before placing orders")
+		return
+	case errors.As(err, &itemErr) && errors.Is(err, services.ErrProductNotFound):
+		utils.RespondError(c, http.StatusNotFound, utils.CodeProductNotFound, fmt.Sprintf("Product with ID %d not found", itemErr.ProductID))
+		return
+	case errors.As(err, &itemErr) && errors.Is(err, services.ErrQuantityInvalid):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeValidationFailed, fmt.Sprintf("Invalid quantity for product with ID %d", itemErr.ProductID))
+		return
+	case errors.As(err, &itemErr) && errors.Is(err, services.ErrInsufficientStock):
+		utils.RespondError(c, http.StatusConflict, utils.CodeInsufficientStock, fmt.Sprintf("Insufficient stock for product with ID %d", itemErr.ProductID))
+		return
+	case errors.Is(err, services.ErrOrderTotalMismatch):
+		utils.RespondError(c, http.StatusConflict, utils.CodeOrderTotalMismatch, "Order total does not match the current prices")
+		return
+	case err != nil:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to create order")
 		return
-	}
-
-	// Check if products exist before adding them to the order
-	for _, item := range orderInput.Items {
-		var product models.Product
-		// Check if the product exists
-		if err := models.DB.First(&product, item.ProductID).Error; err != nil {
-			utils.RespondError(c, http.StatusNotFound, fmt.Sprintf("Product with ID %d not found", item.ProductID))
-			return
-		}
-
-		// Convert uint to int for Quantity field
-		orderItem := models.OrderItem{
-			OrderID:   order.ID,
-			ProductID: item.ProductID,
-			Quantity:  int(item.Quantity), // Convert to int here
-		}
-
-		// Save the order items to the database
-		if err := models.DB.Create(&orderItem).Error; err != nil {
-			utils.RespondError(c, http.StatusInternalServerError, "Failed to add items to order")
-			return
-		}
 	}
 
 	// Respond with the created order details
@@ -98,18 +99,16 @@ func CreateOrder(c *gin.Context) {
 // @Failure 500 {object} utils.ErrorResponse "Failed to fetch orders"
 // @Security BearerAuth
 // @Router /orders [get]
-func GetOrders(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	// fmt.Println("userID String:", userID) // Debug log
-	// fmt.Println("exists :", exists)       // Debug log
-	if !exists {
-		utils.RespondError(c, http.StatusUnauthorized, "Unauthorized user not found")
+func (h *OrderHandler) GetOrders(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized user not found")
 		return
 	}
 
-	var orders []models.Order
-	if err := models.DB.Preload("Items").Where("user_id = ?", userID).Find(&orders).Error; err != nil {
-		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch orders")
+	orders, err := h.orders.ListForUser(c.Request.Context(), userID)
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to fetch orders")
 		return
 	}
 
@@ -118,63 +117,39 @@ func GetOrders(c *gin.Context) {
 
 // CancelOrder cancels an existing order for the authenticated user
 // @Summary Cancel an order
-// @Description Cancel an existing order for the authenticated user
+// @Description Cancel a pending order of the authenticated user and return its items to stock
 // @Tags Orders
 // @Param id path string true "Order ID"
-// @Failure 400 {object} utils.ErrorResponse "Order ID is required"
+// @Failure 400 {object} utils.ErrorResponse "Order is already canceled"
 // @Failure 401 {object} utils.ErrorResponse "Unauthorized user"
 // @Failure 403 {object} utils.ErrorResponse "Permission denied"
 // @Failure 404 {object} utils.ErrorResponse "Order not found"
+// @Failure 409 {object} utils.ErrorResponse "Order has already shipped"
 // @Failure 500 {object} utils.ErrorResponse "Failed to cancel the order"
 // @Security BearerAuth
 // @Router /orders/{id} [delete]
-func CancelOrder(c *gin.Context) {
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
 	// Retrieve the order ID from the URL
-	orderID := c.Param("id")
-	if orderID == "" {
-		utils.RespondError(c, http.StatusBadRequest, "Order ID is required")
-		return
-	}
-
-	// Retrieve the user ID from the context (set by the AuthMiddleware)
-	userID, exists := c.Get("user_id")
-	if !exists {
-		utils.RespondError(c, http.StatusUnauthorized, "Unauthorized user not found")
-		return
-	}
-
-	// Convert userID from float64 to uint
-	userIDUint, ok := userID.(float64)
+	orderID, ok := paramID(c, "id")
 	if !ok {
-		utils.RespondError(c, http.StatusUnauthorized, "Invalid user ID")
-		return
-	}
-
-	convertedUserID := uint(userIDUint)
-
-	// Find the order by ID
-	var order models.Order
-	if err := models.DB.First(&order, orderID).Error; err != nil {
-		utils.RespondError(c, http.StatusNotFound, fmt.Sprintf("Order with ID %s not found", orderID))
+		utils.RespondError(c, http.StatusNotFound, utils.CodeOrderNotFound, fmt.Sprintf("Order with ID %s not found", c.Param("id")))
 		return
 	}
 
-	// Check if the order belongs to the authenticated user
-	if order.UserID != convertedUserID {
-		utils.RespondError(c, http.StatusForbidden, "You do not have permission to cancel this order")
+	// Retrieve the user ID from the context (set by the AuthMiddleware)
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized user not found")
 		return
 	}
 
-	// Check if the order status is already "Canceled" or cannot be canceled
-	if order.Status == "Canceled" {
-		utils.RespondError(c, http.StatusBadRequest, "Order is already canceled")
+	order, err := h.orders.Cancel(c.Request.Context(), userID, orderID)
+	switch {
+	case errors.Is(err, services.ErrOrderNotOwned):
+		utils.RespondError(c, http.StatusForbidden, utils.CodeForbidden, "You do not have permission to cancel this order")
 		return
-	}
-
-	// Update the order status to "Canceled"
-	order.Status = "Canceled"
-	if err := models.DB.Save(&order).Error; err != nil {
-		utils.RespondError(c, http.StatusInternalServerError, "Failed to cancel the order")
+	case err != nil:
+		respondOrderError(c, err, "Failed to cancel the order")
 		return
 	}
 
@@ -184,19 +159,21 @@ func CancelOrder(c *gin.Context) {
 
 // UpdateOrderStatus updates the status of an order (admin only)
 // @Summary Update the status of an order
-// @Description Update the status (e.g., Pending, Shipped, Delivered, Canceled) of an order
+// @Description Move an order to Shipped, Delivered or Canceled. Pending orders may move to any of these and shipped
+// @Description orders to Delivered or Canceled; delivered and canceled orders are final. Canceling returns the items to stock.
 // @Tags Orders
 // @Param id path string true "Order ID"
 // @Failure 400 {object} utils.ErrorResponse "Invalid status value"
 // @Failure 404 {object} utils.ErrorResponse "Order not found"
+// @Failure 409 {object} utils.ErrorResponse "Status change not allowed"
 // @Failure 500 {object} utils.ErrorResponse "Failed to update order status"
 // @Security BearerAuth
 // @Router /orders/{id}/status [put]
-func UpdateOrderStatus(c *gin.Context) {
+func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	// Retrieve the order ID from the URL
-	orderID := c.Param("id")
-	if orderID == "" {
-		utils.RespondError(c, http.StatusBadRequest, "Order ID is required")
+	orderID, ok := paramID(c, "id")
+	if !ok {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeOrderNotFound, fmt.Sprintf("Order with ID %s not found", c.Param("id")))
 		return
 	}
 
@@ -207,37 +184,35 @@ func UpdateOrderStatus(c *gin.Context) {
 
 	// Bind the incoming JSON to the statusUpdate struct
 	if err := c.ShouldBindJSON(&statusUpdate); err != nil {
-		utils.RespondError(c, http.StatusBadRequest, "Invalid request data")
+		utils.RespondValidationError(c, err)
 		return
 	}
 
-	// Find the order by ID
-	var order models.Order
-	if err := models.DB.First(&order, orderID).Error; err != nil {
-		utils.RespondError(c, http.StatusNotFound, fmt.Sprintf("Order with ID %s not found", orderID))
-		return
-	}
-
-	// Ensure that only valid statuses are accepted (e.g., Pending, Shipped, Delivered, Canceled)
-	validStatuses := map[string]bool{
-		"Pending":   true,
-		"Shipped":   true,
-		"Delivered": true,
-		"Canceled":  true,
-	}
-
-	if _, valid := validStatuses[statusUpdate.Status]; !valid {
-		utils.RespondError(c, http.StatusBadRequest, "Invalid status value")
-		return
-	}
-
-	// Update the order status
-	order.Status = statusUpdate.Status
-	if err := models.DB.Save(&order).Error; err != nil {
-		utils.RespondError(c, http.StatusInternalServerError, "Failed to update order status")
+	order, err := h.orders.UpdateStatus(c.Request.Context(), actor(c), orderID, statusUpdate.Status)
+	if err != nil {
+		respondOrderError(c, err, "Failed to update order status")
 		return
 	}
 
 	// Respond with the updated order details
 	utils.RespondSuccess(c, "Order status updated successfully", order)
 }
+
+// respondOrderError responds to the errors shared by the order status changes
+func respondOrderError(c *gin.Context, err error, internal string) {
+	var statusErr *services.StatusError
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		utils.RespondError(c, http.StatusNotFound, utils.CodeOrderNotFound, fmt.Sprintf("Order with ID %s not found", c.Param("id")))
+	case errors.Is(err, services.ErrOrderAlreadyCanceled):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeOrderAlreadyCanceled, "Order is already canceled")
+	case errors.As(err, &statusErr):
+		utils.RespondError(c, http.StatusConflict, utils.CodeOrderStatusConflict, fmt.Sprintf("An order cannot change from %s to %s", statusErr.From, statusErr.To))
+	case errors.Is(err, services.ErrOrderStatusInvalid):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeOrderStatusInvalid, "Invalid status value")
+	case errors.Is(err, services.ErrOrderStatusChanged):
+		utils.RespondError(c, http.StatusConflict, utils.CodeOrderStatusConflict, "The order was changed by another request, please retry")
+	default:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, internal)
+	}
+}
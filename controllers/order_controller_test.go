@@ -0,0 +1,292 @@
+package controllers_test
+
+import (
+	"ecommerce-api/config"
+	"ecommerce-api/models"
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// orderPath returns the path of an order
+func orderPath(order *models.Order) string {
+	return fmt.Sprintf("/api/v1/orders/%d", order.ID)
+}
+
+func TestCreateOrder(t *testing.T) {
+	post := func(token string, body interface{}) testutil.Request {
+		return testutil.Request{Method: http.MethodPost, Path: "/api/v1/orders", Token: token, Body: body}
+	}
+	items := func(product *models.Product, quantity int) gin.H {
+		return gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": quantity}}}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "places an order and reserves the stock",
+			Setup: func(env *testutil.Env) testutil.Request {
+				product := env.Product(models.Product{Price: 2.5, Stock: 10})
+				return post(env.Token(env.Customer()), items(product, 4))
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var order models.Order
+				res.Data(&order)
+				if order.Total != 10 || order.Status != models.OrderPending {
+					t.Errorf("order = %+v, want a pending order of 10", order)
+				}
+				var product models.Product
+				env.DB.First(&product, order.Items[0].ProductID)
+				if product.Stock != 6 {
+					t.Errorf("stock = %d, want 6", product.Stock)
+				}
+			},
+		},
+		{
+			Name: "accepts an API key with orders:write",
+			Setup: func(env *testutil.Env) testutil.Request {
+				req := post("", items(env.Product(models.Product{}), 1))
+				req.APIKey = env.APIKey(env.Customer(), models.ScopeOrdersWrite)
+				return req
+			},
+			Status: http.StatusOK,
+		},
+		{
+			Name: "rejects an API key without orders:write",
+			Setup: func(env *testutil.Env) testutil.Request {
+				req := post("", items(env.Product(models.Product{}), 1))
+				req.APIKey = env.APIKey(env.Customer(), models.ScopeOrdersRead)
+				return req
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeInsufficientScope,
+		},
+		{
+			Name: "requires a token",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post("", items(env.Product(models.Product{}), 1))
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeTokenMissing,
+		},
+		{
+			Name: "rejects an invalid token",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post("not-a-token", items(env.Product(models.Product{}), 1))
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeTokenInvalid,
+		},
+		{
+			Name: "rejects an order without items",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Customer()), gin.H{"items": []gin.H{}})
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeValidationFailed,
+		},
+		{
+			Name: "rejects a negative quantity",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Customer()), items(env.Product(models.Product{}), -1))
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeValidationFailed,
+		},
+		{
+			Name: "rejects an unknown product",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Customer()), gin.H{"items": []gin.H{{"product_id": 999, "quantity": 1}}})
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeProductNotFound,
+		},
+		{
+			Name: "rejects more than the stock",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Customer()), items(env.Product(models.Product{Stock: 3}), 4))
+			},
+			Status: http.StatusConflict,
+			Code:   utils.CodeInsufficientStock,
+		},
+		{
+			Name: "rejects a total that differs from the prices",
+			Setup: func(env *testutil.Env) testutil.Request {
+				body := items(env.Product(models.Product{Price: 5}), 2)
+				body["total"] = 9.99
+				return post(env.Token(env.Customer()), body)
+			},
+			Status: http.StatusConflict,
+			Code:   utils.CodeOrderTotalMismatch,
+		},
+		{
+			Name:      "requires a verified email when configured",
+			Configure: func(cfg *config.Config) { cfg.Auth.RequireVerifiedEmailForOrders = true },
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.User(testutil.UserAttrs{})
+				return post(env.Token(user), items(env.Product(models.Product{}), 1))
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeEmailNotVerified,
+		},
+	})
+}
+
+func TestGetOrders(t *testing.T) {
+	env := testutil.New(t)
+	customer, other := env.Customer(), env.Customer()
+	product := env.Product(models.Product{})
+	mine := env.Order(customer, testutil.Item(product, 1))
+	env.Order(other, testutil.Item(product, 1))
+
+	var orders []models.Order
+	env.GET("/api/v1/orders", env.Token(customer)).AssertStatus(http.StatusOK).Data(&orders)
+	if len(orders) != 1 || orders[0].ID != mine.ID {
+		t.Errorf("orders = %+v, want only the customer's order %d", orders, mine.ID)
+	}
+
+	env.Do(testutil.Request{Method: http.MethodGet, Path: "/api/v1/orders", APIKey: env.APIKey(customer, models.ScopeOrdersRead)}).
+		AssertStatus(http.StatusOK)
+	env.Do(testutil.Request{Method: http.MethodGet, Path: "/api/v1/orders", APIKey: env.APIKey(customer, models.ScopeOrdersWrite)}).
+		AssertError(http.StatusForbidden, utils.CodeInsufficientScope)
+	env.GET("/api/v1/orders", "").AssertError(http.StatusUnauthorized, utils.CodeTokenMissing)
+}
+
+func TestCancelOrderOfAnotherUser(t *testing.T) {
+	env := testutil.New(t)
+	order := env.Order(env.Customer(), testutil.Item(env.Product(models.Product{}), 1))
+	res := env.DELETE(fmt.Sprintf("/api/v1/orders/%d", order.ID), env.Token(env.Customer()))
+	res.AssertError(http.StatusForbidden, utils.CodeForbidden)
+}
+
+func TestCancelOrder(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "cancels a pending order and returns the stock",
+			Setup: func(env *testutil.Env) testutil.Request {
+				customer := env.Customer()
+				order := env.Order(customer, testutil.Item(env.Product(models.Product{Stock: 5}), 2))
+				return testutil.Request{Method: http.MethodDelete, Path: orderPath(order), Token: env.Token(customer)}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var product models.Product
+				env.DB.First(&product)
+				if product.Stock != 5 {
+					t.Errorf("stock = %d, want 5", product.Stock)
+				}
+			},
+		},
+		{
+			Name: "rejects an order canceled before",
+			Setup: func(env *testutil.Env) testutil.Request {
+				customer := env.Customer()
+				order := env.Order(customer, testutil.Item(env.Product(models.Product{}), 1))
+				env.DELETE(orderPath(order), env.Token(customer)).AssertStatus(http.StatusOK)
+				return testutil.Request{Method: http.MethodDelete, Path: orderPath(order), Token: env.Token(customer)}
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeOrderAlreadyCanceled,
+		},
+		{
+			Name: "rejects a shipped order",
+			Setup: func(env *testutil.Env) testutil.Request {
+				customer := env.Customer()
+				order := env.Order(customer, testutil.Item(env.Product(models.Product{}), 1))
+				env.PUT(orderPath(order), env.Token(env.Admin()), gin.H{"status": models.OrderShipped}).AssertStatus(http.StatusOK)
+				return testutil.Request{Method: http.MethodDelete, Path: orderPath(order), Token: env.Token(customer)}
+			},
+			Status: http.StatusConflict,
+			Code:   utils.CodeOrderStatusConflict,
+		},
+		{
+			Name: "rejects an unknown order",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodDelete, Path: "/api/v1/orders/999", Token: env.Token(env.Customer())}
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeOrderNotFound,
+		},
+	})
+}
+
+func TestUpdateOrderStatus(t *testing.T) {
+	put := func(env *testutil.Env, token, status string) testutil.Request {
+		order := env.Order(env.Customer(), testutil.Item(env.Product(models.Product{}), 1))
+		return testutil.Request{Method: http.MethodPut, Path: orderPath(order), Token: token, Body: gin.H{"status": status}}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "ships an order",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return put(env, env.Token(env.Admin()), models.OrderShipped)
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var order models.Order
+				res.Data(&order)
+				if order.Status != models.OrderShipped {
+					t.Errorf("status = %q, want %q", order.Status, models.OrderShipped)
+				}
+			},
+		},
+		{
+			Name: "accepts an API key with orders:manage",
+			Setup: func(env *testutil.Env) testutil.Request {
+				req := put(env, "", models.OrderShipped)
+				req.APIKey = env.APIKey(env.Admin(), models.ScopeOrdersManage)
+				return req
+			},
+			Status: http.StatusOK,
+		},
+		{
+			Name: "is for admins only",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return put(env, env.Token(env.Customer()), models.OrderShipped)
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAdminRequired,
+		},
+		{
+			Name:      "requires two-factor admins when configured",
+			Configure: func(cfg *config.Config) { cfg.Auth.RequireAdminMFA = true },
+			Setup: func(env *testutil.Env) testutil.Request {
+				return put(env, env.SessionToken(env.Admin(), false), models.OrderShipped)
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeMFARequired,
+		},
+		{
+			Name: "rejects an unknown status",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return put(env, env.Token(env.Admin()), "Lost")
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeOrderStatusInvalid,
+		},
+		{
+			Name: "rejects going back to pending",
+			Setup: func(env *testutil.Env) testutil.Request {
+				req := put(env, env.Token(env.Admin()), models.OrderShipped)
+				env.Do(req).AssertStatus(http.StatusOK)
+				req.Body = gin.H{"status": models.OrderPending}
+				return req
+			},
+			Status: http.StatusConflict,
+			Code:   utils.CodeOrderStatusConflict,
+		},
+		{
+			Name: "rejects an unknown order",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodPut, Path: "/api/v1/orders/999", Token: env.Token(env.Admin()), Body: gin.H{"status": models.OrderShipped}}
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeOrderNotFound,
+		},
+	})
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForgotPassword emails a password reset link
+// @Summary Request a password reset
+// @Description Email a single-use password reset token. The response is the same whether or not the email is registered.
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 500 {object} utils.ErrorResponse "Failed to send password reset email"
+// @Router /auth/forgot-password [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	if err := h.users.RequestPasswordReset(c.Request.Context(), input.Email); err != nil {
+		utils.Logger(c.Request.Context()).Error("Failed to send password reset email", "error", err)
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to send password reset email")
+		return
+	}
+
+	utils.RespondSuccess(c, "If the account exists, a password reset email has been sent", nil)
+}
+
+// ResetPassword sets a new password using a reset token
+// @Summary Reset password
+// @Description Set a new password with a token from the forgot-password email. All existing sessions are revoked.
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid or expired reset token"
+// @Failure 500 {object} utils.ErrorResponse "Failed to reset password"
+// @Router /auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	err := h.users.ResetPassword(c.Request.Context(), input.Token, input.Password)
+	if errors.Is(err, services.ErrResetTokenInvalid) {
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeResetTokenInvalid, "Invalid or expired reset token")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to reset password")
+		return
+	}
+
+	utils.RespondSuccess(c, "Password reset successfully", nil)
+}
+
+// ChangePassword changes the password of the authenticated user
+// @Summary Change password
+// @Description Change the password after confirming the current one. Other sessions are revoked and a new token pair is returned.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Current password is incorrect"
+// @Failure 500 {object} utils.ErrorResponse "Failed to change password"
+// @Security BearerAuth
+// @Router /me/password [put]
+func (h *AccountHandler) ChangePassword(c *gin.Context) {
+	var input struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	user, err := h.users.ChangePassword(c.Request.Context(), c.GetUint("user_id"), input.CurrentPassword, input.NewPassword)
+	if respondNoUser(c, err) {
+		return
+	}
+	if errors.Is(err, services.ErrPasswordIncorrect) {
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeInvalidPassword, "Current password is incorrect")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to change password")
+		return
+	}
+
+	// Keep the caller signed in with a fresh session
+	tokens, err := h.sessions.Start(c.Request.Context(), user, c.GetBool("mfa"), client(c))
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to generate token")
+		return
+	}
+
+	utils.RespondSuccess(c, "Password changed successfully", tokens)
+}
@@ -0,0 +1,132 @@
+package controllers_test
+
+import (
+	"ecommerce-api/services"
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestForgotPassword(t *testing.T) {
+	post := func(email string) testutil.Request {
+		return testutil.Request{Method: http.MethodPost, Path: "/api/v1/auth/forgot-password", Body: gin.H{"email": email}}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "emails a reset link",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Customer().Email)
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				if len(env.Mail.Messages()) != 1 {
+					t.Errorf("sent %d emails, want 1", len(env.Mail.Messages()))
+				}
+			},
+		},
+		{
+			Name: "answers the same for an unknown email",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post("nobody@example.com")
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				if len(env.Mail.Messages()) != 0 {
+					t.Errorf("sent %d emails, want none", len(env.Mail.Messages()))
+				}
+			},
+		},
+		{
+			Name: "rejects an invalid email",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post("not-an-email")
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeValidationFailed,
+		},
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	post := func(token string) testutil.Request {
+		return testutil.Request{Method: http.MethodPost, Path: "/api/v1/auth/reset-password", Body: gin.H{"token": token, "password": "new-password"}}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "sets the password and signs out every session",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				env.POST("/api/v1/auth/forgot-password", "", gin.H{"email": user.Email}).AssertStatus(http.StatusOK)
+				return post(env.Mail.Token(user.Email))
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				email := env.Mail.Messages()[0].To
+				env.POST("/api/v1/login", "", gin.H{"email": email, "password": "new-password"}).AssertStatus(http.StatusOK)
+				env.POST("/api/v1/login", "", gin.H{"email": email, "password": testutil.DefaultPassword}).
+					AssertError(http.StatusUnauthorized, utils.CodeInvalidCredentials)
+			},
+		},
+		{
+			Name: "accepts each token once",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				env.POST("/api/v1/auth/forgot-password", "", gin.H{"email": user.Email}).AssertStatus(http.StatusOK)
+				token := env.Mail.Token(user.Email)
+				env.Do(post(token)).AssertStatus(http.StatusOK)
+				return post(token)
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeResetTokenInvalid,
+		},
+		{
+			Name: "rejects an invalid token",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post("not-a-token")
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeResetTokenInvalid,
+		},
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	put := func(token, current string) testutil.Request {
+		return testutil.Request{Method: http.MethodPut, Path: "/api/v1/me/password", Token: token, Body: gin.H{"current_password": current, "new_password": "new-password"}}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "returns a new session and signs out the others",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return put(env.Token(env.Customer()), testutil.DefaultPassword)
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var tokens services.Tokens
+				res.Data(&tokens)
+				env.GET("/api/v1/me", tokens.Token).AssertStatus(http.StatusOK)
+			},
+		},
+		{
+			Name: "rejects a wrong current password",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return put(env.Token(env.Customer()), "wrong-password")
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeInvalidPassword,
+		},
+	})
+
+	t.Run("revokes the previous sessions", func(t *testing.T) {
+		env := testutil.New(t)
+		token := env.Token(env.Customer())
+		env.Do(put(token, testutil.DefaultPassword)).AssertStatus(http.StatusOK)
+		env.GET("/api/v1/me", token).AssertError(http.StatusUnauthorized, utils.CodeTokenRevoked)
+	})
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondExport sends the export of a user as a downloadable JSON file
+func respondExport(c *gin.Context, export *services.UserExport) {
+	sessions := make([]gin.H, 0, len(export.Sessions))
+	for _, session := range export.Sessions {
+		sessions = append(sessions, gin.H{
+			"id":         session.ID,
+			"created_at": session.CreatedAt,
+			"expires_at": session.ExpiresAt,
+			"revoked_at": session.RevokedAt,
+			"user_agent": session.UserAgent,
+			"ip":         session.IP,
+		})
+	}
+	identities := make([]gin.H, 0, len(export.Identities))
+	for _, identity := range export.Identities {
+		identities = append(identities, gin.H{
+			"provider":  identity.Provider,
+			"subject":   identity.Subject,
+			"email":     identity.Email,
+			"linked_at": identity.CreatedAt,
+		})
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-export.json"`, export.User.ID))
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"exported_at":   time.Now(),
+		"profile":       profileOf(export.User),
+		"orders":        export.Orders,
+		"sessions":      sessions,
+		"identities":    identities,
+		"api_keys":      export.APIKeys,
+		"data_requests": export.DataRequests,
+	})
+}
+
+// ExportMyData exports the data of the authenticated user
+// @Summary Export my data
+// @Description Download everything stored about the authenticated user (profile, orders, sessions, linked accounts) as JSON
+// @Tags Users
+// @Produce  json
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized user"
+// @Failure 500 {object} utils.ErrorResponse "Failed to export data"
+// @Security BearerAuth
+// @Router /me/export [get]
+func (h *AccountHandler) ExportMyData(c *gin.Context) {
+	export, err := h.privacy.Export(c.Request.Context(), actor(c), c.GetUint("user_id"))
+	if respondNoUser(c, err) {
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to export data")
+		return
+	}
+
+	respondExport(c, export)
+}
+
+// DeleteMyAccount erases the account of the authenticated user
+// @Summary Delete my account
+// @Description Anonymise the personal data of the authenticated user and sign out everywhere. Orders are kept for accounting.
+// @Description Confirm with the password. Accounts linked to an external identity may confirm with a two-factor code instead, or send no body from a session signed in during the last ten minutes.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Password or two-factor code is incorrect, or the login is not recent"
+// @Failure 429 {object} utils.ErrorResponse "Too many failed attempts or account locked"
+// @Failure 500 {object} utils.ErrorResponse "Failed to delete account"
+// @Security BearerAuth
+// @Router /me [delete]
+func (h *AccountHandler) DeleteMyAccount(c *gin.Context) {
+	var input struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	// The body is optional for a recent login
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	err := h.privacy.ConfirmErasure(c.Request.Context(), user, c.GetUint("session_id"), input.Password, input.Code, input.RecoveryCode, client(c))
+	if respondRetry(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrPasswordIncorrect):
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeInvalidPassword, "Password is incorrect")
+		return
+	case errors.Is(err, services.ErrMFANotEnabled):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeMFANotEnabled, "Two-factor authentication is not enabled")
+		return
+	case errors.Is(err, services.ErrMFACodeInvalid):
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeMFACodeInvalid, "Invalid two-factor code")
+		return
+	case errors.Is(err, services.ErrReauthRequired):
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeReauthRequired, "Sign in again or confirm with a two-factor code to delete the account")
+		return
+	case err != nil:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to delete account")
+		return
+	}
+
+	if err := h.privacy.Erase(c.Request.Context(), actor(c), user.ID); err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to delete account")
+		return
+	}
+
+	utils.RespondSuccess(c, "Account deleted successfully", nil)
+}
+
+// ExportUserData exports the data of a user (admin only)
+// @Summary Export a user's data
+// @Description Download everything stored about a user as JSON, e.g. to answer a request received by email
+// @Tags Admin
+// @Produce  json
+// @Param id path string true "User ID"
+// @Failure 404 {object} utils.ErrorResponse "User not found"
+// @Failure 500 {object} utils.ErrorResponse "Failed to export data"
+// @Security BearerAuth
+// @Router /admin/users/{id}/export [get]
+func (h *UserHandler) ExportUserData(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeUserNotFound, "User not found")
+		return
+	}
+
+	export, err := h.privacy.Export(c.Request.Context(), actor(c), id)
+	if errors.Is(err, services.ErrUserNotFound) {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeUserNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to export data")
+		return
+	}
+
+	respondExport(c, export)
+}
+
+// DeleteUser erases a user account (admin only)
+// @Summary Delete a user account
+// @Description Anonymise the personal data of a user and sign them out everywhere. Orders are kept for accounting.
+// @Tags Admin
+// @Param id path string true "User ID"
+// @Failure 404 {object} utils.ErrorResponse "User not found"
+// @Failure 500 {object} utils.ErrorResponse "Failed to delete user"
+// @Security BearerAuth
+// @Router /admin/users/{id} [delete]
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeUserNotFound, "User not found")
+		return
+	}
+
+	err := h.privacy.Erase(c.Request.Context(), actor(c), id)
+	if errors.Is(err, services.ErrUserNotFound) {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeUserNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to delete user")
+		return
+	}
+
+	utils.RespondSuccess(c, "User deleted successfully", nil)
+}
+
+// GetDataRequests lists the data request audit trail (admin only)
+// @Summary List data requests
+// @Description List data exports and account erasures, newest first, optionally for one user
+// @Tags Admin
+// @Produce  json
+// @Param user_id query string false "User ID"
+// @Failure 400 {object} utils.ErrorResponse "Invalid user ID"
+// @Failure 500 {object} utils.ErrorResponse "Failed to fetch data requests"
+// @Security BearerAuth
+// @Router /admin/data-requests [get]
+func (h *UserHandler) GetDataRequests(c *gin.Context) {
+	var userID uint64
+	if raw := c.Query("user_id"); raw != "" {
+		var err error
+		if userID, err = strconv.ParseUint(raw, 10, 0); err != nil || userID == 0 {
+			utils.RespondError(c, http.StatusBadRequest, utils.CodeBadRequest, "Invalid user ID")
+			return
+		}
+	}
+
+	requests, err := h.privacy.DataRequests(c.Request.Context(), uint(userID))
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to fetch data requests")
+		return
+	}
+
+	utils.RespondSuccess(c, "Data requests fetched successfully", requests)
+}
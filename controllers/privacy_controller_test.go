@@ -0,0 +1,202 @@
+package controllers_test
+
+import (
+	"ecommerce-api/models"
+	"ecommerce-api/services"
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userPath returns the admin path of a user
+func userPath(id uint) string {
+	return fmt.Sprintf("/api/v1/admin/users/%d", id)
+}
+
+func TestExportMyData(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "downloads the profile and orders",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				env.Order(user, testutil.Item(env.Product(models.Product{}), 1))
+				return testutil.Request{Method: http.MethodGet, Path: "/api/v1/me/export", Token: env.Token(user)}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var export struct {
+					Orders []models.Order `json:"orders"`
+				}
+				res.Decode(&export)
+				if len(export.Orders) != 1 {
+					t.Errorf("exported %d orders, want 1", len(export.Orders))
+				}
+				if res.Header().Get("Content-Disposition") == "" {
+					t.Error("no Content-Disposition header")
+				}
+			},
+		},
+	})
+}
+
+func TestDeleteMyAccount(t *testing.T) {
+	del := func(token string, body interface{}) testutil.Request {
+		return testutil.Request{Method: http.MethodDelete, Path: "/api/v1/me", Token: token, Body: body}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "erases the account and signs it out",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return del(env.Token(env.Customer()), gin.H{"password": testutil.DefaultPassword})
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var count int64
+				env.DB.Model(&models.User{}).Count(&count)
+				if count != 0 {
+					t.Errorf("%d users left, want 0", count)
+				}
+			},
+		},
+		{
+			Name: "rejects a wrong password",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return del(env.Token(env.Customer()), gin.H{"password": "wrong-password"})
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeInvalidPassword,
+		},
+		{
+			Name: "locks the account after repeated wrong passwords",
+			Setup: func(env *testutil.Env) testutil.Request {
+				token := env.Token(env.Customer())
+				for i := 0; i < env.Config.Auth.LoginMaxFailures; i++ {
+					env.Do(del(token, gin.H{"password": "wrong-password"})).AssertError(http.StatusUnauthorized, utils.CodeInvalidPassword)
+				}
+				return del(token, gin.H{"password": testutil.DefaultPassword})
+			},
+			Status: http.StatusTooManyRequests,
+			Code:   utils.CodeAccountLocked,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var count int64
+				env.DB.Model(&models.User{}).Count(&count)
+				if count != 1 {
+					t.Errorf("%d users left, want 1", count)
+				}
+			},
+		},
+		{
+			Name: "requires the password of password users",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return del(env.Token(env.Customer()), nil)
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeInvalidPassword,
+		},
+	})
+
+	t.Run("requires a recent login without a password", func(t *testing.T) {
+		env := testutil.New(t)
+		user := env.Customer()
+		if err := env.DB.Create(&models.UserIdentity{UserID: user.ID, Provider: "example", Subject: "1"}).Error; err != nil {
+			t.Fatal(err)
+		}
+		token := env.Token(user)
+		env.DB.Model(&models.Session{}).Where("user_id = ?", user.ID).Update("created_at", time.Now().Add(-2*services.ReauthWindow))
+		env.Do(del(token, nil)).AssertError(http.StatusUnauthorized, utils.CodeReauthRequired)
+
+		env.Do(del(env.Token(user), nil)).AssertStatus(http.StatusOK)
+	})
+}
+
+func TestExportUserData(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "downloads the data of a user",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodGet, Path: userPath(env.Customer().ID) + "/export", Token: env.Token(env.Admin())}
+			},
+			Status: http.StatusOK,
+		},
+		{
+			Name: "rejects an unknown user",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodGet, Path: userPath(999) + "/export", Token: env.Token(env.Admin())}
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeUserNotFound,
+		},
+		{
+			Name: "is for admins only",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				return testutil.Request{Method: http.MethodGet, Path: userPath(user.ID) + "/export", Token: env.Token(user)}
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAdminRequired,
+		},
+	})
+}
+
+func TestDeleteUser(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "erases a user and records the request",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodDelete, Path: userPath(env.Customer().ID), Token: env.Token(env.Admin())}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var requests []models.DataRequest
+				env.GET("/api/v1/admin/data-requests", env.Token(env.Admin())).AssertStatus(http.StatusOK).Data(&requests)
+				if len(requests) != 1 {
+					t.Errorf("%d data requests, want 1", len(requests))
+				}
+			},
+		},
+		{
+			Name: "rejects an unknown user",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodDelete, Path: userPath(999), Token: env.Token(env.Admin())}
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeUserNotFound,
+		},
+	})
+}
+
+func TestGetDataRequests(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "filters by user",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				env.GET("/api/v1/me/export", env.Token(user)).AssertStatus(http.StatusOK)
+				env.GET("/api/v1/me/export", env.Token(env.Customer())).AssertStatus(http.StatusOK)
+				return testutil.Request{Method: http.MethodGet, Path: fmt.Sprintf("/api/v1/admin/data-requests?user_id=%d", user.ID), Token: env.Token(env.Admin())}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var requests []models.DataRequest
+				res.Data(&requests)
+				if len(requests) != 1 {
+					t.Errorf("%d data requests, want 1", len(requests))
+				}
+			},
+		},
+		{
+			Name: "rejects an invalid user ID",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodGet, Path: "/api/v1/admin/data-requests?user_id=x", Token: env.Token(env.Admin())}
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeBadRequest,
+		},
+	})
+}
@@ -2,12 +2,24 @@ package controllers
 
 import (
 	"ecommerce-api/models"
+	"ecommerce-api/services"
 	"ecommerce-api/utils"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ProductHandler serves the catalog endpoints
+type ProductHandler struct {
+	products *services.ProductService
+}
+
+// NewProductHandler returns the catalog handlers for a product service
+func NewProductHandler(products *services.ProductService) *ProductHandler {
+	return &ProductHandler{products: products}
+}
+
 // CreateProduct creates a new product (admin only)
 // @Summary Create a new product
 // @Description Creates a new product, requires admin access
@@ -17,7 +29,7 @@ import (
 // @Param product body models.Product true "Product data"
 // @Success 201 {object} models.Product "Product created successfully"
 // @Failure 400 {object} utils.ErrorResponse "Invalid request data"
-// @Failure 409 {object} utils.ErrorResponse "Product with this name already exists"
+// @Failure 409 {object} utils.ErrorResponse "Product with this name or SKU already exists"
 // @Failure 500 {object} utils.ErrorResponse "Failed to create product"
 // @Security BearerAuth
 // @Router /products [post]
@@ -26,26 +38,41 @@ import (
 //	   "name": "Product 3",
 //	   "price": 75,
 //	   "stock": 30,
-//	   "description": "A description of the product 3"
+//	   "description": "A description of the product 3",
+//	   "sku": "PRD-003"
 //	}
-func CreateProduct(c *gin.Context) {
-	var product models.Product
-	if err := c.ShouldBindJSON(&product); err != nil {
-		utils.RespondError(c, http.StatusBadRequest, "Invalid request data")
+func (h *ProductHandler) CreateProduct(c *gin.Context) {
+	var input models.Product
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
 		return
 	}
 
-	// Check if a product with the same name already exists
-	var existingProduct models.Product
-	if err := models.DB.Where("name = ?", product.Name).First(&existingProduct).Error; err == nil {
-		// Product with the same name already exists
-		utils.RespondError(c, http.StatusConflict, "Product with this name already exists")
+	product, err := h.products.Create(c.Request.Context(), actor(c), services.ProductInput{
+		Name:        input.Name,
+		Description: input.Description,
+		Price:       input.Price,
+		Stock:       input.Stock,
+		SKU:         input.SKU,
+	})
+	if errors.Is(err, services.ErrPriceNegative) {
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeValidationFailed, "Price must not be negative")
 		return
 	}
-
-	// Create the new product if no conflict
-	if err := models.DB.Create(&product).Error; err != nil {
-		utils.RespondError(c, http.StatusInternalServerError, "Failed to create product")
+	if errors.Is(err, services.ErrStockNegative) {
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeValidationFailed, "Stock must not be negative")
+		return
+	}
+	if errors.Is(err, services.ErrProductNameTaken) {
+		utils.RespondError(c, http.StatusConflict, utils.CodeProductNameTaken, "Product with this name already exists")
+		return
+	}
+	if errors.Is(err, services.ErrProductSKUTaken) {
+		utils.RespondError(c, http.StatusConflict, utils.CodeProductSKUTaken, "Product with this SKU already exists")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to create product")
 		return
 	}
 
@@ -60,10 +87,10 @@ func CreateProduct(c *gin.Context) {
 // @Success 200 {array} models.Product "List of products"
 // @Failure 500 {object} utils.ErrorResponse "Failed to fetch products"
 // @Router /products [get]
-func GetProducts(c *gin.Context) {
-	var products []models.Product
-	if err := models.DB.Find(&products).Error; err != nil {
-		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch products")
+func (h *ProductHandler) GetProducts(c *gin.Context) {
+	products, err := h.products.List(c.Request.Context())
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to fetch products")
 		return
 	}
 
@@ -81,42 +108,60 @@ func GetProducts(c *gin.Context) {
 // @Success 200 {object} models.Product "Product updated successfully"
 // @Failure 400 {object} utils.ErrorResponse "Invalid input data"
 // @Failure 404 {object} utils.ErrorResponse "Product not found"
+// @Failure 409 {object} utils.ErrorResponse "Product with this name or SKU already exists, or its stock was ordered meanwhile"
 // @Failure 500 {object} utils.ErrorResponse "Failed to update product"
 // @Security BearerAuth
 // @Router /products/{id} [put]
-func UpdateProduct(c *gin.Context) {
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	var input struct {
 		Name        string  `json:"name"`
 		Price       float64 `json:"price"`
 		Stock       int     `json:"stock"`
 		Description string  `json:"description"`
+		SKU         *string `json:"sku" binding:"omitempty,max=64"` // unchanged when left out
 	}
 
 	// Bind input JSON to struct
 	if err := c.ShouldBindJSON(&input); err != nil {
-		utils.RespondError(c, http.StatusBadRequest, "Invalid input data")
+		utils.RespondValidationError(c, err)
 		return
 	}
 
 	// Get product ID from URL parameter
-	productID := c.Param("id")
-
-	// Find the product by ID
-	var product models.Product
-	if err := models.DB.Where("id = ?", productID).First(&product).Error; err != nil {
-		utils.RespondError(c, http.StatusNotFound, "Product not found")
+	productID, ok := paramID(c, "id")
+	if !ok {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeProductNotFound, "Product not found")
 		return
 	}
 
-	// Update product fields
-	product.Name = input.Name
-	product.Price = input.Price
-	product.Stock = input.Stock
-	product.Description = input.Description
-
-	// Save the updated product to the database
-	if err := models.DB.Save(&product).Error; err != nil {
-		utils.RespondError(c, http.StatusInternalServerError, "Failed to update product")
+	product, err := h.products.Update(c.Request.Context(), actor(c), productID, services.ProductInput{
+		Name:        input.Name,
+		Description: input.Description,
+		Price:       input.Price,
+		Stock:       input.Stock,
+		SKU:         input.SKU,
+	})
+	switch {
+	case errors.Is(err, services.ErrProductNotFound):
+		utils.RespondError(c, http.StatusNotFound, utils.CodeProductNotFound, "Product not found")
+		return
+	case errors.Is(err, services.ErrPriceNegative):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeValidationFailed, "Price must not be negative")
+		return
+	case errors.Is(err, services.ErrStockNegative):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeValidationFailed, "Stock must not be negative")
+		return
+	case errors.Is(err, services.ErrInsufficientStock):
+		utils.RespondError(c, http.StatusConflict, utils.CodeInsufficientStock, "Stock was taken by orders placed during the update")
+		return
+	case errors.Is(err, services.ErrProductNameTaken):
+		utils.RespondError(c, http.StatusConflict, utils.CodeProductNameTaken, "Product with this name already exists")
+		return
+	case errors.Is(err, services.ErrProductSKUTaken):
+		utils.RespondError(c, http.StatusConflict, utils.CodeProductSKUTaken, "Product with this SKU already exists")
+		return
+	case err != nil:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to update product")
 		return
 	}
 
@@ -133,20 +178,21 @@ func UpdateProduct(c *gin.Context) {
 // @Failure 500 {object} utils.ErrorResponse "Failed to delete product"
 // @Security BearerAuth
 // @Router /products/{id} [delete]
-func DeleteProduct(c *gin.Context) {
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	// Get product ID from URL parameter
-	productID := c.Param("id")
-
-	// Find the product by ID
-	var product models.Product
-	if err := models.DB.Where("id = ?", productID).First(&product).Error; err != nil {
-		utils.RespondError(c, http.StatusNotFound, "Product not found")
+	productID, ok := paramID(c, "id")
+	if !ok {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeProductNotFound, "Product not found")
 		return
 	}
 
-	// Delete the product from the database
-	if err := models.DB.Delete(&product).Error; err != nil {
-		utils.RespondError(c, http.StatusInternalServerError, "Failed to delete product")
+	err := h.products.Delete(c.Request.Context(), actor(c), productID)
+	if errors.Is(err, services.ErrProductNotFound) {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeProductNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to delete product")
 		return
 	}
 
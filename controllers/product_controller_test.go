@@ -0,0 +1,366 @@
+package controllers_test
+
+import (
+	"ecommerce-api/models"
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// productPath returns the path of a product
+func productPath(product *models.Product) string {
+	return fmt.Sprintf("/api/v1/products/%d", product.ID)
+}
+
+func TestGetProducts(t *testing.T) {
+	env := testutil.New(t)
+	env.Product(models.Product{Name: "Mug"})
+	env.Product(models.Product{Name: "Cup"})
+
+	// The catalog is public
+	var products []models.Product
+	env.GET("/api/v1/products", "").AssertStatus(http.StatusOK).Data(&products)
+	if len(products) != 2 {
+		t.Errorf("got %d products, want 2", len(products))
+	}
+}
+
+func TestCreateProduct(t *testing.T) {
+	post := func(token string, body interface{}) testutil.Request {
+		return testutil.Request{Method: http.MethodPost, Path: "/api/v1/products", Token: token, Body: body}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "creates a product",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Admin()), gin.H{"name": "Mug", "price": 4.5, "stock": 3, "sku": "MUG-1"})
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var product models.Product
+				res.Data(&product)
+				if product.Name != "Mug" || product.Price != 4.5 || product.SKU == nil || *product.SKU != "MUG-1" {
+					t.Errorf("product = %+v", product)
+				}
+			},
+		},
+		{
+			Name: "accepts an API key with products:write",
+			Setup: func(env *testutil.Env) testutil.Request {
+				req := post("", gin.H{"name": "Mug", "price": 4.5})
+				req.APIKey = env.APIKey(env.Admin(), models.ScopeProductsWrite)
+				return req
+			},
+			Status: http.StatusOK,
+		},
+		{
+			Name: "rejects an API key with products:read",
+			Setup: func(env *testutil.Env) testutil.Request {
+				req := post("", gin.H{"name": "Mug", "price": 4.5})
+				req.APIKey = env.APIKey(env.Admin(), models.ScopeProductsRead)
+				return req
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeInsufficientScope,
+		},
+		{
+			Name: "is for admins only",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Customer()), gin.H{"name": "Mug", "price": 4.5})
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAdminRequired,
+		},
+		{
+			Name: "rejects a negative price",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Admin()), gin.H{"name": "Mug", "price": -1})
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeValidationFailed,
+		},
+		{
+			Name: "rejects a negative stock",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Admin()), gin.H{"name": "Mug", "stock": -1})
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeValidationFailed,
+		},
+		{
+			Name: "rejects a name in use",
+			Setup: func(env *testutil.Env) testutil.Request {
+				env.Product(models.Product{Name: "Mug"})
+				return post(env.Token(env.Admin()), gin.H{"name": "Mug", "price": 4.5})
+			},
+			Status: http.StatusConflict,
+			Code:   utils.CodeProductNameTaken,
+		},
+		{
+			Name: "rejects a SKU in use",
+			Setup: func(env *testutil.Env) testutil.Request {
+				sku := "MUG-1"
+				env.Product(models.Product{Name: "Mug", SKU: &sku})
+				return post(env.Token(env.Admin()), gin.H{"name": "Big mug", "sku": sku})
+			},
+			Status: http.StatusConflict,
+			Code:   utils.CodeProductSKUTaken,
+		},
+	})
+}
+
+func TestUpdateProduct(t *testing.T) {
+	put := func(env *testutil.Env, token string, body interface{}) testutil.Request {
+		product := env.Product(models.Product{Name: "Mug"})
+		return testutil.Request{Method: http.MethodPut, Path: productPath(product), Token: token, Body: body}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "updates a product",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return put(env, env.Token(env.Admin()), gin.H{"name": "Mug", "price": 6, "stock": 1})
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var product models.Product
+				res.Data(&product)
+				if product.Price != 6 || product.Stock != 1 {
+					t.Errorf("product = %+v, want price 6 and stock 1", product)
+				}
+			},
+		},
+		{
+			Name: "removes the SKU",
+			Setup: func(env *testutil.Env) testutil.Request {
+				sku := "MUG-1"
+				product := env.Product(models.Product{Name: "Mug", SKU: &sku})
+				return testutil.Request{Method: http.MethodPut, Path: productPath(product), Token: env.Token(env.Admin()), Body: gin.H{"name": "Mug", "sku": ""}}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var product models.Product
+				if err := env.DB.First(&product, "name = ?", "Mug").Error; err != nil {
+					t.Fatal(err)
+				}
+				if product.SKU != nil {
+					t.Errorf("SKU = %q, want none", *product.SKU)
+				}
+			},
+		},
+		{
+			Name: "is for admins only",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return put(env, env.Token(env.Customer()), gin.H{"name": "Mug", "price": 6})
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAdminRequired,
+		},
+		{
+			Name: "rejects a negative price",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return put(env, env.Token(env.Admin()), gin.H{"name": "Mug", "price": -6})
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeValidationFailed,
+		},
+		{
+			Name: "rejects the name of another product",
+			Setup: func(env *testutil.Env) testutil.Request {
+				env.Product(models.Product{Name: "Cup"})
+				return put(env, env.Token(env.Admin()), gin.H{"name": "Cup", "price": 6})
+			},
+			Status: http.StatusConflict,
+			Code:   utils.CodeProductNameTaken,
+		},
+		{
+			Name: "rejects an unknown product",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodPut, Path: "/api/v1/products/999", Token: env.Token(env.Admin()), Body: gin.H{"name": "Mug"}}
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeProductNotFound,
+		},
+	})
+}
+
+func TestDeleteProduct(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "deletes a product",
+			Setup: func(env *testutil.Env) testutil.Request {
+				product := env.Product(models.Product{})
+				return testutil.Request{Method: http.MethodDelete, Path: productPath(product), Token: env.Token(env.Admin())}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var products []models.Product
+				env.GET("/api/v1/products", "").AssertStatus(http.StatusOK).Data(&products)
+				if len(products) != 0 {
+					t.Errorf("products = %+v, want none", products)
+				}
+			},
+		},
+		{
+			Name: "is for admins only",
+			Setup: func(env *testutil.Env) testutil.Request {
+				product := env.Product(models.Product{})
+				return testutil.Request{Method: http.MethodDelete, Path: productPath(product), Token: env.Token(env.Customer())}
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAdminRequired,
+		},
+		{
+			Name: "rejects an unknown product",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodDelete, Path: "/api/v1/products/999", Token: env.Token(env.Admin())}
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeProductNotFound,
+		},
+	})
+}
+
+func TestImportProducts(t *testing.T) {
+	csv := func(token, query, body string) testutil.Request {
+		return testutil.Request{
+			Method: http.MethodPost,
+			Path:   "/api/v1/products/import" + query,
+			Token:  token,
+			Body:   body,
+			Header: http.Header{"Content-Type": {"text/csv"}},
+		}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "creates and updates products by SKU",
+			Setup: func(env *testutil.Env) testutil.Request {
+				sku := "MUG-1"
+				env.Product(models.Product{Name: "Mug", SKU: &sku, Price: 4})
+				return csv(env.Token(env.Admin()), "", "sku,name,price,stock\nMUG-1,Mug,5,10\nCUP-1,Cup,2,3\n")
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var result struct{ Created, Updated int }
+				res.Data(&result)
+				if result.Created != 1 || result.Updated != 1 {
+					t.Errorf("result = %+v, want 1 created and 1 updated", result)
+				}
+			},
+		},
+		{
+			Name: "changes nothing in a dry run",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return csv(env.Token(env.Admin()), "?dry_run=true", "name,price,stock\nCup,2,3\n")
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var count int64
+				env.DB.Model(&models.Product{}).Count(&count)
+				if count != 0 {
+					t.Errorf("%d products after a dry run, want 0", count)
+				}
+			},
+		},
+		{
+			Name: "imports nothing when a row is invalid",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return csv(env.Token(env.Admin()), "", "name,price,stock\nCup,2,3\nMug,NaN,1\n")
+			},
+			Status: http.StatusUnprocessableEntity,
+			Code:   utils.CodeImportRowsInvalid,
+		},
+		{
+			Name: "rejects an unknown mode",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return csv(env.Token(env.Admin()), "?mode=sometimes", "name,price,stock\n")
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeBadRequest,
+		},
+		{
+			Name: "rejects a file without the required columns",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return csv(env.Token(env.Admin()), "", "name\nCup\n")
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeImportFileInvalid,
+		},
+		{
+			Name: "is for admins only",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return csv(env.Token(env.Customer()), "", "name,price,stock\nCup,2,3\n")
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAdminRequired,
+		},
+	})
+}
+
+func TestExportProducts(t *testing.T) {
+	get := func(token, apiKey, query string) testutil.Request {
+		return testutil.Request{Method: http.MethodGet, Path: "/api/v1/products/export" + query, Token: token, APIKey: apiKey}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "exports CSV",
+			Setup: func(env *testutil.Env) testutil.Request {
+				env.Product(models.Product{Name: "Mug", Price: 4.5, Stock: 2})
+				return get(env.Token(env.Admin()), "", "")
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				if !strings.Contains(res.Body.String(), "Mug,,4.5,2") {
+					t.Errorf("export = %q, want the mug", res.Body.String())
+				}
+			},
+		},
+		{
+			Name: "exports JSON to an API key with products:read",
+			Setup: func(env *testutil.Env) testutil.Request {
+				env.Product(models.Product{Name: "Mug"})
+				return get("", env.APIKey(env.Admin(), models.ScopeProductsRead), "?format=json")
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var rows []struct{ Name string }
+				res.Decode(&rows)
+				if len(rows) != 1 || rows[0].Name != "Mug" {
+					t.Errorf("export = %s, want the mug", res.Body.String())
+				}
+			},
+		},
+		{
+			Name: "rejects an API key without products:read",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return get("", env.APIKey(env.Admin(), models.ScopeOrdersRead), "")
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeInsufficientScope,
+		},
+		{
+			Name: "rejects an unknown format",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return get(env.Token(env.Admin()), "", "?format=xml")
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeBadRequest,
+		},
+		{
+			Name: "is for admins only",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return get(env.Token(env.Customer()), "", "")
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAdminRequired,
+		},
+	})
+}
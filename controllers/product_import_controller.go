@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"ecommerce-api/catalog"
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxImportSize is the largest product file accepted by ImportProducts
+var MaxImportSize int64 = 64 << 20
+
+// ndjson is the content type of import progress streams
+const ndjson = "application/x-ndjson"
+
+// ImportProducts creates and updates products from a CSV or JSON file (admin only)
+// @Summary Import products
+// @Description Create and update products from a CSV or JSON file sent as the request body or as the "file" field of
+// @Description a multipart form. Rows are matched to products by SKU, then by name; unmatched rows create products.
+// @Description In atomic mode nothing is changed unless every row is valid; best-effort mode keeps the valid rows.
+// @Description With dry_run the file is checked and the changes are reported but not made. Clients that accept
+// @Description application/x-ndjson receive progress lines as the rows are processed, then the result.
+// @Tags Products
+// @Accept text/csv,application/json,multipart/form-data
+// @Produce json,application/x-ndjson
+// @Param format query string false "File format, csv or json (default: from the content type or file name)"
+// @Param mode query string false "atomic (default) or best-effort"
+// @Param dry_run query bool false "Report the changes without making them"
+// @Success 200 {object} services.ImportResult "Import result"
+// @Failure 400 {object} utils.ErrorResponse "Invalid options or unreadable file"
+// @Failure 413 {object} utils.ErrorResponse "File too large"
+// @Failure 422 {object} utils.ErrorResponse "Invalid rows; nothing was imported"
+// @Failure 500 {object} utils.ErrorResponse "Failed to import products"
+// @Security BearerAuth
+// @Router /products/import [post]
+func (h *ProductHandler) ImportProducts(c *gin.Context) {
+	mode, err := services.ParseImportMode(c.Query("mode"))
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeBadRequest, err.Error())
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	// The file is the body, or a field of a multipart form
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxImportSize)
+	body, name := io.Reader(c.Request.Body), ""
+	if c.ContentType() == "multipart/form-data" {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			respondImportFileError(c, fmt.Errorf("multipart form without a file field: %w", err))
+			return
+		}
+		defer file.Close()
+		body, name = file, header.Filename
+	}
+
+	format, err := importFormat(c, name)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeBadRequest, err.Error())
+		return
+	}
+	rows, err := catalog.NewReader(body, format)
+	if err != nil {
+		respondImportFileError(c, err)
+		return
+	}
+
+	opts := services.ImportOptions{Mode: mode, DryRun: dryRun}
+	if strings.Contains(c.GetHeader("Accept"), ndjson) {
+		h.streamImport(c, rows, opts)
+		return
+	}
+
+	result, err := h.products.Import(c.Request.Context(), actor(c), rows, opts)
+	if errors.Is(err, services.ErrImportUnreadable) {
+		respondImportFileError(c, err)
+		return
+	}
+	if err != nil {
+		utils.Logger(c.Request.Context()).Error("Product import failed", "error", err)
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to import products")
+		return
+	}
+	if !result.Applied && !result.DryRun {
+		apiErr := utils.NewAPIError(http.StatusUnprocessableEntity, utils.CodeImportRowsInvalid,
+			fmt.Sprintf("%d of %d rows are invalid, nothing was imported", result.Failed, result.Rows))
+		apiErr.Fields = make(map[string]string, len(result.Errors))
+		for _, rowErr := range result.Errors {
+			apiErr.Fields[fmt.Sprintf("line %d", rowErr.Line)] = rowErr.Message
+		}
+		utils.WriteError(c, apiErr)
+		return
+	}
+
+	message := "Products imported successfully"
+	if result.DryRun {
+		message = "Import checked, nothing was changed"
+	}
+	utils.RespondSuccess(c, message, result)
+}
+
+// ExportProducts streams the catalog as CSV or JSON (admin only)
+// @Summary Export products
+// @Description Download every product in the format read by the import endpoint. The catalog is written as it is
+// @Description read from the database, so exports of any size use little memory.
+// @Tags Products
+// @Produce text/csv,json
+// @Param format query string false "csv (default) or json"
+// @Failure 400 {object} utils.ErrorResponse "Unsupported format"
+// @Security BearerAuth
+// @Router /products/export [get]
+func (h *ProductHandler) ExportProducts(c *gin.Context) {
+	format, err := catalog.ParseFormat(c.DefaultQuery("format", string(catalog.CSV)))
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeBadRequest, err.Error())
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == catalog.JSON {
+		contentType = "application/json; charset=utf-8"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+	c.Status(http.StatusOK)
+
+	// Once the first bytes are sent an error can only cut the file short
+	writer, err := catalog.NewWriter(c.Writer, format)
+	if err == nil {
+		err = h.products.Each(c.Request.Context(), writer.Write)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		utils.Logger(c.Request.Context()).Error("Product export failed", "error", err)
+		c.Abort()
+	}
+}
+
+// streamImport runs an import and writes a line per progress report, then
+// {"result": ...} or {"error": ...}. The status is 200 whatever the outcome.
+func (h *ProductHandler) streamImport(c *gin.Context, rows catalog.Reader, opts services.ImportOptions) {
+	c.Header("Content-Type", ndjson)
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	opts.Progress = func(counts services.ImportCounts) {
+		encoder.Encode(gin.H{"progress": counts})
+		c.Writer.Flush()
+	}
+
+	result, err := h.products.Import(c.Request.Context(), actor(c), rows, opts)
+	if errors.Is(err, services.ErrImportUnreadable) {
+		encoder.Encode(gin.H{"error": utils.ErrorResponse{Status: "error", Code: utils.CodeImportFileInvalid, Message: err.Error()}})
+		return
+	}
+	if err != nil {
+		utils.Logger(c.Request.Context()).Error("Product import failed", "error", err)
+		encoder.Encode(gin.H{"error": utils.ErrorResponse{Status: "error", Code: utils.CodeInternal, Message: "Failed to import products"}})
+		return
+	}
+	encoder.Encode(gin.H{"result": result})
+}
+
+// importFormat returns the format named by the format parameter, the file
+// name or the content type
+func importFormat(c *gin.Context, name string) (catalog.Format, error) {
+	if format := c.Query("format"); format != "" {
+		return catalog.ParseFormat(format)
+	}
+	if format := catalog.FormatOf(name); format != "" {
+		return format, nil
+	}
+	switch mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type")); mediaType {
+	case "text/csv":
+		return catalog.CSV, nil
+	case "application/json":
+		return catalog.JSON, nil
+	}
+	return "", errors.New("unknown file format, send text/csv or application/json or set the format parameter")
+}
+
+// respondImportFileError responds to a file that cannot be imported at all
+func respondImportFileError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		utils.RespondError(c, http.StatusRequestEntityTooLarge, utils.CodeImportFileInvalid,
+			fmt.Sprintf("The file is larger than %d bytes", tooLarge.Limit))
+	default:
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeImportFileInvalid, err.Error())
+	}
+}
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"ecommerce-api/models"
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountHandler serves the /me endpoints of the authenticated user
+type AccountHandler struct {
+	users    *services.UserService
+	sessions *services.SessionService
+	mfa      *services.MFAService
+	privacy  *services.PrivacyService
+}
+
+// NewAccountHandler returns the account handlers for the user, session,
+// two-factor and privacy services
+func NewAccountHandler(users *services.UserService, sessions *services.SessionService, mfa *services.MFAService, privacy *services.PrivacyService) *AccountHandler {
+	return &AccountHandler{users: users, sessions: sessions, mfa: mfa, privacy: privacy}
+}
+
+// currentUser loads the user set by the AuthMiddleware, responding with 401
+// when it cannot be found
+func (h *AccountHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	user, err := h.users.Get(c.Request.Context(), c.GetUint("user_id"))
+	if err != nil {
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized user not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// respondNoUser answers a service error for the authenticated user, who may
+// have been deleted since the token was issued, and reports whether err was one
+func respondNoUser(c *gin.Context, err error) bool {
+	if !errors.Is(err, services.ErrUserNotFound) {
+		return false
+	}
+	utils.RespondError(c, http.StatusUnauthorized, utils.CodeUnauthorized, "Unauthorized user not found")
+	return true
+}
+
+// profileOf returns the fields of a user that are shown to the user themselves
+func profileOf(user models.User) gin.H {
+	return gin.H{
+		"id":                   user.ID,
+		"email":                user.Email,
+		"email_verified":       user.EmailVerified(),
+		"pending_email":        user.PendingEmail,
+		"name":                 user.Name,
+		"phone":                user.Phone,
+		"locale":               user.Locale,
+		"marketing_consent":    user.MarketingConsent,
+		"marketing_consent_at": user.MarketingConsentAt,
+		"two_factor_enabled":   user.MFAEnabled(),
+		"is_admin":             user.IsAdmin,
+		"created_at":           user.CreatedAt,
+	}
+}
+
+// GetProfile returns the profile of the authenticated user
+// @Summary Get my profile
+// @Description Return the profile of the authenticated user
+// @Tags Users
+// @Produce  json
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized user"
+// @Security BearerAuth
+// @Router /me [get]
+func (h *AccountHandler) GetProfile(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	utils.RespondSuccess(c, "Profile fetched successfully", profileOf(*user))
+}
+
+// UpdateProfile updates the profile of the authenticated user
+// @Summary Update my profile
+// @Description Update name, phone (E.164), locale (BCP 47) and marketing consent. Omitted fields are left unchanged.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Unauthorized user"
+// @Failure 500 {object} utils.ErrorResponse "Failed to update profile"
+// @Security BearerAuth
+// @Router /me [put]
+func (h *AccountHandler) UpdateProfile(c *gin.Context) {
+	var input struct {
+		Name             *string `json:"name" binding:"omitempty,max=100"`
+		Phone            *string `json:"phone" binding:"omitempty,e164"`
+		Locale           *string `json:"locale" binding:"omitempty,bcp47_language_tag"`
+		MarketingConsent *bool   `json:"marketing_consent"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	user, err := h.users.UpdateProfile(c.Request.Context(), c.GetUint("user_id"), services.ProfileInput{
+		Name:             input.Name,
+		Phone:            input.Phone,
+		Locale:           input.Locale,
+		MarketingConsent: input.MarketingConsent,
+	})
+	if respondNoUser(c, err) {
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to update profile")
+		return
+	}
+
+	utils.RespondSuccess(c, "Profile updated successfully", profileOf(*user))
+}
+
+// ChangeEmail starts a change of email address
+// @Summary Change my email address
+// @Description Send a verification link to the new address. The email is changed once the link is opened.
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 401 {object} utils.ErrorResponse "Password is incorrect"
+// @Failure 409 {object} utils.ErrorResponse "Email address is already in use"
+// @Failure 500 {object} utils.ErrorResponse "Failed to change email"
+// @Security BearerAuth
+// @Router /me/email [post]
+func (h *AccountHandler) ChangeEmail(c *gin.Context) {
+	var input struct {
+		NewEmail string `json:"new_email" binding:"required,email"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	err := h.users.ChangeEmail(c.Request.Context(), c.GetUint("user_id"), input.NewEmail, input.Password)
+	if respondNoUser(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrPasswordIncorrect):
+		utils.RespondError(c, http.StatusUnauthorized, utils.CodeInvalidPassword, "Password is incorrect")
+		return
+	case errors.Is(err, services.ErrSameEmail):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeBadRequest, "New email must differ from the current one")
+		return
+	case errors.Is(err, services.ErrEmailTaken):
+		utils.RespondError(c, http.StatusConflict, utils.CodeEmailTaken, "Email address is already in use")
+		return
+	case errors.Is(err, services.ErrMailNotSent):
+		utils.Logger(c.Request.Context()).Error("Failed to send verification email", "error", err)
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to send verification email")
+		return
+	case err != nil:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to change email")
+		return
+	}
+
+	utils.RespondSuccess(c, "Verification email sent to the new address", nil)
+}
+
+// GetSessions lists the active sessions of the authenticated user
+// @Summary List my sessions
+// @Description List the active login sessions (devices) of the authenticated user. last_used is the last login or token refresh.
+// @Tags Users
+// @Produce  json
+// @Failure 500 {object} utils.ErrorResponse "Failed to fetch sessions"
+// @Security BearerAuth
+// @Router /me/sessions [get]
+func (h *AccountHandler) GetSessions(c *gin.Context) {
+	sessions, err := h.sessions.List(c.Request.Context(), c.GetUint("user_id"))
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to fetch sessions")
+		return
+	}
+
+	currentSession := c.GetUint("session_id")
+	result := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, gin.H{
+			"id":         session.ID,
+			"created_at": session.CreatedAt,
+			"last_used":  session.UpdatedAt,
+			"expires_at": session.ExpiresAt,
+			"user_agent": session.UserAgent,
+			"ip":         session.IP,
+			"two_factor": session.MFA,
+			"current":    session.ID == currentSession,
+		})
+	}
+
+	utils.RespondSuccess(c, "Sessions fetched successfully", result)
+}
+
+// RevokeMySession signs out one of the authenticated user's sessions
+// @Summary Revoke one of my sessions
+// @Description Sign out a session (device) of the authenticated user
+// @Tags Users
+// @Param id path string true "Session ID"
+// @Failure 404 {object} utils.ErrorResponse "Session not found"
+// @Failure 500 {object} utils.ErrorResponse "Failed to revoke session"
+// @Security BearerAuth
+// @Router /me/sessions/{id} [delete]
+func (h *AccountHandler) RevokeMySession(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeSessionNotFound, "Session not found")
+		return
+	}
+
+	err := h.sessions.Revoke(c.Request.Context(), c.GetUint("user_id"), id)
+	if errors.Is(err, services.ErrSessionNotFound) {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeSessionNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to revoke session")
+		return
+	}
+
+	utils.RespondSuccess(c, "Session revoked successfully", nil)
+}
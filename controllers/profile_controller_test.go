@@ -0,0 +1,191 @@
+package controllers_test
+
+import (
+	"ecommerce-api/models"
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetProfile(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "returns the profile",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodGet, Path: "/api/v1/me", Token: env.Token(env.User(testutil.UserAttrs{Email: "me@example.com", Verified: true}))}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var profile struct {
+					Email         string `json:"email"`
+					EmailVerified bool   `json:"email_verified"`
+				}
+				res.Data(&profile)
+				if profile.Email != "me@example.com" || !profile.EmailVerified {
+					t.Errorf("profile = %+v", profile)
+				}
+			},
+		},
+		{
+			Name: "is not available to API keys",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodGet, Path: "/api/v1/me", APIKey: env.APIKey(env.Customer(), models.ScopeOrdersRead)}
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAPIKeyNotAllowed,
+		},
+		{
+			Name: "requires a token",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodGet, Path: "/api/v1/me"}
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeTokenMissing,
+		},
+	})
+}
+
+func TestUpdateProfile(t *testing.T) {
+	put := func(token string, body gin.H) testutil.Request {
+		return testutil.Request{Method: http.MethodPut, Path: "/api/v1/me", Token: token, Body: body}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "updates the given fields",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return put(env.Token(env.Customer()), gin.H{"name": "Ada", "phone": "+441234567890", "locale": "en-GB"})
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var profile struct {
+					Name   string `json:"name"`
+					Phone  string `json:"phone"`
+					Locale string `json:"locale"`
+				}
+				res.Data(&profile)
+				if profile.Name != "Ada" || profile.Phone != "+441234567890" || profile.Locale != "en-GB" {
+					t.Errorf("profile = %+v", profile)
+				}
+			},
+		},
+		{
+			Name: "rejects a phone number not in E.164 format",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return put(env.Token(env.Customer()), gin.H{"phone": "0123 456"})
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeValidationFailed,
+		},
+	})
+}
+
+func TestChangeEmail(t *testing.T) {
+	post := func(token, email, password string) testutil.Request {
+		return testutil.Request{Method: http.MethodPost, Path: "/api/v1/me/email", Token: token, Body: gin.H{"new_email": email, "password": password}}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "emails a verification link to the new address",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Customer()), "new@example.com", testutil.DefaultPassword)
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				token := env.Mail.Token("new@example.com")
+				if token == "" {
+					t.Fatal("no verification email")
+				}
+				env.GET("/api/v1/auth/verify-email?token="+token, "").AssertStatus(http.StatusOK)
+				var user models.User
+				env.DB.First(&user)
+				if user.Email != "new@example.com" {
+					t.Errorf("email = %q, want new@example.com", user.Email)
+				}
+			},
+		},
+		{
+			Name: "rejects a wrong password",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Customer()), "new@example.com", "wrong-password")
+			},
+			Status: http.StatusUnauthorized,
+			Code:   utils.CodeInvalidPassword,
+		},
+		{
+			Name: "rejects the current address",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				return post(env.Token(user), user.Email, testutil.DefaultPassword)
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeBadRequest,
+		},
+		{
+			Name: "rejects an address in use",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Customer()), env.Customer().Email, testutil.DefaultPassword)
+			},
+			Status: http.StatusConflict,
+			Code:   utils.CodeEmailTaken,
+		},
+	})
+}
+
+func TestSessions(t *testing.T) {
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "lists the sessions and marks the current one",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				env.Token(user)
+				return testutil.Request{Method: http.MethodGet, Path: "/api/v1/me/sessions", Token: env.Token(user)}
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var sessions []struct {
+					Current bool `json:"current"`
+				}
+				res.Data(&sessions)
+				if len(sessions) != 2 || sessions[0].Current == sessions[1].Current {
+					t.Errorf("sessions = %+v, want two with one current", sessions)
+				}
+			},
+		},
+		{
+			Name: "revokes a session",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				env.Token(user)
+				var session models.Session
+				env.DB.First(&session)
+				return testutil.Request{Method: http.MethodDelete, Path: fmt.Sprintf("/api/v1/me/sessions/%d", session.ID), Token: env.Token(user)}
+			},
+			Status: http.StatusOK,
+		},
+		{
+			Name: "does not revoke the session of another user",
+			Setup: func(env *testutil.Env) testutil.Request {
+				env.Token(env.Customer())
+				var session models.Session
+				env.DB.First(&session)
+				return testutil.Request{Method: http.MethodDelete, Path: fmt.Sprintf("/api/v1/me/sessions/%d", session.ID), Token: env.Token(env.Customer())}
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeSessionNotFound,
+		},
+		{
+			Name: "rejects an invalid session ID",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return testutil.Request{Method: http.MethodDelete, Path: "/api/v1/me/sessions/abc", Token: env.Token(env.Customer())}
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeSessionNotFound,
+		},
+	})
+}
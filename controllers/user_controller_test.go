@@ -0,0 +1,109 @@
+package controllers_test
+
+import (
+	"ecommerce-api/models"
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegisterUser(t *testing.T) {
+	post := func(body gin.H) testutil.Request {
+		return testutil.Request{Method: http.MethodPost, Path: "/api/v1/register", Body: body}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "creates an unverified user and emails a link",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(gin.H{"email": "new@example.com", "password": "secret-password", "is_admin": true})
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var user models.User
+				if err := env.DB.Where("email = ?", "new@example.com").First(&user).Error; err != nil {
+					t.Fatal(err)
+				}
+				if user.EmailVerified() {
+					t.Error("new user is verified")
+				}
+				if env.Mail.Token("new@example.com") == "" {
+					t.Error("no verification email")
+				}
+			},
+		},
+		{
+			Name: "rejects a registered email",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(gin.H{"email": env.Customer().Email, "password": "secret-password", "is_admin": true})
+			},
+			Status: http.StatusConflict,
+			Code:   utils.CodeEmailTaken,
+		},
+		{
+			Name: "rejects an invalid email",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(gin.H{"email": "not-an-email", "password": "secret-password", "is_admin": true})
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeValidationFailed,
+		},
+		{
+			Name: "rejects a short password",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(gin.H{"email": "new@example.com", "password": "abc", "is_admin": true})
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeValidationFailed,
+		},
+	})
+}
+
+func TestUnlockUser(t *testing.T) {
+	post := func(token string, id uint) testutil.Request {
+		return testutil.Request{Method: http.MethodPost, Path: fmt.Sprintf("/api/v1/admin/users/%d/unlock", id), Token: token}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "lets a locked user sign in again",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				for i := 0; i < env.Config.Auth.LoginMaxFailures; i++ {
+					env.POST("/api/v1/login", "", gin.H{"email": user.Email, "password": "wrong-password"})
+				}
+				env.POST("/api/v1/login", "", gin.H{"email": user.Email, "password": testutil.DefaultPassword}).
+					AssertError(http.StatusTooManyRequests, utils.CodeAccountLocked)
+				return post(env.Token(env.Admin()), user.ID)
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var user models.User
+				env.DB.Where("is_admin = ?", false).First(&user)
+				env.POST("/api/v1/login", "", gin.H{"email": user.Email, "password": testutil.DefaultPassword}).
+					AssertStatus(http.StatusOK)
+			},
+		},
+		{
+			Name: "rejects an unknown user",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Token(env.Admin()), 999)
+			},
+			Status: http.StatusNotFound,
+			Code:   utils.CodeUserNotFound,
+		},
+		{
+			Name: "is for admins only",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.Customer()
+				return post(env.Token(user), user.ID)
+			},
+			Status: http.StatusForbidden,
+			Code:   utils.CodeAdminRequired,
+		},
+	})
+}
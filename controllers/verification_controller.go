@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyEmail confirms the email address of a user
+// @Summary Verify email address
+// @Description Confirm an email address with the token sent after registration or an email change
+// @Tags Auth
+// @Produce  json
+// @Param token query string true "Verification token"
+// @Failure 400 {object} utils.ErrorResponse "Invalid or expired verification token"
+// @Failure 409 {object} utils.ErrorResponse "Email address is already in use"
+// @Failure 500 {object} utils.ErrorResponse "Failed to verify email"
+// @Router /auth/verify-email [get]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	err := h.users.VerifyEmail(c.Request.Context(), c.Query("token"))
+	switch {
+	case errors.Is(err, services.ErrVerificationTokenInvalid):
+		utils.RespondError(c, http.StatusBadRequest, utils.CodeVerificationTokenInvalid, "Invalid or expired verification token")
+		return
+	case errors.Is(err, services.ErrEmailTaken):
+		utils.RespondError(c, http.StatusConflict, utils.CodeEmailTaken, "Email address is already in use")
+		return
+	case err != nil:
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to verify email")
+		return
+	}
+
+	utils.RespondSuccess(c, "Email verified successfully", nil)
+}
+
+// ResendVerification sends a new verification email
+// @Summary Resend verification email
+// @Description Send a new verification link. The response is the same whether or not the email is registered; at most one email per account is sent per resend interval.
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Failure 400 {object} utils.ErrorResponse "Invalid request data"
+// @Failure 500 {object} utils.ErrorResponse "Failed to send verification email"
+// @Router /auth/resend-verification [post]
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondValidationError(c, err)
+		return
+	}
+
+	if err := h.users.ResendVerification(c.Request.Context(), input.Email); err != nil {
+		utils.Logger(c.Request.Context()).Error("Failed to send verification email", "error", err)
+		utils.RespondError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to send verification email")
+		return
+	}
+
+	utils.RespondSuccess(c, "If the account exists and is unverified, a verification email has been sent", nil)
+}
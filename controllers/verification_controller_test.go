@@ -0,0 +1,86 @@
+package controllers_test
+
+import (
+	"ecommerce-api/models"
+	"ecommerce-api/testutil"
+	"ecommerce-api/utils"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	get := func(token string) testutil.Request {
+		return testutil.Request{Method: http.MethodGet, Path: "/api/v1/auth/verify-email?token=" + url.QueryEscape(token)}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "verifies the address of a new user",
+			Setup: func(env *testutil.Env) testutil.Request {
+				user := env.User(testutil.UserAttrs{})
+				env.POST("/api/v1/auth/resend-verification", "", gin.H{"email": user.Email}).AssertStatus(http.StatusOK)
+				return get(env.Mail.Token(user.Email))
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				var user models.User
+				env.DB.First(&user)
+				if !user.EmailVerified() {
+					t.Error("email is not verified")
+				}
+			},
+		},
+		{
+			Name: "rejects an invalid token",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return get("not-a-token")
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeVerificationTokenInvalid,
+		},
+	})
+}
+
+func TestResendVerification(t *testing.T) {
+	post := func(email string) testutil.Request {
+		return testutil.Request{Method: http.MethodPost, Path: "/api/v1/auth/resend-verification", Body: gin.H{"email": email}}
+	}
+
+	testutil.Run(t, []testutil.Case{
+		{
+			Name: "emails an unverified user",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.User(testutil.UserAttrs{}).Email)
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				if len(env.Mail.Messages()) != 1 {
+					t.Errorf("sent %d emails, want 1", len(env.Mail.Messages()))
+				}
+			},
+		},
+		{
+			Name: "does not email a verified user",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post(env.Customer().Email)
+			},
+			Status: http.StatusOK,
+			Check: func(t *testing.T, env *testutil.Env, res *testutil.Response) {
+				if len(env.Mail.Messages()) != 0 {
+					t.Errorf("sent %d emails, want none", len(env.Mail.Messages()))
+				}
+			},
+		},
+		{
+			Name: "rejects an invalid email",
+			Setup: func(env *testutil.Env) testutil.Request {
+				return post("not-an-email")
+			},
+			Status: http.StatusBadRequest,
+			Code:   utils.CodeValidationFailed,
+		},
+	})
+}
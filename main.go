@@ -1,25 +1,176 @@
 package main
 
 import (
-	"ecommerce-api/models"
-	"ecommerce-api/routes"
+	"context"
+	"ecommerce-api/app"
+	"ecommerce-api/config"
+	"ecommerce-api/utils"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	// Initialize database
-	models.ConnectDatabase()
+	// Without a command, or with only flags, the server starts as it always has
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	os.Exit(run(command, args))
+}
+
+// run runs a command and returns the exit code
+func run(command string, args []string) int {
+	switch command {
+	case "serve":
+		return runServe(args)
+	case "migrate":
+		return runMigrate(args)
+	case "seed":
+		return runSeed(args)
+	case "user":
+		return runUser(args)
+	case "products":
+		return runProducts(args)
+	case "orders":
+		return runOrders(args)
+	case "help":
+		fmt.Print(usage)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+	return 2
+}
+
+// runServe implements "ecommerce-api serve" and returns the exit code
+func runServe(args []string) int {
+	// Load and validate the configuration before anything else
+	flagSet := flag.NewFlagSet("ecommerce-api serve", flag.ContinueOnError)
+	flagSet.Usage = func() {
+		fmt.Fprint(os.Stderr, usage, "\nflags:\n")
+		flagSet.PrintDefaults()
+	}
+	cfg, code, ok := loadConfig(flagSet, args)
+	if !ok {
+		return code
+	}
+
+	// Structured logging, configured first so everything below uses it
+	if err := utils.InitLogger(cfg.Log); err != nil {
+		log.Fatal("Failed to initialize logger: ", err)
+	}
+	slog.Debug("Configuration loaded", "config", cfg)
+
+	// Tracing, before the database so its queries are traced
+	shutdownTracing, err := utils.InitTracing(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("Failed to initialize tracing", err)
+	}
+
+	// Connect the database and wire the API from the configuration
+	application, err := app.New(context.Background(), cfg, app.Options{})
+	if err != nil {
+		fatal("Failed to initialize the API", err)
+	}
+	router := application.Router
 
-	// Auto-migrate database models
-	models.DB.AutoMigrate(&models.User{}, &models.Product{}, &models.Order{}, &models.OrderItem{})
+	// Prometheus metrics, on the API port or on a separate admin port
+	if sqlDB, err := application.DB.DB(); err == nil {
+		if err := utils.RegisterDBStats(sqlDB, cfg.Database.Name); err != nil {
+			fatal("Failed to register database metrics", err)
+		}
+	}
+	metrics := utils.MetricsHandler(cfg.Metrics.Token)
+	servers := []*http.Server{newServer(cfg.Server, cfg.Server.Addr, router)}
+	if cfg.Metrics.Addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics)
+		servers = append(servers, newServer(cfg.Server, cfg.Metrics.Addr, mux))
+	} else {
+		router.GET("/metrics", gin.WrapH(metrics))
+	}
 
-	// Set up routes
-	router := gin.Default()
-	routes.SetupRoutes(router)
-	router.Use(cors.Default())
+	// Serve until SIGINT/SIGTERM or until a server fails
+	certFile, keyFile := cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			slog.Info("HTTP server listening", "addr", server.Addr, "tls", certFile != "")
+			var err error
+			if certFile != "" {
+				err = server.ListenAndServeTLS(certFile, keyFile)
+			} else {
+				err = server.ListenAndServe()
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}(server)
+	}
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received")
+	case err := <-serverErr:
+		slog.Error("HTTP server failed", "error", err)
+		exitCode = 1
+	}
+	// A second signal terminates immediately
+	stop()
+
+	// Fail readiness first and give load balancers time to notice
+	application.Health.SetDraining(true)
+	time.Sleep(cfg.Server.ShutdownDrainDelay)
+
+	// Then finish in-flight requests, flush spans and close the pool, in that order
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("HTTP server did not shut down cleanly", "addr", server.Addr, "error", err)
+			exitCode = 1
+		}
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	if err := application.Close(); err != nil {
+		slog.Error("Failed to close database", "error", err)
+	}
+	slog.Info("Shutdown complete")
+	return exitCode
+}
+
+// newServer builds an HTTP server with the configured timeouts and limits
+func newServer(cfg config.ServerConfig, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
 
-	// Start server
-	router.Run(":8080") // Run on port 8080
+// fatal logs a startup error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -1,21 +1,44 @@
 package middlewares
 
 import (
+	"ecommerce-api/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AdminMiddleware checks if the user is an admin
-func AdminMiddleware() gin.HandlerFunc {
+// AdminMiddleware checks if the user is an admin. API key callers are never
+// admins; they are let through only when the key holds every listed scope.
+// requireMFA rejects admins whose session was not established with a second
+// factor.
+func AdminMiddleware(requireMFA bool, scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// API keys are authorized by their scopes alone
+		if _, isAPIKey := c.Get("api_key_id"); isAPIKey {
+			if len(scopes) == 0 {
+				utils.AbortWithError(c, http.StatusForbidden, utils.CodeAdminRequired, "Access forbidden: Admins only")
+				return
+			}
+			if !hasScopes(c, scopes) {
+				utils.AbortWithError(c, http.StatusForbidden, utils.CodeInsufficientScope, "Access forbidden: API key lacks the required scope")
+				return
+			}
+			c.Next()
+			return
+		}
+
 		// Retrieve the `is_admin` value set in the JWT middleware or context
 		isAdmin, exists := c.Get("is_admin")
 
 		// Check if the value exists and is a boolean
 		if !exists || isAdmin == nil || !isAdmin.(bool) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Access forbidden: Admins only"})
-			c.Abort() // Stop the request from proceeding further
+			utils.AbortWithError(c, http.StatusForbidden, utils.CodeAdminRequired, "Access forbidden: Admins only")
+			return
+		}
+
+		// Admin sessions must have passed two-factor authentication when enforced
+		if requireMFA && !c.GetBool("mfa") {
+			utils.AbortWithError(c, http.StatusForbidden, utils.CodeMFARequired, "Two-factor authentication required for admin access")
 			return
 		}
 
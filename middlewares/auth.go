@@ -1,70 +1,63 @@
 package middlewares
 
 import (
-	"fmt"
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
 	"net/http"
-	"os"
 	"strings"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware validates the Bearer access token, or an X-API-Key header
+// for server-to-server integrations, and stores the caller in the context
+func AuthMiddleware(tokens *utils.TokenService, sessions *services.SessionService, keys *services.APIKeyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// API keys are an alternative to user tokens
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			if authenticateAPIKey(c, keys, apiKey) {
+				c.Next()
+			}
+			return
+		}
+
 		// Extract the Authorization header
 		authHeader := c.GetHeader("Authorization")
-		// fmt.Println("Authorization Header:", authHeader) // Debug log
 
 		// Check if Authorization header is missing or does not start with "Bearer "
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
-			c.Abort()
-			return
-		}
-
-		// Extract JWT secret key from environment variables
-		key := os.Getenv("JWT_SECRET")
-		if key == "" {
-			fmt.Println("Error: JWT_SECRET environment variable is missing.") // Debug log
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server configuration error"})
-			c.Abort()
+			utils.AbortWithError(c, http.StatusUnauthorized, utils.CodeTokenMissing, "Authorization token required")
 			return
 		}
 
 		// Remove the "Bearer " prefix from the token
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-		// fmt.Println("Token String:", tokenStr) // Debug log
 
-		// Parse the token using the secret key
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			// Check if the token's signing method is valid
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(key), nil
-		})
+		// Verify signature, expiry, issuer and audience
+		claims, err := tokens.VerifyJWT(tokenStr)
+		if err != nil {
+			utils.AbortWithError(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid token")
+			return
+		}
 
-		// Handle token parsing errors
+		// Reject tokens whose login session has been revoked (logout, reuse detection)
+		revoked, err := sessions.Revoked(c.Request.Context(), claims.SessionID)
 		if err != nil {
-			fmt.Println("Token Parse Error:", err) // Debug log
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			utils.AbortWithError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to validate session")
+			return
+		}
+		if revoked {
+			utils.AbortWithError(c, http.StatusUnauthorized, utils.CodeTokenRevoked, "Token has been revoked")
 			return
 		}
 
-		// Extract user info from the token (assuming `is_admin` is part of the token claims)
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			// Set the user ID and is_admin in the context
-			c.Set("user_id", claims["user_id"])
-			c.Set("is_admin", claims["is_admin"]) // Set is_admin value in context
+		// Set the user ID, is_admin and session in the context
+		c.Set("user_id", claims.UserID)
+		c.Set("is_admin", claims.IsAdmin)
+		c.Set("session_id", claims.SessionID)
+		c.Set("mfa", claims.MFA)
+		c.Set("claims", claims)
 
-			// Proceed to the next handler
-			c.Next()
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-		}
 		// Proceed to the next middleware or handler
 		c.Next()
 	}
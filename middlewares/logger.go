@@ -0,0 +1,104 @@
+package middlewares
+
+import (
+	"ecommerce-api/utils"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// Incoming request IDs are kept only if they are short and harmless to log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// RequestID accepts the caller's X-Request-ID or generates one, echoes it in
+// the response and attaches a logger carrying it to the request context
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = utils.NewRequestID()
+		}
+
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+
+		logger := slog.Default().With("request_id", id)
+		c.Request = c.Request.WithContext(utils.WithLogger(c.Request.Context(), logger))
+		c.Next()
+	}
+}
+
+// AccessLog logs one line per request once it has been handled. The query
+// string is left out as it may carry tokens.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		}
+		if userID, ok := c.Get("user_id"); ok {
+			attrs = append(attrs, slog.Any("user_id", userID))
+		}
+		if keyID, ok := c.Get("api_key_id"); ok {
+			attrs = append(attrs, slog.Any("api_key_id", keyID))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		ctx := c.Request.Context()
+		utils.Logger(ctx).LogAttrs(ctx, level, "request", attrs...)
+	}
+}
+
+// ProblemTypes sets the base URL of the RFC 7807 problem types returned by
+// the request's error responses
+func ProblemTypes(baseURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		utils.SetProblemTypeBaseURL(c, baseURL)
+		c.Next()
+	}
+}
+
+// Recovery turns panics into a logged 500 error response
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				ctx := c.Request.Context()
+				utils.Logger(ctx).ErrorContext(ctx, "panic recovered",
+					"panic", recovered, "stack", string(debug.Stack()))
+				if !c.Writer.Written() {
+					utils.AbortWithError(c, http.StatusInternalServerError, utils.CodeInternal, "Internal server error")
+					return
+				}
+				c.Abort()
+			}
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"ecommerce-api/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records the count and latency of every request. Requests that match
+// no route share one label value to keep the number of series bounded.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		utils.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		utils.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,167 @@
+package middlewares
+
+import (
+	"ecommerce-api/utils"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token, when not allowed
+}
+
+// RateLimitStore keeps the token buckets. Implementations must be safe for
+// concurrent use; a shared store (e.g. Redis) makes limits global across instances.
+type RateLimitStore interface {
+	Take(key string, limit int, period time.Duration) (RateLimitResult, error)
+}
+
+// RateLimits is the store used by policies that do not set their own
+var RateLimits RateLimitStore = NewMemoryRateLimitStore()
+
+// RateLimitKeyFunc identifies the client a request is counted against
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// RateLimitPolicy allows Limit requests per Period for each key, with bursts
+// of up to Limit requests
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+	Key    RateLimitKeyFunc
+	Store  RateLimitStore
+}
+
+// KeyByIP counts requests per client IP
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUser counts requests per authenticated user, falling back to the client IP
+func KeyByUser(c *gin.Context) string {
+	if userID, ok := c.Get("user_id"); ok {
+		return fmt.Sprintf("user:%v", userID)
+	}
+	return KeyByIP(c)
+}
+
+// KeyByAPIKey counts requests per API key, falling back to the user and then the client IP
+func KeyByAPIKey(c *gin.Context) string {
+	if keyID, ok := c.Get("api_key_id"); ok {
+		return fmt.Sprintf("key:%v", keyID)
+	}
+	return KeyByUser(c)
+}
+
+// RateLimit enforces a policy and sets the RateLimit-* headers
+// (draft-ietf-httpapi-ratelimit-headers) on every response
+func RateLimit(policy RateLimitPolicy) gin.HandlerFunc {
+	if policy.Key == nil {
+		policy.Key = KeyByIP
+	}
+	policyHeader := fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Period.Seconds()))
+
+	return func(c *gin.Context) {
+		store := policy.Store
+		if store == nil {
+			store = RateLimits
+		}
+
+		result, err := store.Take(policy.Name+"|"+policy.Key(c), policy.Limit, policy.Period)
+		if err != nil {
+			// Fail open: an unavailable store must not take the API down
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", policyHeader)
+		c.Header("RateLimit-Limit", fmt.Sprint(policy.Limit))
+		c.Header("RateLimit-Remaining", fmt.Sprint(result.Remaining))
+		c.Header("RateLimit-Reset", fmt.Sprint(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", fmt.Sprint(ceilSeconds(result.RetryAfter)))
+			utils.AbortWithError(c, http.StatusTooManyRequests, utils.CodeTooManyRequests, "Too many requests, please slow down")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// MemoryRateLimitStore keeps token buckets in process memory
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket), lastSweep: time.Now()}
+}
+
+// Take refills the bucket for the elapsed time and consumes one token
+func (s *MemoryRateLimitStore) Take(key string, limit int, period time.Duration) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	capacity := float64(limit)
+	perToken := period / time.Duration(limit)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, updated: now, period: period}
+		s.buckets[key] = bucket
+	} else {
+		elapsed := now.Sub(bucket.updated)
+		bucket.tokens = math.Min(capacity, bucket.tokens+elapsed.Seconds()/perToken.Seconds())
+		bucket.updated = now
+	}
+
+	result := RateLimitResult{}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - bucket.tokens) * float64(perToken))
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = time.Duration((capacity - bucket.tokens) * float64(perToken))
+	return result, nil
+}
+
+// sweep drops buckets that have refilled completely; callers must hold s.mu
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updated) > bucket.period {
+			delete(s.buckets, key)
+		}
+	}
+}
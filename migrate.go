@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"ecommerce-api/migrations"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `usage: ecommerce-api migrate <command> [flags]
+
+commands:
+  up       apply pending migrations (--to VERSION stops after VERSION)
+  down     revert applied migrations (--steps N, default 1)
+  status   list migrations and when they were applied
+
+up and down accept --dry-run to print the SQL instead of running it. The
+configuration flags of the server are accepted as well, see -h.
+`
+
+// runMigrate implements "ecommerce-api migrate" and returns the exit code
+func runMigrate(args []string) int {
+	command, ok := subcommand(args, "up", "down", "status")
+	if !ok {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	flagSet := flag.NewFlagSet("ecommerce-api migrate "+command, flag.ContinueOnError)
+	dryRun := flagSet.Bool("dry-run", false, "print the SQL instead of running it")
+	to := flagSet.Int64("to", 0, "apply migrations up to this `version` only (up)")
+	steps := flagSet.Int("steps", 1, "number of migrations to revert (down)")
+	cfg, code, ok := loadConfig(flagSet, args[1:])
+	if !ok {
+		return code
+	}
+
+	db, closeDB, err := connect(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeDB()
+
+	ctx, stop := commandContext()
+	defer stop()
+
+	migrator := migrations.New(db)
+	migrator.DryRun = *dryRun
+	switch command {
+	case "up":
+		var n int
+		n, err = migrator.Up(ctx, *to)
+		if err == nil && !*dryRun {
+			fmt.Printf("%d migration(s) applied\n", n)
+		}
+	case "down":
+		var n int
+		n, err = migrator.Down(ctx, *steps)
+		if err == nil && !*dryRun {
+			fmt.Printf("%d migration(s) reverted\n", n)
+		}
+	case "status":
+		err = printMigrationStatus(ctx, migrator)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// printMigrationStatus lists every migration with its applied time
+func printMigrationStatus(ctx context.Context, migrator *migrations.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = status.AppliedAt.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, applied)
+	}
+	return w.Flush()
+}
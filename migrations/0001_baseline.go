@@ -0,0 +1,185 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The schema as it was when versioned migrations were introduced. On a
+// database created by the former AutoMigrate at startup this is a no-op.
+
+type baselineUser struct {
+	gorm.Model
+	Email              string `gorm:"unique;not null"`
+	Password           string `gorm:"not null"`
+	IsAdmin            bool   `gorm:"default:false"`
+	Name               string `gorm:"size:100"`
+	Phone              string `gorm:"size:32"`
+	Locale             string `gorm:"size:16;default:'en'"`
+	MarketingConsent   bool   `gorm:"default:false"`
+	MarketingConsentAt *time.Time
+	EmailVerifiedAt    *time.Time
+	VerificationSentAt *time.Time
+	PendingEmail       string `gorm:"size:255"`
+	TOTPSecret         string `gorm:"size:64"`
+	TOTPEnabledAt      *time.Time
+	TOTPLastStep       int64
+	FailedLoginCount   int `gorm:"default:0"`
+	LastFailedLoginAt  *time.Time
+	LockedUntil        *time.Time
+	AnonymizedAt       *time.Time
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineRecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;index"`
+	CodeHash string `gorm:"size:64;not null;index"`
+	UsedAt   *time.Time
+}
+
+func (baselineRecoveryCode) TableName() string { return "recovery_codes" }
+
+type baselineProduct struct {
+	gorm.Model
+	Name        string  `gorm:"not null"`
+	Description string  `gorm:"type:text"`
+	Price       float64 `gorm:"not null"`
+	Stock       int     `gorm:"not null"`
+}
+
+func (baselineProduct) TableName() string { return "products" }
+
+type baselineOrder struct {
+	gorm.Model
+	UserID uint                `gorm:"not null"`
+	Status string              `gorm:"default:'Pending'"`
+	Total  float64             `gorm:"not null"`
+	Items  []baselineOrderItem `gorm:"foreignKey:OrderID"`
+}
+
+func (baselineOrder) TableName() string { return "orders" }
+
+type baselineOrderItem struct {
+	gorm.Model
+	OrderID   uint
+	ProductID uint
+	Quantity  int
+}
+
+func (baselineOrderItem) TableName() string { return "order_items" }
+
+type baselineSession struct {
+	gorm.Model
+	UserID    uint      `gorm:"not null;index"`
+	UserAgent string    `gorm:"size:255"`
+	IP        string    `gorm:"size:64"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+	MFA       bool `gorm:"default:false"`
+}
+
+func (baselineSession) TableName() string { return "sessions" }
+
+type baselineRefreshToken struct {
+	gorm.Model
+	SessionID uint      `gorm:"not null;index"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+func (baselineRefreshToken) TableName() string { return "refresh_tokens" }
+
+type baselinePasswordResetToken struct {
+	gorm.Model
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+func (baselinePasswordResetToken) TableName() string { return "password_reset_tokens" }
+
+type baselineUserIdentity struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;index"`
+	Provider string `gorm:"size:64;not null;uniqueIndex:idx_identity_provider_subject"`
+	Subject  string `gorm:"size:255;not null;uniqueIndex:idx_identity_provider_subject"`
+	Email    string `gorm:"size:255"`
+}
+
+func (baselineUserIdentity) TableName() string { return "user_identities" }
+
+type baselineOAuthState struct {
+	gorm.Model
+	State        string    `gorm:"size:64;uniqueIndex;not null"`
+	Provider     string    `gorm:"size:64;not null"`
+	Nonce        string    `gorm:"size:64;not null"`
+	CodeVerifier string    `gorm:"size:128;not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+}
+
+func (baselineOAuthState) TableName() string { return "o_auth_states" }
+
+type baselineAPIKey struct {
+	gorm.Model
+	Name        string `gorm:"size:100;not null"`
+	Prefix      string `gorm:"size:16;uniqueIndex;not null"`
+	KeyHash     string `gorm:"size:64;not null"`
+	Scopes      string `gorm:"size:255"`
+	UserID      uint   `gorm:"not null;index"`
+	CreatedByID uint
+	ExpiresAt   *time.Time
+	LastUsedAt  *time.Time
+	RevokedAt   *time.Time
+}
+
+func (baselineAPIKey) TableName() string { return "api_keys" }
+
+type baselineDataRequest struct {
+	gorm.Model
+	UserID        uint   `gorm:"not null;index"`
+	Type          string `gorm:"size:16;not null"`
+	RequestedByID uint   `gorm:"not null"`
+	IP            string `gorm:"size:45"`
+}
+
+func (baselineDataRequest) TableName() string { return "data_requests" }
+
+type baselineAuditLog struct {
+	ID         uint      `gorm:"primarykey"`
+	CreatedAt  time.Time `gorm:"index"`
+	ActorID    uint      `gorm:"not null;index"`
+	APIKeyID   *uint
+	Action     string `gorm:"size:64;not null;index"`
+	TargetType string `gorm:"size:32;not null;index:idx_audit_target"`
+	TargetID   uint   `gorm:"not null;index:idx_audit_target"`
+	Changes    string `gorm:"type:text"`
+	IP         string `gorm:"size:45"`
+	RequestID  string `gorm:"size:64;index"`
+}
+
+func (baselineAuditLog) TableName() string { return "audit_logs" }
+
+var baselineTables = []interface{}{
+	&baselineUser{}, &baselineProduct{}, &baselineOrder{}, &baselineOrderItem{},
+	&baselineSession{}, &baselineRefreshToken{}, &baselinePasswordResetToken{}, &baselineRecoveryCode{},
+	&baselineUserIdentity{}, &baselineOAuthState{}, &baselineAPIKey{}, &baselineDataRequest{}, &baselineAuditLog{},
+}
+
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(baselineTables...)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(baselineTables...)
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// Products get an optional stock keeping unit, unique when set, which bulk
+// imports match products by.
+
+type productSKU struct {
+	SKU *string `gorm:"size:64;uniqueIndex"`
+}
+
+func (productSKU) TableName() string { return "products" }
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "product_sku",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&productSKU{}, "SKU"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&productSKU{}, "SKU")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(&productSKU{}, "SKU"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&productSKU{}, "SKU")
+		},
+	})
+}
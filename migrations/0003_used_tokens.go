@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Single-use signed tokens, such as the two-factor login challenge, are
+// recorded by their ID once presented.
+
+type usedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+func (usedToken) TableName() string { return "used_tokens" }
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "used_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&usedToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&usedToken{})
+		},
+	})
+}
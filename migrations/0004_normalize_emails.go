@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Email addresses are stored in lower case so that lookups can match them
+// exactly. Accounts that differ from another one only in letter case have to
+// be merged or renamed by hand before this migration can run.
+
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "normalize_emails",
+		Up: func(tx *gorm.DB) error {
+			var users []struct {
+				ID    uint
+				Email string
+			}
+			// Soft-deleted rows are included: they hold on to their address
+			if err := tx.Table("users").Select("id", "email").Find(&users).Error; err != nil {
+				return err
+			}
+
+			owners := make(map[string][]uint, len(users))
+			for _, user := range users {
+				email := strings.ToLower(strings.TrimSpace(user.Email))
+				owners[email] = append(owners[email], user.ID)
+			}
+			for email, ids := range owners {
+				if len(ids) > 1 {
+					return fmt.Errorf("users %v share the email address %s in different letter case", ids, email)
+				}
+			}
+
+			for _, user := range users {
+				email := strings.ToLower(strings.TrimSpace(user.Email))
+				if email == user.Email {
+					continue
+				}
+				if err := tx.Table("users").Where("id = ?", user.ID).Update("email", email).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			// The original letter case is gone; lower-case addresses work either way
+			return nil
+		},
+	})
+}
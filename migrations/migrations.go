@@ -0,0 +1,339 @@
+// Package migrations evolves the database schema through ordered, versioned
+// migrations written as Go functions. Applied versions are recorded in the
+// schema_migrations table.
+//
+// Each migration runs in a transaction together with its schema_migrations
+// row. PostgreSQL and SQLite roll DDL back on failure; MySQL commits DDL
+// implicitly, so a migration that fails halfway there needs manual repair.
+//
+// A migration must never change once released: later changes go into a new
+// migration. Migrations therefore declare their own structs instead of using
+// the models package, whose types follow the latest schema.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// registry holds the migrations declared by this package, see register
+var registry []Migration
+
+// register adds a migration; each migration file calls it from init
+func register(m Migration) {
+	registry = append(registry, m)
+}
+
+// All returns the migrations of this package ordered by version
+func All() []Migration {
+	migrations := append([]Migration(nil), registry...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// SchemaMigrationLock is held by the instance running migrations. It has at
+// most one row.
+type SchemaMigrationLock struct {
+	ID       uint `gorm:"primaryKey;autoIncrement:false"`
+	Owner    string
+	LockedAt time.Time
+}
+
+// Status describes a migration and when it was applied, if it was
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// ErrLocked is returned when another instance holds the migration lock for
+// longer than LockTimeout
+var ErrLocked = errors.New("migrations are locked by another instance")
+
+// Migrator applies and reverts migrations on a database
+type Migrator struct {
+	DB         *gorm.DB
+	Migrations []Migration
+
+	// DryRun prints the SQL of each pending migration to Out instead of
+	// running it. Statements a migration decides on by reading the database
+	// are computed against its current state.
+	DryRun bool
+	Out    io.Writer
+
+	// LockTimeout bounds the wait for another instance's lock; a lock older
+	// than StaleLockAfter is considered abandoned and taken over
+	LockTimeout    time.Duration
+	StaleLockAfter time.Duration
+}
+
+// New returns a migrator for all migrations of this package
+func New(db *gorm.DB) *Migrator {
+	return &Migrator{
+		DB:             db,
+		Migrations:     All(),
+		Out:            os.Stdout,
+		LockTimeout:    time.Minute,
+		StaleLockAfter: 15 * time.Minute,
+	}
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied, in order
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Check is a health check that fails while migrations are pending
+func (m *Migrator) Check(ctx context.Context) error {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d migration(s) pending, next is %d_%s", len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
+
+// Up applies pending migrations up to and including target, or all of them
+// when target is 0. It returns the number of migrations applied.
+func (m *Migrator) Up(ctx context.Context, target int64) (int, error) {
+	count := 0
+	err := m.locked(ctx, func() error {
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range pending {
+			if target != 0 && migration.Version > target {
+				break
+			}
+			if err := m.run(ctx, migration, true); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts the last steps applied migrations, newest first. It returns
+// the number of migrations reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := m.locked(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.Migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := m.Migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("migration %d_%s cannot be reverted", migration.Version, migration.Name)
+			}
+			if err := m.run(ctx, migration, false); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// run applies or reverts one migration and records it in the same transaction
+func (m *Migrator) run(ctx context.Context, migration Migration, up bool) error {
+	direction, step := "down", migration.Down
+	if up {
+		direction, step = "up", migration.Up
+	}
+
+	if m.DryRun {
+		fmt.Fprintf(m.Out, "-- %d_%s (%s)\n", migration.Version, migration.Name, direction)
+		recorder := &sqlRecorder{Interface: logger.Discard, out: m.Out}
+		tx := m.DB.WithContext(ctx).Session(&gorm.Session{DryRun: true, Logger: recorder})
+		if err := step(tx); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := step(tx); err != nil {
+			return err
+		}
+		if up {
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+		}
+		return tx.Delete(&SchemaMigration{}, migration.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s (%s): %w", migration.Version, migration.Name, direction, err)
+	}
+	fmt.Fprintf(m.Out, "%s %d_%s (%s)\n", direction, migration.Version, migration.Name, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// applied reads schema_migrations, which may not exist yet
+func (m *Migrator) applied(ctx context.Context) (map[int64]SchemaMigration, error) {
+	db := m.DB.WithContext(ctx)
+	applied := map[int64]SchemaMigration{}
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// locked runs fn while holding the migration lock. A dry run only reads and
+// takes no lock.
+func (m *Migrator) locked(ctx context.Context, fn func() error) error {
+	if m.DryRun {
+		return fn()
+	}
+
+	db := m.DB.WithContext(ctx)
+	if err := createTables(db); err != nil {
+		return fmt.Errorf("create migration tables: %w", err)
+	}
+
+	owner := lockOwner()
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		// A failed insert is the expected outcome while the lock is held
+		lock := SchemaMigrationLock{ID: 1, Owner: owner, LockedAt: time.Now().UTC()}
+		err := db.Session(&gorm.Session{Logger: logger.Discard}).Create(&lock).Error
+		if err == nil {
+			break
+		}
+
+		// Someone else holds it: take over an abandoned lock, else wait
+		var held SchemaMigrationLock
+		if db.First(&held, 1).Error != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if time.Since(held.LockedAt) > m.StaleLockAfter {
+			db.Where("id = ? AND locked_at = ?", 1, held.LockedAt).Delete(&SchemaMigrationLock{})
+			continue
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w (%s since %s)", ErrLocked, held.Owner, held.LockedAt.Format(time.RFC3339))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+	defer db.Where("id = ? AND owner = ?", 1, owner).Delete(&SchemaMigrationLock{})
+
+	return fn()
+}
+
+// createTables creates schema_migrations and the lock table. Instances
+// starting together may race to create them, which is not an error.
+func createTables(db *gorm.DB) error {
+	tables := []interface{}{&SchemaMigration{}, &SchemaMigrationLock{}}
+	err := db.Session(&gorm.Session{Logger: logger.Discard}).AutoMigrate(tables...)
+	if err == nil {
+		return nil
+	}
+	for _, table := range tables {
+		if !db.Migrator().HasTable(table) {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockOwner identifies this process in the lock row
+func lockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// sqlRecorder prints the statements of a dry run. The reads a migration
+// makes to decide what to do are not printed.
+type sqlRecorder struct {
+	logger.Interface
+	out io.Writer
+}
+
+// gormPrinter is the logger through which AutoMigrate prints its statements
+// to stdout itself in a dry run before passing them on
+const gormPrinter = "gorm.io/gorm/migrator.(*printSQLLogger).Trace"
+
+func (r *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	if pc, _, _, ok := runtime.Caller(1); ok && runtime.FuncForPC(pc).Name() == gormPrinter {
+		return
+	}
+	sql, _ := fc()
+	switch strings.ToUpper(strings.SplitN(strings.TrimSpace(sql), " ", 2)[0]) {
+	case "SELECT", "PRAGMA", "SHOW":
+		return
+	}
+	fmt.Fprintln(r.out, sql+";")
+}
@@ -0,0 +1,106 @@
+package migrations_test
+
+import (
+	"context"
+	"ecommerce-api/config"
+	"ecommerce-api/migrations"
+	"ecommerce-api/models"
+	"io"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// openSQLite returns a private in-memory SQLite database
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	cfg := config.Defaults().Database
+	cfg.Driver, cfg.Path = "sqlite", ":memory:"
+	db, err := models.ConnectDatabase(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { models.Close(db) })
+	return db
+}
+
+// newMigrator returns a quiet migrator on db
+func newMigrator(db *gorm.DB) *migrations.Migrator {
+	migrator := migrations.New(db)
+	migrator.Out = io.Discard
+	return migrator
+}
+
+func TestUpAndDownOnSQLite(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	migrator := newMigrator(db)
+	total := len(migrator.Migrations)
+
+	n, err := migrator.Up(ctx, 0)
+	if err != nil || n != total {
+		t.Fatalf("Up = %d, %v; want %d", n, err, total)
+	}
+	if err := migrator.Check(ctx); err != nil {
+		t.Errorf("Check after Up: %v", err)
+	}
+	for _, table := range []string{"users", "products", "orders", "order_items", "sessions", "api_keys", "audit_logs", "used_tokens"} {
+		if !db.Migrator().HasTable(table) {
+			t.Errorf("no %s table", table)
+		}
+	}
+
+	if n, err := migrator.Down(ctx, total); err != nil || n != total {
+		t.Fatalf("Down = %d, %v; want %d", n, err, total)
+	}
+	if db.Migrator().HasTable("users") {
+		t.Error("users table left after Down")
+	}
+	if err := migrator.Check(ctx); err == nil {
+		t.Error("Check passes with every migration reverted")
+	}
+
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		t.Fatalf("Up after Down: %v", err)
+	}
+}
+
+func TestNormalizeEmailsOnSQLite(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	migrator := newMigrator(db)
+
+	if _, err := migrator.Up(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("INSERT INTO users (email, password, created_at, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)", " Ada@Example.COM", "hash").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var email string
+	db.Table("users").Select("email").Scan(&email)
+	if email != "ada@example.com" {
+		t.Errorf("email = %q, want ada@example.com", email)
+	}
+}
+
+func TestSQLiteEnforcesForeignKeys(t *testing.T) {
+	db := openSQLite(t)
+	if _, err := newMigrator(db).Up(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	item := "INSERT INTO order_items (order_id, product_id, quantity) VALUES (?, 1, 1)"
+	if err := db.Exec("INSERT INTO orders (id, user_id, total, status) VALUES (1, 1, 1, 'Pending')").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(item, 1).Error; err != nil {
+		t.Fatalf("insert an item of an order: %v", err)
+	}
+	if err := db.Exec(item, 999).Error; err == nil {
+		t.Error("inserted an item of an unknown order")
+	}
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// API key scopes
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	ScopeOrdersRead    = "orders:read"
+	ScopeOrdersWrite   = "orders:write"
+	ScopeOrdersManage  = "orders:manage"
+)
+
+// APIKeyScopes lists every scope an API key can be granted
+var APIKeyScopes = []string{ScopeProductsRead, ScopeProductsWrite, ScopeOrdersRead, ScopeOrdersWrite, ScopeOrdersManage}
+
+// APIKey authenticates server-to-server integrations. The key acts on behalf
+// of UserID, limited to its scopes. Only the SHA-256 hash of the secret is
+// stored; Prefix is the public part used to look the key up.
+type APIKey struct {
+	gorm.Model
+	Name        string `gorm:"size:100;not null"`
+	Prefix      string `gorm:"size:16;uniqueIndex;not null"`
+	KeyHash     string `gorm:"size:64;not null" json:"-"`
+	Scopes      string `gorm:"size:255"` // space separated
+	UserID      uint   `gorm:"not null;index"`
+	CreatedByID uint
+	ExpiresAt   *time.Time
+	LastUsedAt  *time.Time
+	RevokedAt   *time.Time
+}
+
+// ScopeList returns the granted scopes
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// Active reports whether the key may be used
+func (k *APIKey) Active() bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt))
+}
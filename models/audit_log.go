@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Fields that change on every write and are left out of audit diffs
+var auditIgnoredFields = map[string]bool{"ID": true, "CreatedAt": true, "UpdatedAt": true, "DeletedAt": true}
+
+// ErrAuditLogImmutable is returned when an audit log entry is updated or deleted
+var ErrAuditLogImmutable = errors.New("audit log entries cannot be changed")
+
+// AuditChange is the value of a field before and after a change
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditChanges maps field names to their change, stored as JSON
+type AuditChanges map[string]AuditChange
+
+// Value implements driver.Valuer
+func (a AuditChanges) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(a)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner
+func (a *AuditChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return fmt.Errorf("unsupported audit changes type %T", value)
+	}
+}
+
+// AuditLog records an administrative mutation. Entries are append-only.
+type AuditLog struct {
+	ID         uint         `gorm:"primarykey"`
+	CreatedAt  time.Time    `gorm:"index"`
+	ActorID    uint         `gorm:"not null;index"`
+	APIKeyID   *uint        // set when the actor used an API key
+	Action     string       `gorm:"size:64;not null;index"`
+	TargetType string       `gorm:"size:32;not null;index:idx_audit_target"`
+	TargetID   uint         `gorm:"not null;index:idx_audit_target"`
+	Changes    AuditChanges `gorm:"type:text"`
+	IP         string       `gorm:"size:45"`
+	RequestID  string       `gorm:"size:64;index"`
+}
+
+// BeforeUpdate keeps entries append-only
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete keeps entries append-only
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// DiffAuditChanges compares the JSON representation of two states and returns
+// the fields that differ. Either state may be nil.
+func DiffAuditChanges(before, after interface{}) (AuditChanges, error) {
+	from, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := AuditChanges{}
+	for field, value := range from {
+		if !reflect.DeepEqual(value, to[field]) {
+			changes[field] = AuditChange{From: value, To: to[field]}
+		}
+	}
+	for field, value := range to {
+		if _, seen := from[field]; !seen && value != nil {
+			changes[field] = AuditChange{To: value}
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return changes, nil
+}
+
+func auditFields(state interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if state == nil {
+		return fields, nil
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for field := range auditIgnoredFields {
+		delete(fields, field)
+	}
+	return fields, nil
+}
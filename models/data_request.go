@@ -0,0 +1,19 @@
+package models
+
+import "gorm.io/gorm"
+
+// Data subject request types (GDPR articles 15, 17 and 20)
+const (
+	DataRequestExport  = "export"
+	DataRequestErasure = "erasure"
+)
+
+// DataRequest is the audit trail of data exports and account erasures. It is
+// kept after erasure and only references the user by ID.
+type DataRequest struct {
+	gorm.Model
+	UserID        uint   `gorm:"not null;index"`
+	Type          string `gorm:"size:16;not null"`
+	RequestedByID uint   `gorm:"not null"` // the user themselves or an admin
+	IP            string `gorm:"size:45"`
+}
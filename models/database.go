@@ -1,51 +1,120 @@
 package models
 
 import (
+	"context"
+	"ecommerce-api/config"
+	"ecommerce-api/utils"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-// DB is the global variable for the database connection
-var DB *gorm.DB
+// memoryDatabases names in-memory SQLite databases so that every
+// ConnectDatabase call gets a fresh one
+var memoryDatabases atomic.Int64
 
-// InitializeDB initializes the database connection
-func InitializeDB() {
-	var err error
-	dsn := "your-database-username:your-database-password@tcp(localhost:3306)/your-database-name?charset=utf8mb4&parseTime=True&loc=Local" // Replace with your actual database credentials
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+// ConnectDatabase opens the database selected by cfg.Driver
+func ConnectDatabase(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dialector, system, err := openDialector(cfg)
 	if err != nil {
-		log.Fatalf("Error opening database connection: %v", err)
+		return nil, err
 	}
-}
 
-func ConnectDatabase() {
-	// Load environment variables from .env file
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file: ", err)
+	// Initialize GORM, logging queries through slog
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: QueryLogger{SlowThreshold: cfg.SlowQueryThreshold}})
+	if err != nil {
+		return nil, fmt.Errorf("connect to the database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Driver == "sqlite" {
+		// SQLite has a single writer; one connection avoids "database is locked"
+		sqlDB.SetMaxOpenConns(1)
+	}
+	if err := db.Use(TracingPlugin{DBSystem: system}); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("enable query tracing: %w", err)
 	}
 
-	// Get database credentials from environment variables
-	username := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
+	slog.Info("Database connection established successfully", "driver", cfg.Driver)
+	return db, nil
+}
 
-	// Create DSN (Data Source Name) for MySQL
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		username, password, host, port, dbName)
+// openDialector builds the GORM dialector of a driver and the name
+// reported as db.system in traces
+func openDialector(cfg config.DatabaseConfig) (gorm.Dialector, string, error) {
+	switch cfg.Driver {
+	case "mysql":
+		port := cfg.Port
+		if port == 0 {
+			port = 3306
+		}
+		dsn := mysqldriver.Config{
+			User:                 cfg.User,
+			Passwd:               cfg.Password,
+			Net:                  "tcp",
+			Addr:                 net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+			DBName:               cfg.Name,
+			ParseTime:            true,
+			Loc:                  time.Local,
+			AllowNativePasswords: true,
+			Params:               map[string]string{"charset": "utf8mb4"},
+		}
+		return mysql.Open(dsn.FormatDSN()), "mysql", nil
 
-	// Initialize GORM with MySQL
-	var err error
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatal("Failed to connect to the database: ", err)
+	case "postgres":
+		port := cfg.Port
+		if port == 0 {
+			port = 5432
+		}
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(cfg.User, cfg.Password),
+			Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+			Path:     "/" + cfg.Name,
+			RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+		}
+		return postgres.Open(dsn.String()), "postgresql", nil
+
+	case "sqlite":
+		pragmas := "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+		if cfg.Path == ":memory:" {
+			dsn := fmt.Sprintf("file:memory%d?mode=memory&cache=shared&%s", memoryDatabases.Add(1), pragmas)
+			return sqlite.Open(dsn), "sqlite", nil
+		}
+		return sqlite.Open(cfg.Path + "?" + pragmas + "&_pragma=journal_mode(WAL)"), "sqlite", nil
 	}
+	return nil, "", fmt.Errorf("unknown database driver %q", cfg.Driver)
+}
 
-	fmt.Println("Database connection established successfully")
+// CheckDatabase returns the health check that pings db
+func CheckDatabase(db *gorm.DB) utils.HealthCheck {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// Close closes the connection pool of db once the server has stopped
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserIdentity links an account to an external OpenID Connect identity
+type UserIdentity struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;index"`
+	Provider string `gorm:"size:64;not null;uniqueIndex:idx_identity_provider_subject"`
+	Subject  string `gorm:"size:255;not null;uniqueIndex:idx_identity_provider_subject"`
+	Email    string `gorm:"size:255"`
+}
+
+// OAuthState holds the per-login secrets of an authorization code flow until
+// the provider redirects back. Each state is single-use.
+type OAuthState struct {
+	gorm.Model
+	State        string    `gorm:"size:64;uniqueIndex;not null"`
+	Provider     string    `gorm:"size:64;not null"`
+	Nonce        string    `gorm:"size:64;not null"`
+	CodeVerifier string    `gorm:"size:128;not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+}
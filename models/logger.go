@@ -0,0 +1,67 @@
+package models
+
+import (
+	"context"
+	"ecommerce-api/utils"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// QueryLogger sends GORM logs to slog. Queries run with a request context
+// (DB.WithContext) are logged with that request's ID.
+type QueryLogger struct {
+	// SlowThreshold is the duration above which queries are logged as warnings
+	SlowThreshold time.Duration
+}
+
+// LogMode is a no-op: levels are filtered by the slog handler
+func (l QueryLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (QueryLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	utils.Logger(ctx).InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (QueryLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	utils.Logger(ctx).WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (QueryLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	utils.Logger(ctx).ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+// Trace logs every query at debug level, slow queries as warnings and
+// failed queries as errors. Record not found is an expected outcome.
+func (l QueryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	log := utils.Logger(ctx)
+	elapsed := time.Since(begin)
+
+	level := slog.LevelDebug
+	msg := "query"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level, msg = slog.LevelError, "query failed"
+	case elapsed > l.SlowThreshold:
+		level, msg = slog.LevelWarn, "slow query"
+	}
+	if !log.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	log.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -2,10 +2,18 @@ package models
 
 import "gorm.io/gorm"
 
+// Order statuses
+const (
+	OrderPending   = "Pending"
+	OrderShipped   = "Shipped"
+	OrderDelivered = "Delivered"
+	OrderCanceled  = "Canceled"
+)
+
 type Order struct {
 	gorm.Model
 	UserID uint    `gorm:"not null"`
-	Status string  `gorm:"default:'Pending'"` // Pending, Completed, Cancelled
+	Status string  `gorm:"default:'Pending'"` // one of the order statuses above
 	Total  float64 `gorm:"not null"`
 	Items  []OrderItem
 }
@@ -10,4 +10,7 @@ type Product struct {
 	Description string  `gorm:"type:text"`
 	Price       float64 `gorm:"not null"`
 	Stock       int     `gorm:"not null"`
+
+	// SKU is the optional stock keeping unit, unique among products
+	SKU *string `gorm:"size:64;uniqueIndex"`
 }
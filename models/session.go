@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session groups every refresh token issued from a single login (a token
+// family). Revoking a session invalidates all access and refresh tokens
+// derived from it.
+type Session struct {
+	gorm.Model
+	UserID    uint      `gorm:"not null;index"`
+	UserAgent string    `gorm:"size:255"`
+	IP        string    `gorm:"size:64"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+
+	// MFA records that the login was completed with a second factor
+	MFA bool `gorm:"default:false"`
+}
+
+// RefreshToken is a single-use token that can be exchanged for a new access
+// token. Only the SHA-256 hash of the token is stored.
+type RefreshToken struct {
+	gorm.Model
+	SessionID uint      `gorm:"not null;index"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+// Active reports whether the session can still be used to mint tokens
+func (s *Session) Active() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
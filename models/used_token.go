@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// UsedToken records the ID (jti) of a single-use signed token once it has been
+// presented. Rows are only needed until the token would have expired anyway.
+type UsedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
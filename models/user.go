@@ -1,10 +1,78 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"ecommerce-api/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
 	gorm.Model
 	Email    string `gorm:"unique;not null"`
-	Password string `gorm:"not null"`
+	Password string `gorm:"not null" json:"-"`
 	IsAdmin  bool   `gorm:"default:false"`
+
+	// Profile
+	Name               string `gorm:"size:100"`
+	Phone              string `gorm:"size:32"`
+	Locale             string `gorm:"size:16;default:'en'"`
+	MarketingConsent   bool   `gorm:"default:false"`
+	MarketingConsentAt *time.Time
+
+	// Email verification. PendingEmail holds a requested address change until
+	// the new address is confirmed.
+	EmailVerifiedAt    *time.Time
+	VerificationSentAt *time.Time
+	PendingEmail       string `gorm:"size:255"`
+
+	// Two-factor authentication. The secret is set on enrolment and only
+	// enforced once TOTPEnabledAt is set by a confirmed code.
+	TOTPSecret    string `gorm:"size:64" json:"-"`
+	TOTPEnabledAt *time.Time
+	TOTPLastStep  int64 `json:"-"`
+
+	// Brute-force protection
+	FailedLoginCount  int `gorm:"default:0"`
+	LastFailedLoginAt *time.Time
+	LockedUntil       *time.Time
+
+	// Set when personal data was erased on request; the row is kept
+	// (soft-deleted) so orders still reference it
+	AnonymizedAt *time.Time
+}
+
+// RecoveryCode is a single-use 2FA backup code. Only its hash is stored.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;index"`
+	CodeHash string `gorm:"size:64;not null;index"`
+	UsedAt   *time.Time
+}
+
+// BeforeSave stores email addresses normalised, see utils.NormalizeEmail.
+// Updates with a map of columns bypass it and must normalise themselves.
+func (u *User) BeforeSave(tx *gorm.DB) error {
+	u.Email = utils.NormalizeEmail(u.Email)
+	u.PendingEmail = utils.NormalizeEmail(u.PendingEmail)
+	return nil
+}
+
+// MFAEnabled reports whether login requires a second factor
+func (u *User) MFAEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// Locked reports whether logins are temporarily blocked and for how long
+func (u *User) Locked() (bool, time.Duration) {
+	if u.LockedUntil == nil {
+		return false, 0
+	}
+	remaining := time.Until(*u.LockedUntil)
+	return remaining > 0, remaining
+}
+
+// EmailVerified reports whether the user confirmed their email address
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
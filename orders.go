@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"ecommerce-api/catalog"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/services"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const ordersUsage = `usage: ecommerce-api orders export [flags]
+
+Write every order with its items. CSV files have one line per item, JSON
+files one object per order. The configuration flags of the server are
+accepted as well, see -h.
+`
+
+// orderRecord is the JSON form of an exported order
+type orderRecord struct {
+	ID        uint              `json:"id"`
+	UserID    uint              `json:"user_id"`
+	Status    string            `json:"status"`
+	Total     float64           `json:"total"`
+	CreatedAt time.Time         `json:"created_at"`
+	Items     []orderItemRecord `json:"items"`
+}
+
+type orderItemRecord struct {
+	ProductID uint `json:"product_id"`
+	Quantity  int  `json:"quantity"`
+}
+
+// runOrders implements "ecommerce-api orders" and returns the exit code
+func runOrders(args []string) int {
+	if _, ok := subcommand(args, "export"); !ok {
+		fmt.Fprint(os.Stderr, ordersUsage)
+		return 2
+	}
+
+	flagSet := flag.NewFlagSet("ecommerce-api orders export", flag.ContinueOnError)
+	path := flagSet.String("file", "-", "`file` to write, - for standard output")
+	formatName := flagSet.String("format", "", "file `format`, csv or json (default: from the file extension, else csv)")
+	status := flagSet.String("status", "", "export only the orders with this `status`")
+	cfg, code, ok := loadConfig(flagSet, args[1:])
+	if !ok {
+		return code
+	}
+	format, err := fileFormat(*formatName, *path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *status != "" && !services.ValidOrderStatus(*status) {
+		fmt.Fprintf(os.Stderr, "unknown order status %q\n", *status)
+		return 2
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+	store, closeDB, err := openStore(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeDB()
+
+	out, err := createFile(*path)
+	if err == nil {
+		defer out.Close()
+		err = exportOrders(ctx, store, out, format, *status)
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// exportOrders writes the orders one by one as they are read
+func exportOrders(ctx context.Context, store repositories.Store, out io.Writer, format catalog.Format, status string) error {
+	var write func(*models.Order) error
+	var finish func() error
+	count := 0
+
+	switch format {
+	case catalog.CSV:
+		w := csv.NewWriter(out)
+		if err := w.Write([]string{"order_id", "user_id", "status", "total", "created_at", "product_id", "quantity"}); err != nil {
+			return err
+		}
+		write = func(order *models.Order) error {
+			line := []string{
+				strconv.FormatUint(uint64(order.ID), 10),
+				strconv.FormatUint(uint64(order.UserID), 10),
+				order.Status,
+				strconv.FormatFloat(order.Total, 'f', 2, 64),
+				order.CreatedAt.UTC().Format(time.RFC3339),
+				"", "",
+			}
+			for _, item := range order.Items {
+				line[5] = strconv.FormatUint(uint64(item.ProductID), 10)
+				line[6] = strconv.Itoa(item.Quantity)
+				if err := w.Write(line); err != nil {
+					return err
+				}
+			}
+			if len(order.Items) == 0 {
+				return w.Write(line)
+			}
+			return nil
+		}
+		finish = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	case catalog.JSON:
+		w := bufio.NewWriter(out)
+		w.WriteString("[")
+		write = func(order *models.Order) error {
+			record := orderRecord{ID: order.ID, UserID: order.UserID, Status: order.Status, Total: order.Total,
+				CreatedAt: order.CreatedAt.UTC(), Items: make([]orderItemRecord, len(order.Items))}
+			for i, item := range order.Items {
+				record.Items[i] = orderItemRecord{ProductID: item.ProductID, Quantity: item.Quantity}
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				w.WriteString(",")
+			}
+			w.WriteString("\n  ")
+			_, err = w.Write(data)
+			return err
+		}
+		finish = func() error {
+			if count > 0 {
+				w.WriteString("\n")
+			}
+			w.WriteString("]\n")
+			return w.Flush()
+		}
+	}
+
+	err := store.Orders().Each(ctx, func(order *models.Order) error {
+		if status != "" && order.Status != status {
+			return nil
+		}
+		if err := write(order); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := finish(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d order(s) exported\n", count)
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"ecommerce-api/catalog"
+	"ecommerce-api/repositories"
+	"ecommerce-api/services"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const productsUsage = `usage: ecommerce-api products <command> [flags]
+
+commands:
+  import   create and update products from a CSV or JSON file, matching
+           existing products by SKU, then by name
+  export   write the catalog as CSV or JSON
+
+--file names the file, standard input or output by default, and --format its
+format, guessed from the file extension. An import changes nothing unless
+every row is valid; --mode best-effort imports the valid rows and --dry-run
+only reports. The configuration flags of the server are accepted as well,
+see -h.
+`
+
+// runProducts implements "ecommerce-api products" and returns the exit code
+func runProducts(args []string) int {
+	command, ok := subcommand(args, "import", "export")
+	if !ok {
+		fmt.Fprint(os.Stderr, productsUsage)
+		return 2
+	}
+
+	flagSet := flag.NewFlagSet("ecommerce-api products "+command, flag.ContinueOnError)
+	path := flagSet.String("file", "-", "`file` to read or write, - for standard input or output")
+	formatName := flagSet.String("format", "", "file `format`, csv or json (default: from the file extension, else csv)")
+	dryRun := flagSet.Bool("dry-run", false, "check the file and report the changes without making them (import)")
+	modeName := flagSet.String("mode", string(services.ImportAtomic), "import `mode`: atomic imports every row or none, best-effort the valid rows")
+	cfg, code, ok := loadConfig(flagSet, args[1:])
+	if !ok {
+		return code
+	}
+	format, err := fileFormat(*formatName, *path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	mode, err := services.ParseImportMode(*modeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+	store, closeDB, err := openStore(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeDB()
+
+	switch command {
+	case "import":
+		err = importProducts(ctx, store, *path, format, services.ImportOptions{Mode: mode, DryRun: *dryRun})
+	case "export":
+		err = exportProducts(ctx, store, *path, format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// fileFormat returns the format named by name, or else the one of path
+func fileFormat(name, path string) (catalog.Format, error) {
+	if name != "" {
+		return catalog.ParseFormat(name)
+	}
+	if format := catalog.FormatOf(path); format != "" {
+		return format, nil
+	}
+	return catalog.CSV, nil
+}
+
+// importProducts imports a file, reporting progress and failed rows on stderr
+func importProducts(ctx context.Context, store repositories.Store, path string, format catalog.Format, opts services.ImportOptions) error {
+	in, err := openFile(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	rows, err := catalog.NewReader(in, format)
+	if err != nil {
+		return err
+	}
+
+	opts.Progress = func(counts services.ImportCounts) {
+		fmt.Fprintf(os.Stderr, "%d row(s) read: %d created, %d updated, %d unchanged, %d failed\n",
+			counts.Rows, counts.Created, counts.Updated, counts.Unchanged, counts.Failed)
+	}
+	// Changes made from the command line have no actor in the audit log
+	result, err := services.NewProductService(store).Import(ctx, services.Actor{}, rows, opts)
+	if err != nil {
+		return err
+	}
+
+	for _, rowErr := range result.Errors {
+		fmt.Fprintf(os.Stderr, "line %d: %s\n", rowErr.Line, rowErr.Message)
+	}
+	if result.Failed > len(result.Errors) {
+		fmt.Fprintf(os.Stderr, "... and %d more failed row(s)\n", result.Failed-len(result.Errors))
+	}
+	switch {
+	case result.DryRun:
+		fmt.Fprintln(os.Stderr, "Dry run, nothing was changed")
+	case !result.Applied:
+		fmt.Fprintln(os.Stderr, "Nothing was imported")
+	default:
+		fmt.Fprintf(os.Stderr, "%d product(s) created, %d updated, %d unchanged\n", result.Created, result.Updated, result.Unchanged)
+	}
+	if result.Failed > 0 {
+		return fmt.Errorf("%d of %d row(s) failed", result.Failed, result.Rows)
+	}
+	return nil
+}
+
+// exportProducts writes the catalog as it is read from the database
+func exportProducts(ctx context.Context, store repositories.Store, path string, format catalog.Format) error {
+	out, err := createFile(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	writer, err := catalog.NewWriter(out, format)
+	if err != nil {
+		return err
+	}
+	if err := services.NewProductService(store).Each(ctx, writer.Write); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d product(s) exported\n", writer.Count())
+	return out.Close()
+}
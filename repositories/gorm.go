@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// eachBatchSize is the number of records Each loads per query
+const eachBatchSize = 500
+
+// gormStore implements Store on a database connection or transaction
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore returns the repositories backed by db
+func NewGormStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) Products() ProductRepository             { return gormProducts{s.db} }
+func (s *gormStore) Orders() OrderRepository                 { return gormOrders{s.db} }
+func (s *gormStore) Users() UserRepository                   { return gormUsers{s.db} }
+func (s *gormStore) Sessions() SessionRepository             { return gormSessions{s.db} }
+func (s *gormStore) RefreshTokens() RefreshTokenRepository   { return gormRefreshTokens{s.db} }
+func (s *gormStore) PasswordResets() PasswordResetRepository { return gormPasswordResets{s.db} }
+func (s *gormStore) RecoveryCodes() RecoveryCodeRepository   { return gormRecoveryCodes{s.db} }
+func (s *gormStore) Identities() IdentityRepository          { return gormIdentities{s.db} }
+func (s *gormStore) OAuthStates() OAuthStateRepository       { return gormOAuthStates{s.db} }
+func (s *gormStore) UsedTokens() UsedTokenRepository         { return gormUsedTokens{s.db} }
+func (s *gormStore) APIKeys() APIKeyRepository               { return gormAPIKeys{s.db} }
+func (s *gormStore) DataRequests() DataRequestRepository     { return gormDataRequests{s.db} }
+func (s *gormStore) AuditLogs() AuditLogRepository           { return gormAuditLogs{s.db} }
+
+func (s *gormStore) Atomic(ctx context.Context, fn func(Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormStore{db: tx})
+	})
+}
+
+// notFound maps GORM's missing record error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type gormProducts struct {
+	db *gorm.DB
+}
+
+func (r gormProducts) List(ctx context.Context) ([]models.Product, error) {
+	var products []models.Product
+	err := r.db.WithContext(ctx).Find(&products).Error
+	return products, err
+}
+
+func (r gormProducts) Get(ctx context.Context, id uint) (*models.Product, error) {
+	var product models.Product
+	if err := r.db.WithContext(ctx).First(&product, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &product, nil
+}
+
+func (r gormProducts) GetByName(ctx context.Context, name string) (*models.Product, error) {
+	var product models.Product
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&product).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &product, nil
+}
+
+func (r gormProducts) GetBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	var product models.Product
+	if err := r.db.WithContext(ctx).Where("sku = ?", sku).First(&product).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &product, nil
+}
+
+func (r gormProducts) Create(ctx context.Context, product *models.Product) error {
+	return r.db.WithContext(ctx).Create(product).Error
+}
+
+func (r gormProducts) Update(ctx context.Context, product *models.Product, columns ...string) error {
+	return r.db.WithContext(ctx).Model(product).Select(columns).Updates(product).Error
+}
+
+func (r gormProducts) Delete(ctx context.Context, product *models.Product) error {
+	return r.db.WithContext(ctx).Delete(product).Error
+}
+
+func (r gormProducts) AdjustStock(ctx context.Context, id uint, delta int) (bool, error) {
+	// A single conditional update, so concurrent orders cannot oversell
+	result := r.db.WithContext(ctx).Model(&models.Product{}).
+		Where("id = ? AND stock + ? >= 0", id, delta).
+		Update("stock", gorm.Expr("stock + ?", delta))
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r gormProducts) Each(ctx context.Context, fn func(*models.Product) error) error {
+	var batch []models.Product
+	return r.db.WithContext(ctx).FindInBatches(&batch, eachBatchSize, func(*gorm.DB, int) error {
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+type gormOrders struct {
+	db *gorm.DB
+}
+
+func (r gormOrders) ListByUser(ctx context.Context, userID uint) ([]models.Order, error) {
+	var orders []models.Order
+	err := r.db.WithContext(ctx).Preload("Items").Where("user_id = ?", userID).Find(&orders).Error
+	return orders, err
+}
+
+func (r gormOrders) Get(ctx context.Context, id uint) (*models.Order, error) {
+	var order models.Order
+	if err := r.db.WithContext(ctx).Preload("Items").First(&order, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &order, nil
+}
+
+func (r gormOrders) Create(ctx context.Context, order *models.Order) error {
+	return r.db.WithContext(ctx).Create(order).Error
+}
+
+func (r gormOrders) SetStatus(ctx context.Context, id uint, from, to string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Order{}).Where("id = ? AND status = ?", id, from).Update("status", to)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r gormOrders) Each(ctx context.Context, fn func(*models.Order) error) error {
+	var batch []models.Order
+	return r.db.WithContext(ctx).Preload("Items").FindInBatches(&batch, eachBatchSize, func(*gorm.DB, int) error {
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
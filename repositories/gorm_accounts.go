@@ -0,0 +1,368 @@
+package repositories
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r gormUsers) Get(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("email = ?", utils.NormalizeEmail(email)).First(&user).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r gormUsers) Update(ctx context.Context, user *models.User, columns ...string) error {
+	return r.db.WithContext(ctx).Model(user).Select(columns).Updates(user).Error
+}
+
+func (r gormUsers) Delete(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Delete(user).Error
+}
+
+func (r gormUsers) RecordLoginFailure(ctx context.Context, id uint, at, since time.Time) (int, error) {
+	// Increment in the database so concurrent failures are all counted
+	db := r.db.WithContext(ctx)
+	err := db.Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"failed_login_count": gorm.Expr("CASE WHEN last_failed_login_at IS NULL OR last_failed_login_at < ? "+
+			"THEN 1 ELSE failed_login_count + 1 END", since),
+		"last_failed_login_at": at,
+	}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var failures int
+	err = db.Model(&models.User{}).Where("id = ?", id).Select("failed_login_count").Scan(&failures).Error
+	return failures, err
+}
+
+func (r gormUsers) SetTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+type gormSessions struct {
+	db *gorm.DB
+}
+
+func (r gormSessions) Create(ctx context.Context, session *models.Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+func (r gormSessions) Get(ctx context.Context, id uint) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.WithContext(ctx).First(&session, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &session, nil
+}
+
+func (r gormSessions) ListByUser(ctx context.Context, userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r gormSessions) ActiveIDs(ctx context.Context, userID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (r gormSessions) Touch(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Model(&models.Session{}).Where("id = ?", id).Update("updated_at", time.Now()).Error
+}
+
+func (r gormSessions) Revoke(ctx context.Context, ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id IN ? AND revoked_at IS NULL", ids).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r gormSessions) Anonymize(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&models.Session{}).Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"user_agent": "", "ip": ""}).Error
+}
+
+type gormRefreshTokens struct {
+	db *gorm.DB
+}
+
+func (r gormRefreshTokens) Create(ctx context.Context, token *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r gormRefreshTokens) GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &token, nil
+}
+
+func (r gormRefreshTokens) MarkUsed(ctx context.Context, id uint) (bool, error) {
+	// A concurrent or repeated use loses this race
+	result := r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+type gormPasswordResets struct {
+	db *gorm.DB
+}
+
+func (r gormPasswordResets) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r gormPasswordResets) GetByHash(ctx context.Context, hash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &token, nil
+}
+
+func (r gormPasswordResets) MarkUsed(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r gormPasswordResets) MarkAllUsed(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+func (r gormPasswordResets) DeleteByUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Unscoped().Where("user_id = ?", userID).Delete(&models.PasswordResetToken{}).Error
+}
+
+type gormRecoveryCodes struct {
+	db *gorm.DB
+}
+
+func (r gormRecoveryCodes) Create(ctx context.Context, code *models.RecoveryCode) error {
+	return r.db.WithContext(ctx).Create(code).Error
+}
+
+func (r gormRecoveryCodes) Use(ctx context.Context, userID uint, hash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormRecoveryCodes) DeleteByUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+type gormIdentities struct {
+	db *gorm.DB
+}
+
+func (r gormIdentities) Get(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &identity, nil
+}
+
+func (r gormIdentities) ListByUser(ctx context.Context, userID uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&identities).Error
+	return identities, err
+}
+
+func (r gormIdentities) Create(ctx context.Context, identity *models.UserIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+func (r gormIdentities) DeleteByUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Unscoped().Where("user_id = ?", userID).Delete(&models.UserIdentity{}).Error
+}
+
+type gormOAuthStates struct {
+	db *gorm.DB
+}
+
+func (r gormOAuthStates) Create(ctx context.Context, state *models.OAuthState) error {
+	return r.db.WithContext(ctx).Create(state).Error
+}
+
+func (r gormOAuthStates) Take(ctx context.Context, state, provider string) (*models.OAuthState, error) {
+	var record models.OAuthState
+	db := r.db.WithContext(ctx)
+	if err := db.Where("state = ? AND provider = ?", state, provider).First(&record).Error; err != nil {
+		return nil, notFound(err)
+	}
+	// Of two concurrent callbacks with the same state only one deletes it
+	result := db.Unscoped().Delete(&record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+func (r gormOAuthStates) DeleteExpired(ctx context.Context, t time.Time) error {
+	return r.db.WithContext(ctx).Unscoped().Where("expires_at < ?", t).Delete(&models.OAuthState{}).Error
+}
+
+type gormUsedTokens struct {
+	db *gorm.DB
+}
+
+func (r gormUsedTokens) Use(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.UsedToken{JTI: jti, ExpiresAt: expiresAt})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r gormUsedTokens) DeleteExpired(ctx context.Context, t time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at < ?", t).Delete(&models.UsedToken{}).Error
+}
+
+type gormAPIKeys struct {
+	db *gorm.DB
+}
+
+func (r gormAPIKeys) List(ctx context.Context) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.WithContext(ctx).Order("id").Find(&keys).Error
+	return keys, err
+}
+
+func (r gormAPIKeys) ListByUser(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&keys).Error
+	return keys, err
+}
+
+func (r gormAPIKeys) Get(ctx context.Context, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.WithContext(ctx).First(&key, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &key, nil
+}
+
+func (r gormAPIKeys) GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.WithContext(ctx).Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &key, nil
+}
+
+func (r gormAPIKeys) Create(ctx context.Context, key *models.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r gormAPIKeys) Update(ctx context.Context, key *models.APIKey, columns ...string) error {
+	return r.db.WithContext(ctx).Model(key).Select(columns).Updates(key).Error
+}
+
+func (r gormAPIKeys) Touch(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
+
+func (r gormAPIKeys) RevokeByUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+type gormDataRequests struct {
+	db *gorm.DB
+}
+
+func (r gormDataRequests) Create(ctx context.Context, request *models.DataRequest) error {
+	return r.db.WithContext(ctx).Create(request).Error
+}
+
+func (r gormDataRequests) List(ctx context.Context, userID uint) ([]models.DataRequest, error) {
+	query := r.db.WithContext(ctx).Order("created_at DESC, id DESC")
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	var requests []models.DataRequest
+	err := query.Find(&requests).Error
+	return requests, err
+}
+
+type gormAuditLogs struct {
+	db *gorm.DB
+}
+
+func (r gormAuditLogs) Create(ctx context.Context, entry *models.AuditLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+func (r gormAuditLogs) List(ctx context.Context, filter AuditLogFilter) ([]models.AuditLog, error) {
+	query := r.db.WithContext(ctx).Order("id DESC")
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at <= ?", filter.Until)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	var logs []models.AuditLog
+	err := query.Find(&logs).Error
+	return logs, err
+}
@@ -0,0 +1,511 @@
+package memory
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/utils"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+// list returns the records of m that keep accepts, in ID order
+func list[T any](m map[uint]T, keep func(T) bool) []T {
+	ids := make([]uint, 0, len(m))
+	for id, record := range m {
+		if keep(record) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	records := make([]T, len(ids))
+	for i, id := range ids {
+		records[i] = m[id]
+	}
+	return records
+}
+
+// setColumns copies the fields of src named by columns (as GORM names the
+// columns) to dst, so updates leave the other fields of a record alone like
+// the database does
+func setColumns(dst, src interface{}, columns []string) {
+	wanted := map[string]bool{}
+	for _, column := range columns {
+		wanted[column] = true
+	}
+	copyFields(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem(), wanted)
+}
+
+func copyFields(dst, src reflect.Value, wanted map[string]bool) {
+	naming := schema.NamingStrategy{}
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			copyFields(dst.Field(i), src.Field(i), wanted)
+			continue
+		}
+		if wanted[naming.ColumnName("", field.Name)] {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
+
+type users struct{ s *Store }
+
+func (r users) Get(ctx context.Context, id uint) (*models.User, error) {
+	defer r.s.lock()()
+	u, ok := r.s.data.users[id]
+	if !ok {
+		return nil, repositories.ErrNotFound
+	}
+	return &u, nil
+}
+
+func (r users) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	defer r.s.lock()()
+	email = utils.NormalizeEmail(email)
+	for _, u := range r.s.data.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (r users) Create(ctx context.Context, user *models.User) error {
+	defer r.s.lock()()
+	user.Email = utils.NormalizeEmail(user.Email)
+	user.ID = r.s.data.id()
+	user.CreatedAt, user.UpdatedAt = time.Now(), time.Now()
+	r.s.data.users[user.ID] = *user
+	return nil
+}
+
+func (r users) Update(ctx context.Context, user *models.User, columns ...string) error {
+	defer r.s.lock()()
+	stored, ok := r.s.data.users[user.ID]
+	if !ok {
+		return repositories.ErrNotFound
+	}
+	user.Email = utils.NormalizeEmail(user.Email)
+	user.PendingEmail = utils.NormalizeEmail(user.PendingEmail)
+	user.UpdatedAt = time.Now()
+	setColumns(&stored, user, append(columns, "updated_at"))
+	r.s.data.users[user.ID] = stored
+	return nil
+}
+
+// Delete removes the user; the fake does not keep soft-deleted rows
+func (r users) Delete(ctx context.Context, user *models.User) error {
+	defer r.s.lock()()
+	delete(r.s.data.users, user.ID)
+	return nil
+}
+
+func (r users) RecordLoginFailure(ctx context.Context, id uint, at, since time.Time) (int, error) {
+	defer r.s.lock()()
+	u, ok := r.s.data.users[id]
+	if !ok {
+		return 0, nil
+	}
+	if u.LastFailedLoginAt == nil || u.LastFailedLoginAt.Before(since) {
+		u.FailedLoginCount = 1
+	} else {
+		u.FailedLoginCount++
+	}
+	u.LastFailedLoginAt = &at
+	r.s.data.users[id] = u
+	return u.FailedLoginCount, nil
+}
+
+func (r users) SetTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	defer r.s.lock()()
+	u, ok := r.s.data.users[id]
+	if !ok || u.TOTPLastStep >= step {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	r.s.data.users[id] = u
+	return true, nil
+}
+
+type sessions struct{ s *Store }
+
+func (r sessions) Create(ctx context.Context, session *models.Session) error {
+	defer r.s.lock()()
+	session.ID = r.s.data.id()
+	session.CreatedAt, session.UpdatedAt = time.Now(), time.Now()
+	r.s.data.sessions[session.ID] = *session
+	return nil
+}
+
+func (r sessions) Get(ctx context.Context, id uint) (*models.Session, error) {
+	defer r.s.lock()()
+	session, ok := r.s.data.sessions[id]
+	if !ok {
+		return nil, repositories.ErrNotFound
+	}
+	return &session, nil
+}
+
+func (r sessions) ListByUser(ctx context.Context, userID uint) ([]models.Session, error) {
+	defer r.s.lock()()
+	list := list(r.s.data.sessions, func(s models.Session) bool { return s.UserID == userID })
+	slices.Reverse(list)
+	return list, nil
+}
+
+func (r sessions) ActiveIDs(ctx context.Context, userID uint) ([]uint, error) {
+	defer r.s.lock()()
+	var ids []uint
+	for _, session := range list(r.s.data.sessions, func(s models.Session) bool { return s.UserID == userID && s.RevokedAt == nil }) {
+		ids = append(ids, session.ID)
+	}
+	return ids, nil
+}
+
+func (r sessions) Touch(ctx context.Context, id uint) error {
+	defer r.s.lock()()
+	if session, ok := r.s.data.sessions[id]; ok {
+		session.UpdatedAt = time.Now()
+		r.s.data.sessions[id] = session
+	}
+	return nil
+}
+
+func (r sessions) Revoke(ctx context.Context, ids ...uint) error {
+	defer r.s.lock()()
+	now := time.Now()
+	for _, id := range ids {
+		if session, ok := r.s.data.sessions[id]; ok && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			r.s.data.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+func (r sessions) Anonymize(ctx context.Context, userID uint) error {
+	defer r.s.lock()()
+	for id, session := range r.s.data.sessions {
+		if session.UserID == userID {
+			session.UserAgent, session.IP = "", ""
+			r.s.data.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+type refreshTokens struct{ s *Store }
+
+func (r refreshTokens) Create(ctx context.Context, token *models.RefreshToken) error {
+	defer r.s.lock()()
+	token.ID = r.s.data.id()
+	token.CreatedAt, token.UpdatedAt = time.Now(), time.Now()
+	r.s.data.refreshTokens[token.ID] = *token
+	return nil
+}
+
+func (r refreshTokens) GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	defer r.s.lock()()
+	for _, token := range r.s.data.refreshTokens {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (r refreshTokens) MarkUsed(ctx context.Context, id uint) (bool, error) {
+	defer r.s.lock()()
+	token, ok := r.s.data.refreshTokens[id]
+	if !ok || token.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	r.s.data.refreshTokens[id] = token
+	return true, nil
+}
+
+type passwordResets struct{ s *Store }
+
+func (r passwordResets) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	defer r.s.lock()()
+	token.ID = r.s.data.id()
+	token.CreatedAt, token.UpdatedAt = time.Now(), time.Now()
+	r.s.data.passwordResets[token.ID] = *token
+	return nil
+}
+
+func (r passwordResets) GetByHash(ctx context.Context, hash string) (*models.PasswordResetToken, error) {
+	defer r.s.lock()()
+	for _, token := range r.s.data.passwordResets {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (r passwordResets) MarkUsed(ctx context.Context, id uint) (bool, error) {
+	defer r.s.lock()()
+	token, ok := r.s.data.passwordResets[id]
+	if !ok || token.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	r.s.data.passwordResets[id] = token
+	return true, nil
+}
+
+func (r passwordResets) MarkAllUsed(ctx context.Context, userID uint) error {
+	defer r.s.lock()()
+	now := time.Now()
+	for id, token := range r.s.data.passwordResets {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &now
+			r.s.data.passwordResets[id] = token
+		}
+	}
+	return nil
+}
+
+func (r passwordResets) DeleteByUser(ctx context.Context, userID uint) error {
+	defer r.s.lock()()
+	maps.DeleteFunc(r.s.data.passwordResets, func(_ uint, t models.PasswordResetToken) bool { return t.UserID == userID })
+	return nil
+}
+
+type recoveryCodes struct{ s *Store }
+
+func (r recoveryCodes) Create(ctx context.Context, code *models.RecoveryCode) error {
+	defer r.s.lock()()
+	code.ID = r.s.data.id()
+	code.CreatedAt, code.UpdatedAt = time.Now(), time.Now()
+	r.s.data.recoveryCodes[code.ID] = *code
+	return nil
+}
+
+func (r recoveryCodes) Use(ctx context.Context, userID uint, hash string) (bool, error) {
+	defer r.s.lock()()
+	for id, code := range r.s.data.recoveryCodes {
+		if code.UserID == userID && code.CodeHash == hash && code.UsedAt == nil {
+			now := time.Now()
+			code.UsedAt = &now
+			r.s.data.recoveryCodes[id] = code
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r recoveryCodes) DeleteByUser(ctx context.Context, userID uint) error {
+	defer r.s.lock()()
+	maps.DeleteFunc(r.s.data.recoveryCodes, func(_ uint, c models.RecoveryCode) bool { return c.UserID == userID })
+	return nil
+}
+
+type identities struct{ s *Store }
+
+func (r identities) Get(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	defer r.s.lock()()
+	for _, identity := range r.s.data.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (r identities) ListByUser(ctx context.Context, userID uint) ([]models.UserIdentity, error) {
+	defer r.s.lock()()
+	return list(r.s.data.identities, func(i models.UserIdentity) bool { return i.UserID == userID }), nil
+}
+
+func (r identities) Create(ctx context.Context, identity *models.UserIdentity) error {
+	defer r.s.lock()()
+	identity.ID = r.s.data.id()
+	identity.CreatedAt, identity.UpdatedAt = time.Now(), time.Now()
+	r.s.data.identities[identity.ID] = *identity
+	return nil
+}
+
+func (r identities) DeleteByUser(ctx context.Context, userID uint) error {
+	defer r.s.lock()()
+	maps.DeleteFunc(r.s.data.identities, func(_ uint, i models.UserIdentity) bool { return i.UserID == userID })
+	return nil
+}
+
+type oauthStates struct{ s *Store }
+
+func (r oauthStates) Create(ctx context.Context, state *models.OAuthState) error {
+	defer r.s.lock()()
+	state.ID = r.s.data.id()
+	state.CreatedAt, state.UpdatedAt = time.Now(), time.Now()
+	r.s.data.oauthStates[state.ID] = *state
+	return nil
+}
+
+func (r oauthStates) Take(ctx context.Context, state, provider string) (*models.OAuthState, error) {
+	defer r.s.lock()()
+	for id, record := range r.s.data.oauthStates {
+		if record.State == state && record.Provider == provider {
+			delete(r.s.data.oauthStates, id)
+			return &record, nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (r oauthStates) DeleteExpired(ctx context.Context, t time.Time) error {
+	defer r.s.lock()()
+	maps.DeleteFunc(r.s.data.oauthStates, func(_ uint, s models.OAuthState) bool { return s.ExpiresAt.Before(t) })
+	return nil
+}
+
+type usedTokens struct{ s *Store }
+
+func (r usedTokens) Use(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	defer r.s.lock()()
+	if _, used := r.s.data.usedTokens[jti]; used {
+		return false, nil
+	}
+	r.s.data.usedTokens[jti] = models.UsedToken{JTI: jti, ExpiresAt: expiresAt, CreatedAt: time.Now()}
+	return true, nil
+}
+
+func (r usedTokens) DeleteExpired(ctx context.Context, t time.Time) error {
+	defer r.s.lock()()
+	maps.DeleteFunc(r.s.data.usedTokens, func(_ string, u models.UsedToken) bool { return u.ExpiresAt.Before(t) })
+	return nil
+}
+
+type apiKeys struct{ s *Store }
+
+func (r apiKeys) List(ctx context.Context) ([]models.APIKey, error) {
+	defer r.s.lock()()
+	return list(r.s.data.apiKeys, func(models.APIKey) bool { return true }), nil
+}
+
+func (r apiKeys) ListByUser(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	defer r.s.lock()()
+	return list(r.s.data.apiKeys, func(k models.APIKey) bool { return k.UserID == userID }), nil
+}
+
+func (r apiKeys) Get(ctx context.Context, id uint) (*models.APIKey, error) {
+	defer r.s.lock()()
+	key, ok := r.s.data.apiKeys[id]
+	if !ok {
+		return nil, repositories.ErrNotFound
+	}
+	return &key, nil
+}
+
+func (r apiKeys) GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	defer r.s.lock()()
+	for _, key := range r.s.data.apiKeys {
+		if key.Prefix == prefix {
+			return &key, nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (r apiKeys) Create(ctx context.Context, key *models.APIKey) error {
+	defer r.s.lock()()
+	key.ID = r.s.data.id()
+	key.CreatedAt, key.UpdatedAt = time.Now(), time.Now()
+	r.s.data.apiKeys[key.ID] = *key
+	return nil
+}
+
+func (r apiKeys) Update(ctx context.Context, key *models.APIKey, columns ...string) error {
+	defer r.s.lock()()
+	stored, ok := r.s.data.apiKeys[key.ID]
+	if !ok {
+		return repositories.ErrNotFound
+	}
+	key.UpdatedAt = time.Now()
+	setColumns(&stored, key, append(columns, "updated_at"))
+	r.s.data.apiKeys[key.ID] = stored
+	return nil
+}
+
+func (r apiKeys) Touch(ctx context.Context, id uint, at time.Time) error {
+	defer r.s.lock()()
+	if key, ok := r.s.data.apiKeys[id]; ok {
+		key.LastUsedAt = &at
+		r.s.data.apiKeys[id] = key
+	}
+	return nil
+}
+
+func (r apiKeys) RevokeByUser(ctx context.Context, userID uint) error {
+	defer r.s.lock()()
+	now := time.Now()
+	for id, key := range r.s.data.apiKeys {
+		if key.UserID == userID && key.RevokedAt == nil {
+			key.RevokedAt = &now
+			r.s.data.apiKeys[id] = key
+		}
+	}
+	return nil
+}
+
+type dataRequests struct{ s *Store }
+
+func (r dataRequests) Create(ctx context.Context, request *models.DataRequest) error {
+	defer r.s.lock()()
+	request.ID = r.s.data.id()
+	request.CreatedAt, request.UpdatedAt = time.Now(), time.Now()
+	r.s.data.dataRequests[request.ID] = *request
+	return nil
+}
+
+func (r dataRequests) List(ctx context.Context, userID uint) ([]models.DataRequest, error) {
+	defer r.s.lock()()
+	list := list(r.s.data.dataRequests, func(d models.DataRequest) bool { return userID == 0 || d.UserID == userID })
+	slices.Reverse(list)
+	return list, nil
+}
+
+type auditLogs struct{ s *Store }
+
+func (r auditLogs) Create(ctx context.Context, entry *models.AuditLog) error {
+	defer r.s.lock()()
+	entry.ID = uint(len(r.s.data.auditLogs) + 1)
+	entry.CreatedAt = time.Now()
+	r.s.data.auditLogs = append(r.s.data.auditLogs, *entry)
+	return nil
+}
+
+func (r auditLogs) List(ctx context.Context, filter repositories.AuditLogFilter) ([]models.AuditLog, error) {
+	defer r.s.lock()()
+	var entries []models.AuditLog
+	for i := len(r.s.data.auditLogs) - 1; i >= 0; i-- {
+		entry := r.s.data.auditLogs[i]
+		switch {
+		case filter.ActorID != 0 && entry.ActorID != filter.ActorID,
+			filter.Action != "" && entry.Action != filter.Action,
+			filter.TargetType != "" && entry.TargetType != filter.TargetType,
+			filter.TargetID != 0 && entry.TargetID != filter.TargetID,
+			!filter.Since.IsZero() && entry.CreatedAt.Before(filter.Since),
+			!filter.Until.IsZero() && entry.CreatedAt.After(filter.Until):
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	entries = entries[min(filter.Offset, len(entries)):]
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
@@ -0,0 +1,298 @@
+// Package memory implements the repositories in memory, for tests that
+// exercise the services and handlers without a database. Records are copied
+// in and out, so callers never share state with the store.
+package memory
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+)
+
+// data is everything the store holds
+type data struct {
+	products       map[uint]models.Product
+	orders         map[uint]models.Order
+	users          map[uint]models.User
+	sessions       map[uint]models.Session
+	refreshTokens  map[uint]models.RefreshToken
+	passwordResets map[uint]models.PasswordResetToken
+	recoveryCodes  map[uint]models.RecoveryCode
+	identities     map[uint]models.UserIdentity
+	oauthStates    map[uint]models.OAuthState
+	usedTokens     map[string]models.UsedToken
+	apiKeys        map[uint]models.APIKey
+	dataRequests   map[uint]models.DataRequest
+	auditLogs      []models.AuditLog
+	nextID         uint
+}
+
+func newData() *data {
+	return &data{
+		products:       map[uint]models.Product{},
+		orders:         map[uint]models.Order{},
+		users:          map[uint]models.User{},
+		sessions:       map[uint]models.Session{},
+		refreshTokens:  map[uint]models.RefreshToken{},
+		passwordResets: map[uint]models.PasswordResetToken{},
+		recoveryCodes:  map[uint]models.RecoveryCode{},
+		identities:     map[uint]models.UserIdentity{},
+		oauthStates:    map[uint]models.OAuthState{},
+		usedTokens:     map[string]models.UsedToken{},
+		apiKeys:        map[uint]models.APIKey{},
+		dataRequests:   map[uint]models.DataRequest{},
+	}
+}
+
+func (d *data) clone() *data {
+	c := &data{
+		products:       maps.Clone(d.products),
+		orders:         make(map[uint]models.Order, len(d.orders)),
+		users:          maps.Clone(d.users),
+		sessions:       maps.Clone(d.sessions),
+		refreshTokens:  maps.Clone(d.refreshTokens),
+		passwordResets: maps.Clone(d.passwordResets),
+		recoveryCodes:  maps.Clone(d.recoveryCodes),
+		identities:     maps.Clone(d.identities),
+		oauthStates:    maps.Clone(d.oauthStates),
+		usedTokens:     maps.Clone(d.usedTokens),
+		apiKeys:        maps.Clone(d.apiKeys),
+		dataRequests:   maps.Clone(d.dataRequests),
+		auditLogs:      append([]models.AuditLog(nil), d.auditLogs...),
+		nextID:         d.nextID,
+	}
+	for id, o := range d.orders {
+		o.Items = append([]models.OrderItem(nil), o.Items...)
+		c.orders[id] = o
+	}
+	return c
+}
+
+func (d *data) id() uint {
+	d.nextID++
+	return d.nextID
+}
+
+// Store implements repositories.Store. The zero value is not usable; call
+// NewStore.
+type Store struct {
+	mu   *sync.Mutex
+	data *data
+
+	// inTx is set on the store passed to Atomic, which already holds mu
+	inTx bool
+}
+
+// NewStore returns an empty store
+func NewStore() *Store {
+	return &Store{mu: &sync.Mutex{}, data: newData()}
+}
+
+func (s *Store) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *Store) Products() repositories.ProductRepository             { return products{s} }
+func (s *Store) Orders() repositories.OrderRepository                 { return orders{s} }
+func (s *Store) Users() repositories.UserRepository                   { return users{s} }
+func (s *Store) Sessions() repositories.SessionRepository             { return sessions{s} }
+func (s *Store) RefreshTokens() repositories.RefreshTokenRepository   { return refreshTokens{s} }
+func (s *Store) PasswordResets() repositories.PasswordResetRepository { return passwordResets{s} }
+func (s *Store) RecoveryCodes() repositories.RecoveryCodeRepository   { return recoveryCodes{s} }
+func (s *Store) Identities() repositories.IdentityRepository          { return identities{s} }
+func (s *Store) OAuthStates() repositories.OAuthStateRepository       { return oauthStates{s} }
+func (s *Store) UsedTokens() repositories.UsedTokenRepository         { return usedTokens{s} }
+func (s *Store) APIKeys() repositories.APIKeyRepository               { return apiKeys{s} }
+func (s *Store) DataRequests() repositories.DataRequestRepository     { return dataRequests{s} }
+func (s *Store) AuditLogs() repositories.AuditLogRepository           { return auditLogs{s} }
+
+// Atomic runs fn on a copy of the data that replaces the original only if fn
+// succeeds. Atomic calls are serialized.
+func (s *Store) Atomic(ctx context.Context, fn func(repositories.Store) error) error {
+	defer s.lock()()
+	tx := &Store{mu: s.mu, data: s.data.clone(), inTx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	*s.data = *tx.data
+	return nil
+}
+
+// AuditLogEntries returns the entries written so far, oldest first
+func (s *Store) AuditLogEntries() []models.AuditLog {
+	defer s.lock()()
+	return append([]models.AuditLog(nil), s.data.auditLogs...)
+}
+
+type products struct{ s *Store }
+
+func (r products) List(ctx context.Context) ([]models.Product, error) {
+	defer r.s.lock()()
+	list := make([]models.Product, 0, len(r.s.data.products))
+	for _, p := range r.s.data.products {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (r products) Get(ctx context.Context, id uint) (*models.Product, error) {
+	defer r.s.lock()()
+	p, ok := r.s.data.products[id]
+	if !ok {
+		return nil, repositories.ErrNotFound
+	}
+	return &p, nil
+}
+
+func (r products) GetByName(ctx context.Context, name string) (*models.Product, error) {
+	defer r.s.lock()()
+	for _, p := range r.s.data.products {
+		if p.Name == name {
+			return &p, nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (r products) GetBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	defer r.s.lock()()
+	for _, p := range r.s.data.products {
+		if p.SKU != nil && *p.SKU == sku {
+			return &p, nil
+		}
+	}
+	return nil, repositories.ErrNotFound
+}
+
+func (r products) Create(ctx context.Context, product *models.Product) error {
+	defer r.s.lock()()
+	product.ID = r.s.data.id()
+	product.CreatedAt, product.UpdatedAt = time.Now(), time.Now()
+	r.s.data.products[product.ID] = *product
+	return nil
+}
+
+func (r products) Update(ctx context.Context, product *models.Product, columns ...string) error {
+	defer r.s.lock()()
+	stored, ok := r.s.data.products[product.ID]
+	if !ok {
+		return repositories.ErrNotFound
+	}
+	product.UpdatedAt = time.Now()
+	setColumns(&stored, product, append(columns, "updated_at"))
+	r.s.data.products[product.ID] = stored
+	return nil
+}
+
+func (r products) Delete(ctx context.Context, product *models.Product) error {
+	defer r.s.lock()()
+	delete(r.s.data.products, product.ID)
+	return nil
+}
+
+func (r products) AdjustStock(ctx context.Context, id uint, delta int) (bool, error) {
+	defer r.s.lock()()
+	p, ok := r.s.data.products[id]
+	if !ok || p.Stock+delta < 0 {
+		return false, nil
+	}
+	p.Stock += delta
+	p.UpdatedAt = time.Now()
+	r.s.data.products[id] = p
+	return true, nil
+}
+
+func (r products) Each(ctx context.Context, fn func(*models.Product) error) error {
+	// fn runs without the lock, so it may use the store
+	list, _ := r.List(ctx)
+	for i := range list {
+		if err := fn(&list[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type orders struct{ s *Store }
+
+func (r orders) ListByUser(ctx context.Context, userID uint) ([]models.Order, error) {
+	defer r.s.lock()()
+	var list []models.Order
+	for _, o := range r.s.data.orders {
+		if o.UserID == userID {
+			o.Items = append([]models.OrderItem(nil), o.Items...)
+			list = append(list, o)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (r orders) Get(ctx context.Context, id uint) (*models.Order, error) {
+	defer r.s.lock()()
+	o, ok := r.s.data.orders[id]
+	if !ok {
+		return nil, repositories.ErrNotFound
+	}
+	o.Items = append([]models.OrderItem(nil), o.Items...)
+	return &o, nil
+}
+
+func (r orders) Create(ctx context.Context, order *models.Order) error {
+	defer r.s.lock()()
+	now := time.Now()
+	order.ID = r.s.data.id()
+	order.CreatedAt, order.UpdatedAt = now, now
+	if order.Status == "" {
+		order.Status = models.OrderPending
+	}
+	for i := range order.Items {
+		order.Items[i].ID = r.s.data.id()
+		order.Items[i].OrderID = order.ID
+		order.Items[i].CreatedAt, order.Items[i].UpdatedAt = now, now
+	}
+	stored := *order
+	stored.Items = append([]models.OrderItem(nil), order.Items...)
+	r.s.data.orders[order.ID] = stored
+	return nil
+}
+
+func (r orders) SetStatus(ctx context.Context, id uint, from, to string) (bool, error) {
+	defer r.s.lock()()
+	o, ok := r.s.data.orders[id]
+	if !ok || o.Status != from {
+		return false, nil
+	}
+	o.Status = to
+	o.UpdatedAt = time.Now()
+	r.s.data.orders[id] = o
+	return true, nil
+}
+
+func (r orders) Each(ctx context.Context, fn func(*models.Order) error) error {
+	unlock := r.s.lock()
+	list := make([]models.Order, 0, len(r.s.data.orders))
+	for _, o := range r.s.data.orders {
+		o.Items = append([]models.OrderItem(nil), o.Items...)
+		list = append(list, o)
+	}
+	unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	// fn runs without the lock, so it may use the store
+	for i := range list {
+		if err := fn(&list[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
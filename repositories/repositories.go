@@ -0,0 +1,228 @@
+// Package repositories defines how the services load and store data. The
+// GORM implementations are used by the server; package memory provides
+// in-memory fakes for tests.
+package repositories
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ProductRepository stores the catalog
+type ProductRepository interface {
+	List(ctx context.Context) ([]models.Product, error)
+	Get(ctx context.Context, id uint) (*models.Product, error)
+	GetByName(ctx context.Context, name string) (*models.Product, error)
+	GetBySKU(ctx context.Context, sku string) (*models.Product, error)
+	Create(ctx context.Context, product *models.Product) error
+
+	// Update writes the named columns of product
+	Update(ctx context.Context, product *models.Product, columns ...string) error
+	Delete(ctx context.Context, product *models.Product) error
+
+	// AdjustStock adds delta to the stock of a product unless the stock
+	// would become negative, and reports whether it did
+	AdjustStock(ctx context.Context, id uint, delta int) (bool, error)
+
+	// Each calls fn for every product in ID order without loading the whole
+	// catalog at once, and stops at the first error fn returns
+	Each(ctx context.Context, fn func(*models.Product) error) error
+}
+
+// OrderRepository stores orders. Orders are loaded with their items.
+type OrderRepository interface {
+	ListByUser(ctx context.Context, userID uint) ([]models.Order, error)
+	Get(ctx context.Context, id uint) (*models.Order, error)
+
+	// Create stores an order together with its items
+	Create(ctx context.Context, order *models.Order) error
+
+	// SetStatus changes the status of an order from one value to another and
+	// reports whether it did; it does not when the status is no longer from
+	SetStatus(ctx context.Context, id uint, from, to string) (bool, error)
+
+	// Each calls fn for every order in ID order without loading them all at
+	// once, and stops at the first error fn returns
+	Each(ctx context.Context, fn func(*models.Order) error) error
+}
+
+// UserRepository stores user accounts
+type UserRepository interface {
+	Get(ctx context.Context, id uint) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+
+	// Update writes the named columns of user
+	Update(ctx context.Context, user *models.User, columns ...string) error
+
+	// Delete soft-deletes a user; orders keep referencing the row
+	Delete(ctx context.Context, user *models.User) error
+
+	// RecordLoginFailure counts a failed login of a user at the given time
+	// and returns the new count. The count starts over when the previous
+	// failure happened before since. Concurrent failures are all counted.
+	RecordLoginFailure(ctx context.Context, id uint, at, since time.Time) (int, error)
+
+	// SetTOTPStep stores step as the last TOTP step a user has used, unless
+	// the same or a later step is stored already, and reports whether it did
+	SetTOTPStep(ctx context.Context, id uint, step int64) (bool, error)
+}
+
+// SessionRepository stores login sessions
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	Get(ctx context.Context, id uint) (*models.Session, error)
+
+	// ListByUser returns every session of a user, newest first
+	ListByUser(ctx context.Context, userID uint) ([]models.Session, error)
+
+	// ActiveIDs returns the IDs of the sessions of a user that are not revoked
+	ActiveIDs(ctx context.Context, userID uint) ([]uint, error)
+
+	// Touch records that a session has just been used
+	Touch(ctx context.Context, id uint) error
+
+	// Revoke marks the sessions as revoked, leaving already revoked ones alone
+	Revoke(ctx context.Context, ids ...uint) error
+
+	// Anonymize clears the user agent and IP of every session of a user
+	Anonymize(ctx context.Context, userID uint) error
+}
+
+// RefreshTokenRepository stores refresh tokens by their hash
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+
+	// MarkUsed marks an unused token as used and reports whether it did
+	MarkUsed(ctx context.Context, id uint) (bool, error)
+}
+
+// PasswordResetRepository stores password reset tokens by their hash
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token *models.PasswordResetToken) error
+	GetByHash(ctx context.Context, hash string) (*models.PasswordResetToken, error)
+
+	// MarkUsed marks an unused token as used and reports whether it did
+	MarkUsed(ctx context.Context, id uint) (bool, error)
+
+	// MarkAllUsed invalidates every unused token of a user
+	MarkAllUsed(ctx context.Context, userID uint) error
+
+	DeleteByUser(ctx context.Context, userID uint) error
+}
+
+// RecoveryCodeRepository stores two-factor recovery codes by their hash
+type RecoveryCodeRepository interface {
+	Create(ctx context.Context, code *models.RecoveryCode) error
+
+	// Use marks the unused code of a user with the given hash as used and
+	// reports whether there was one
+	Use(ctx context.Context, userID uint, hash string) (bool, error)
+
+	DeleteByUser(ctx context.Context, userID uint) error
+}
+
+// IdentityRepository stores the external identities linked to accounts
+type IdentityRepository interface {
+	Get(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	ListByUser(ctx context.Context, userID uint) ([]models.UserIdentity, error)
+	Create(ctx context.Context, identity *models.UserIdentity) error
+	DeleteByUser(ctx context.Context, userID uint) error
+}
+
+// OAuthStateRepository stores the state of social logins in progress
+type OAuthStateRepository interface {
+	Create(ctx context.Context, state *models.OAuthState) error
+
+	// Take deletes the state of a provider and returns it, so that each state
+	// is used once
+	Take(ctx context.Context, state, provider string) (*models.OAuthState, error)
+
+	// DeleteExpired deletes the states that expired before t
+	DeleteExpired(ctx context.Context, t time.Time) error
+}
+
+// UsedTokenRepository records the IDs of single-use signed tokens
+type UsedTokenRepository interface {
+	// Use records the ID of a token valid until expiresAt and reports whether
+	// it was not recorded before
+	Use(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+
+	// DeleteExpired deletes the records of tokens that expired before t
+	DeleteExpired(ctx context.Context, t time.Time) error
+}
+
+// APIKeyRepository stores API keys
+type APIKeyRepository interface {
+	// List returns every key in ID order
+	List(ctx context.Context) ([]models.APIKey, error)
+	ListByUser(ctx context.Context, userID uint) ([]models.APIKey, error)
+	Get(ctx context.Context, id uint) (*models.APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	Create(ctx context.Context, key *models.APIKey) error
+
+	// Update writes the named columns of key
+	Update(ctx context.Context, key *models.APIKey, columns ...string) error
+
+	// Touch records when a key was last used, leaving updated_at alone
+	Touch(ctx context.Context, id uint, at time.Time) error
+
+	// RevokeByUser revokes every active key of a user
+	RevokeByUser(ctx context.Context, userID uint) error
+}
+
+// DataRequestRepository stores the trail of data exports and erasures
+type DataRequestRepository interface {
+	Create(ctx context.Context, request *models.DataRequest) error
+
+	// List returns the requests about a user, or all requests when userID is
+	// 0, newest first
+	List(ctx context.Context, userID uint) ([]models.DataRequest, error)
+}
+
+// AuditLogFilter selects audit log entries. Zero fields match every entry.
+type AuditLogFilter struct {
+	ActorID    uint
+	Action     string
+	TargetType string
+	TargetID   uint
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
+
+// AuditLogRepository appends to and reads the audit log
+type AuditLogRepository interface {
+	Create(ctx context.Context, entry *models.AuditLog) error
+
+	// List returns the entries matching filter, newest first
+	List(ctx context.Context, filter AuditLogFilter) ([]models.AuditLog, error)
+}
+
+// Store gives access to every repository
+type Store interface {
+	Products() ProductRepository
+	Orders() OrderRepository
+	Users() UserRepository
+	Sessions() SessionRepository
+	RefreshTokens() RefreshTokenRepository
+	PasswordResets() PasswordResetRepository
+	RecoveryCodes() RecoveryCodeRepository
+	Identities() IdentityRepository
+	OAuthStates() OAuthStateRepository
+	UsedTokens() UsedTokenRepository
+	APIKeys() APIKeyRepository
+	DataRequests() DataRequestRepository
+	AuditLogs() AuditLogRepository
+
+	// Atomic runs fn with a store whose changes are all kept when fn
+	// succeeds and all discarded when it fails
+	Atomic(ctx context.Context, fn func(Store) error) error
+}
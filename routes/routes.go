@@ -1,13 +1,100 @@
 package routes
 
 import (
+	"ecommerce-api/config"
 	"ecommerce-api/controllers"
 	"ecommerce-api/middlewares"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"net/http"
+	"time"
 
+	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(router *gin.Engine) {
+// Handlers are the handler structs behind the routes
+type Handlers struct {
+	Products  *controllers.ProductHandler
+	Orders    *controllers.OrderHandler
+	Users     *controllers.UserHandler
+	Auth      *controllers.AuthHandler
+	Account   *controllers.AccountHandler
+	APIKeys   *controllers.APIKeyHandler
+	AuditLogs *controllers.AuditLogHandler
+	Health    *controllers.HealthHandler
+	JWKS      *controllers.JWKSHandler
+
+	// Authenticate is the AuthMiddleware for the session and API key services
+	Authenticate gin.HandlerFunc
+
+	// Admin is the AdminMiddleware with the configured two-factor policy
+	Admin func(scopes ...string) gin.HandlerFunc
+}
+
+// Dependencies are what the handlers are built from
+type Dependencies struct {
+	Config    *config.Config
+	Store     repositories.Store
+	Tokens    *utils.TokenService
+	Mailer    utils.Mailer
+	Providers map[string]*utils.OIDCProvider
+	Health    *utils.HealthRegistry
+}
+
+// NewHandlers wires the services on deps.Store into the handlers
+func NewHandlers(deps Dependencies) Handlers {
+	store, server, auth := deps.Store, deps.Config.Server, deps.Config.Auth
+	cache := utils.NewSessionRevocationCache(30 * time.Second)
+
+	orders := services.NewOrderService(store)
+	orders.RequireVerifiedEmail = auth.RequireVerifiedEmailForOrders
+
+	users := services.NewUserService(store, deps.Tokens, cache)
+	users.Mailer = deps.Mailer
+	users.BaseURL = server.BaseURL
+
+	sessions := services.NewSessionService(store, deps.Tokens, cache)
+	authService := services.NewAuthService(store, deps.Tokens, sessions, services.LoginPolicy{
+		MaxFailures:   auth.LoginMaxFailures,
+		LockoutBase:   auth.LoginLockoutBase,
+		LockoutMax:    auth.LoginLockoutMax,
+		IPMaxFailures: auth.LoginIPMaxFailures,
+		IPWindow:      auth.LoginIPWindow,
+	})
+	authService.Providers = deps.Providers
+
+	privacy := services.NewPrivacyService(store, cache, authService)
+	keys := services.NewAPIKeyService(store)
+	return Handlers{
+		Products:     controllers.NewProductHandler(services.NewProductService(store)),
+		Orders:       controllers.NewOrderHandler(orders),
+		Users:        controllers.NewUserHandler(users, privacy),
+		Auth:         controllers.NewAuthHandler(authService, sessions, users, server.BaseURL),
+		Account:      controllers.NewAccountHandler(users, sessions, services.NewMFAService(store, cache, authService), privacy),
+		APIKeys:      controllers.NewAPIKeyHandler(keys),
+		AuditLogs:    controllers.NewAuditLogHandler(services.NewAuditLogService(store)),
+		Health:       controllers.NewHealthHandler(deps.Health, server.HealthCheckTimeout),
+		JWKS:         controllers.NewJWKSHandler(deps.Tokens),
+		Authenticate: middlewares.AuthMiddleware(deps.Tokens, sessions, keys),
+		Admin: func(scopes ...string) gin.HandlerFunc {
+			return middlewares.AdminMiddleware(auth.RequireAdminMFA, scopes...)
+		},
+	}
+}
+
+// NewRouter returns the API router with its global middlewares. Middlewares
+// must be registered before the routes they apply to.
+func NewRouter(server config.ServerConfig, h Handlers) *gin.Engine {
+	router := gin.New()
+	router.Use(middlewares.RequestID(), middlewares.ProblemTypes(server.ProblemTypeBaseURL), middlewares.Tracing(), middlewares.AccessLog(), middlewares.Metrics(), middlewares.Recovery(), cors.Default())
+	SetupRoutes(router, h)
+	return router
+}
+
+func SetupRoutes(router *gin.Engine, h Handlers) {
 
 	// Serve Swagger UI files from the swagger-ui directory
 	router.Static("/swagger-ui", "./swagger-ui/dist") // Serve the Swagger UI static files
@@ -24,24 +111,86 @@ func SetupRoutes(router *gin.Engine) {
 	// // Wrap the Swagger handler and serve it under the /swagger path
 	// router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
 
-	api := router.Group("/api/v1")
+	// Unknown routes use the same error format as the API
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(c *gin.Context) {
+		utils.RespondError(c, http.StatusNotFound, utils.CodeNotFound, "Route not found")
+	})
+	router.NoMethod(func(c *gin.Context) {
+		utils.RespondError(c, http.StatusMethodNotAllowed, utils.CodeMethodNotAllowed, "Method not allowed")
+	})
+
+	// Probes for the orchestrator, outside the API rate limits
+	router.GET("/healthz", h.Health.Liveness)
+	router.GET("/readyz", h.Health.Readiness)
+
+	// Public keys for verifying our JWTs
+	router.GET("/.well-known/jwks.json", h.JWKS.JWKS)
+
+	// Rate limiting policies
+	apiLimit := middlewares.RateLimit(middlewares.RateLimitPolicy{Name: "api", Limit: 300, Period: time.Minute, Key: middlewares.KeyByIP})
+	authLimit := middlewares.RateLimit(middlewares.RateLimitPolicy{Name: "auth", Limit: 10, Period: time.Minute, Key: middlewares.KeyByIP})
+	catalogLimit := middlewares.RateLimit(middlewares.RateLimitPolicy{Name: "catalog", Limit: 60, Period: time.Minute, Key: middlewares.KeyByIP})
+	userLimit := middlewares.RateLimit(middlewares.RateLimitPolicy{Name: "user", Limit: 120, Period: time.Minute, Key: middlewares.KeyByAPIKey})
+
+	api := router.Group("/api/v1", apiLimit)
 	{
 
 		// api.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 		// User routes
-		api.POST("/register", controllers.RegisterUser)
-		api.POST("/login", controllers.Login)
+		api.POST("/register", authLimit, h.Users.RegisterUser)
+		api.POST("/login", authLimit, h.Auth.Login)
 
-		// Product routes
-		api.GET("/products", controllers.GetProducts)
-		api.POST("/products", middlewares.AuthMiddleware(), middlewares.AdminMiddleware(), controllers.CreateProduct)
-		api.PUT("/products/:id", middlewares.AuthMiddleware(), middlewares.AdminMiddleware(), controllers.UpdateProduct)
-		api.DELETE("/products/:id", middlewares.AuthMiddleware(), middlewares.AdminMiddleware(), controllers.DeleteProduct)
+		// Auth routes
+		auth := api.Group("/auth", authLimit)
+		auth.POST("/login/mfa", h.Auth.LoginMFA)
+		auth.POST("/refresh", h.Auth.RefreshToken)
+		auth.POST("/logout", h.Authenticate, middlewares.SessionOnly(), h.Auth.Logout)
+		auth.GET("/verify-email", h.Auth.VerifyEmail)
+		auth.POST("/resend-verification", h.Auth.ResendVerification)
+		auth.POST("/forgot-password", h.Auth.ForgotPassword)
+		auth.POST("/reset-password", h.Auth.ResetPassword)
+		auth.GET("/oidc/providers", h.Auth.ListOIDCProviders)
+		auth.GET("/oidc/:provider/login", h.Auth.OIDCLogin)
+		auth.GET("/oidc/:provider/callback", h.Auth.OIDCCallback)
+
+		// Account routes for the authenticated user
+		me := api.Group("/me", h.Authenticate, middlewares.SessionOnly(), userLimit)
+		me.GET("", h.Account.GetProfile)
+		me.PUT("", h.Account.UpdateProfile)
+		me.DELETE("", h.Account.DeleteMyAccount)
+		me.GET("/export", h.Account.ExportMyData)
+		me.POST("/email", h.Account.ChangeEmail)
+		me.GET("/sessions", h.Account.GetSessions)
+		me.DELETE("/sessions/:id", h.Account.RevokeMySession)
+		me.PUT("/password", h.Account.ChangePassword)
+		me.POST("/2fa/enroll", h.Account.EnrollMFA)
+		me.POST("/2fa/confirm", h.Account.ConfirmMFA)
+		me.DELETE("/2fa", h.Account.DisableMFA)
+
+		// Product routes (API keys need the listed scope)
+		api.GET("/products", catalogLimit, h.Products.GetProducts)
+		api.POST("/products", h.Authenticate, userLimit, h.Admin(models.ScopeProductsWrite), h.Products.CreateProduct)
+		api.PUT("/products/:id", h.Authenticate, userLimit, h.Admin(models.ScopeProductsWrite), h.Products.UpdateProduct)
+		api.DELETE("/products/:id", h.Authenticate, userLimit, h.Admin(models.ScopeProductsWrite), h.Products.DeleteProduct)
+		api.POST("/products/import", h.Authenticate, userLimit, h.Admin(models.ScopeProductsWrite), h.Products.ImportProducts)
+		api.GET("/products/export", h.Authenticate, userLimit, h.Admin(models.ScopeProductsRead), h.Products.ExportProducts)
 
 		// Order routes
-		api.POST("/orders", middlewares.AuthMiddleware(), controllers.CreateOrder)
-		api.GET("/orders", middlewares.AuthMiddleware(), controllers.GetOrders)
-		api.PUT("/orders/:id", middlewares.AuthMiddleware(), middlewares.AdminMiddleware(), controllers.UpdateOrderStatus)
-		api.DELETE("/orders/:id", middlewares.AuthMiddleware(), controllers.CancelOrder)
+		api.POST("/orders", h.Authenticate, userLimit, middlewares.RequireScope(models.ScopeOrdersWrite), h.Orders.CreateOrder)
+		api.GET("/orders", h.Authenticate, userLimit, middlewares.RequireScope(models.ScopeOrdersRead), h.Orders.GetOrders)
+		api.PUT("/orders/:id", h.Authenticate, userLimit, h.Admin(models.ScopeOrdersManage), h.Orders.UpdateOrderStatus)
+		api.DELETE("/orders/:id", h.Authenticate, userLimit, middlewares.RequireScope(models.ScopeOrdersWrite), h.Orders.CancelOrder)
+
+		// Admin routes (user tokens only)
+		admin := api.Group("/admin", h.Authenticate, userLimit, h.Admin())
+		admin.POST("/api-keys", h.APIKeys.CreateAPIKey)
+		admin.GET("/api-keys", h.APIKeys.GetAPIKeys)
+		admin.DELETE("/api-keys/:id", h.APIKeys.RevokeAPIKey)
+		admin.POST("/users/:id/unlock", h.Users.UnlockUser)
+		admin.GET("/users/:id/export", h.Users.ExportUserData)
+		admin.DELETE("/users/:id", h.Users.DeleteUser)
+		admin.GET("/data-requests", h.Users.GetDataRequests)
+		admin.GET("/audit-logs", h.AuditLogs.GetAuditLogs)
 	}
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/services"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// demoProducts is the catalog created by "ecommerce-api seed"
+var demoProducts = []services.ProductInput{
+	{Name: "Classic T-Shirt", Description: "Soft cotton t-shirt in navy blue", Price: 19.99, Stock: 120},
+	{Name: "Hooded Sweatshirt", Description: "Heavyweight fleece hoodie", Price: 49.5, Stock: 60},
+	{Name: "Denim Jacket", Description: "Stonewashed denim jacket", Price: 89, Stock: 25},
+	{Name: "Running Shoes", Description: "Lightweight shoes for road running", Price: 119.95, Stock: 40},
+	{Name: "Canvas Backpack", Description: "20 litre backpack with a laptop sleeve", Price: 64, Stock: 35},
+	{Name: "Wool Beanie", Description: "Ribbed merino wool beanie", Price: 24.9, Stock: 80},
+	{Name: "Water Bottle", Description: "Insulated steel bottle, 750 ml", Price: 29.99, Stock: 150},
+	{Name: "Sunglasses", Description: "Polarized sunglasses with a case", Price: 75, Stock: 30},
+}
+
+// runSeed implements "ecommerce-api seed" and returns the exit code
+func runSeed(args []string) int {
+	flagSet := flag.NewFlagSet("ecommerce-api seed", flag.ContinueOnError)
+	adminEmail := flagSet.String("admin-email", "admin@example.com", "email `address` of the demo administrator")
+	customerEmail := flagSet.String("customer-email", "customer@example.com", "email `address` of the demo customer")
+	password := flagSet.String("password", "", "`password` of the demo users; generated and printed when empty")
+	flagSet.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: ecommerce-api seed [flags]\n\n"+
+			"Create a demo catalog, an administrator and a customer. Records that\n"+
+			"already exist are left as they are, so seeding twice is harmless.\n\nflags:\n")
+		flagSet.PrintDefaults()
+	}
+	cfg, code, ok := loadConfig(flagSet, args)
+	if !ok {
+		return code
+	}
+	if *password == "" {
+		var err error
+		if *password, err = generatePassword(); err != nil {
+			fmt.Fprintln(os.Stderr, "generate password:", err)
+			return 1
+		}
+	}
+	if len(*password) < minPasswordLength {
+		fmt.Fprintf(os.Stderr, "the password must have at least %d characters\n", minPasswordLength)
+		return 2
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+	store, closeDB, err := openStore(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeDB()
+
+	if err := seed(ctx, store, *adminEmail, *customerEmail, *password); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// seed creates the demo users and catalog, skipping those that exist
+func seed(ctx context.Context, store repositories.Store, adminEmail, customerEmail, password string) error {
+	users := services.NewUserService(store, nil, nil)
+	var admin *models.User
+	for _, account := range []struct {
+		email   string
+		isAdmin bool
+	}{{adminEmail, true}, {customerEmail, false}} {
+		user, err := users.Register(ctx, account.email, password, account.isAdmin)
+		if errors.Is(err, services.ErrEmailTaken) {
+			fmt.Printf("User %s exists, skipped\n", account.email)
+			if user, err = store.Users().GetByEmail(ctx, account.email); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return fmt.Errorf("create user %s: %w", account.email, err)
+		} else {
+			// Demo accounts can place orders right away
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+			if err := store.Users().Update(ctx, user, "email_verified_at"); err != nil {
+				return err
+			}
+			fmt.Printf("User %s created with password %s\n", user.Email, password)
+		}
+		if account.isAdmin {
+			admin = user
+		}
+	}
+
+	products := services.NewProductService(store)
+	created := 0
+	for _, input := range demoProducts {
+		_, err := products.Create(ctx, services.Actor{UserID: admin.ID}, input)
+		if errors.Is(err, services.ErrProductNameTaken) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("create product %s: %w", input.Name, err)
+		}
+		created++
+	}
+	fmt.Printf("%d of %d demo products created\n", created, len(demoProducts))
+	return nil
+}
@@ -0,0 +1,23 @@
+package services
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+)
+
+// AuditLogService reads the audit log. Entries are written by the services
+// that make the audited changes.
+type AuditLogService struct {
+	store repositories.Store
+}
+
+// NewAuditLogService returns an audit log service on store
+func NewAuditLogService(store repositories.Store) *AuditLogService {
+	return &AuditLogService{store: store}
+}
+
+// List returns the entries matching filter, newest first
+func (s *AuditLogService) List(ctx context.Context, filter repositories.AuditLogFilter) ([]models.AuditLog, error) {
+	return s.store.AuditLogs().List(ctx, filter)
+}
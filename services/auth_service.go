@@ -0,0 +1,442 @@
+package services
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/utils"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Login settings
+var (
+	// LoginFailureWindow resets the failure count when no failure happened for this long
+	LoginFailureWindow = time.Hour
+
+	// MFAChallengeTTL is how long the password step of a 2FA login stays valid
+	MFAChallengeTTL = 5 * time.Minute
+
+	// OAuthStateTTL is how long a user has to complete the provider login
+	OAuthStateTTL = 10 * time.Minute
+)
+
+// LoginPolicy is the brute-force protection of logins
+type LoginPolicy struct {
+	// MaxFailures is the number of consecutive failures before an account is locked
+	MaxFailures int
+
+	// LockoutBase is the first lockout; every further failure doubles it up to LockoutMax
+	LockoutBase time.Duration
+	LockoutMax  time.Duration
+
+	// IPMaxFailures failures per client IP within IPWindow throttle the IP
+	IPMaxFailures int
+	IPWindow      time.Duration
+}
+
+// LoginResult is the outcome of a first-factor login: the tokens of a new
+// session, or for accounts with two-factor authentication a short-lived
+// challenge to complete with LoginMFA
+type LoginResult struct {
+	Tokens    *Tokens
+	Challenge string
+}
+
+// AuthService signs users in with a password, a second factor or an external
+// identity
+type AuthService struct {
+	store    repositories.Store
+	tokens   *utils.TokenService
+	sessions *SessionService
+	policy   LoginPolicy
+	limiter  *utils.AttemptLimiter
+
+	// Providers are the OpenID Connect providers available for social login
+	Providers map[string]*utils.OIDCProvider
+}
+
+// NewAuthService returns an auth service on store that signs two-factor
+// challenges with tokens and opens sessions with sessions
+func NewAuthService(store repositories.Store, tokens *utils.TokenService, sessions *SessionService, policy LoginPolicy) *AuthService {
+	return &AuthService{
+		store:    store,
+		tokens:   tokens,
+		sessions: sessions,
+		policy:   policy,
+		limiter:  utils.NewAttemptLimiter(policy.IPMaxFailures, policy.IPWindow),
+	}
+}
+
+// Login checks an email and password
+func (s *AuthService) Login(ctx context.Context, email, password string, client Client) (*LoginResult, error) {
+	// Throttle clients with too many recent failures
+	if err := s.throttled(client); err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.Users().GetByEmail(ctx, email)
+	if errors.Is(err, repositories.ErrNotFound) {
+		// Spend the same bcrypt time as for a known email to avoid user enumeration
+		utils.VerifyPasswordDummy(password)
+		s.fail(ctx, nil, client)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkPassword(ctx, user, password, client)
+	if errors.Is(err, ErrPasswordIncorrect) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// With two-factor authentication the failure count is only cleared once the second factor succeeds
+	if !user.MFAEnabled() {
+		s.succeed(ctx, user, client)
+	}
+	return s.complete(ctx, user, client)
+}
+
+// LoginMFA completes a login with the challenge from Login and a TOTP code or
+// a recovery code. Each challenge allows a single attempt, so a leaked
+// challenge cannot be used to guess codes or replayed after the login.
+func (s *AuthService) LoginMFA(ctx context.Context, challenge, code, recoveryCode string, client Client) (*Tokens, error) {
+	if err := s.throttled(client); err != nil {
+		return nil, err
+	}
+
+	claims, err := s.tokens.VerifyPurposeToken(challenge, utils.PurposeMFAChallenge)
+	if err != nil || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, ErrMFAChallengeInvalid
+	}
+	if err := s.store.UsedTokens().DeleteExpired(ctx, time.Now()); err != nil {
+		return nil, err
+	}
+	first, err := s.store.UsedTokens().Use(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if !first {
+		return nil, ErrMFAChallengeInvalid
+	}
+
+	user, err := s.store.Users().Get(ctx, claims.UserID)
+	if err != nil || !user.MFAEnabled() {
+		return nil, ErrMFAChallengeInvalid
+	}
+
+	if err := s.checkSecondFactor(ctx, user, code, recoveryCode, client); err != nil {
+		return nil, err
+	}
+	s.succeed(ctx, user, client)
+
+	return s.sessions.Start(ctx, user, true, client)
+}
+
+// OIDCProviders returns the names of the social login providers
+func (s *AuthService) OIDCProviders() []string {
+	names := make([]string, 0, len(s.Providers))
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartOIDC starts an authorization code flow with PKCE. It returns the URL
+// of the provider's login page and the state, which the callback must
+// present from the same browser.
+func (s *AuthService) StartOIDC(ctx context.Context, providerName string) (authURL, state string, err error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return "", "", ErrLoginProviderNotFound
+	}
+
+	secrets := make([]string, 3)
+	for i := range secrets {
+		if secrets[i], _, err = utils.GenerateOpaqueToken(); err != nil {
+			return "", "", err
+		}
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+
+	authURL, err = provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrLoginProviderUnavailable, err)
+	}
+
+	// Abandoned logins leave their state behind
+	if err := s.store.OAuthStates().DeleteExpired(ctx, time.Now()); err != nil {
+		utils.Logger(ctx).Warn("Failed to delete expired login states", "error", err)
+	}
+
+	err = s.store.OAuthStates().Create(ctx, &models.OAuthState{
+		State:        state,
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(OAuthStateTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// FinishOIDC completes the authorization code flow and signs the user in.
+// Unknown identities are linked to the account with the same verified email,
+// or a new customer account.
+func (s *AuthService) FinishOIDC(ctx context.Context, providerName, state, code string, client Client) (*LoginResult, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return nil, ErrLoginProviderNotFound
+	}
+
+	// Consume the state so it cannot be replayed
+	record, err := s.store.OAuthStates().Take(ctx, state, provider.Name)
+	if err != nil {
+		return nil, notFound(err, ErrLoginStateInvalid)
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, ErrLoginStateExpired
+	}
+
+	claims, err := provider.Exchange(ctx, code, record.CodeVerifier, record.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLoginRejected, err)
+	}
+
+	user, err := s.linkIdentity(ctx, provider.Name, claims)
+	if err != nil {
+		return nil, err
+	}
+	return s.complete(ctx, user, client)
+}
+
+// linkIdentity finds the user for an external identity
+func (s *AuthService) linkIdentity(ctx context.Context, provider string, claims *utils.IDTokenClaims) (*models.User, error) {
+	identity, err := s.store.Identities().Get(ctx, provider, claims.Subject)
+	if err == nil {
+		return s.store.Users().Get(ctx, identity.UserID)
+	}
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+
+	email, verified := claims.VerifiedEmail()
+	if !verified {
+		return nil, ErrIdentityEmailUnverified
+	}
+
+	var user *models.User
+	var revoked []uint
+	err = s.store.Atomic(ctx, func(store repositories.Store) error {
+		var err error
+		user, err = store.Users().GetByEmail(ctx, email)
+		switch {
+		case errors.Is(err, repositories.ErrNotFound):
+			// Social accounts get an unusable random password until they reset it
+			hashedPassword, err := unusablePassword()
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			user = &models.User{Email: email, Password: hashedPassword, EmailVerifiedAt: &now}
+			if err := store.Users().Create(ctx, user); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case !user.EmailVerified():
+			// Whoever registered the unverified account never proved they own
+			// the address; the provider just did. Drop every credential they
+			// may have set up so a pre-registered account cannot be taken over.
+			if revoked, err = resetCredentials(ctx, store, user); err != nil {
+				return err
+			}
+		}
+
+		return store.Identities().Create(ctx, &models.UserIdentity{
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    email,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	markRevoked(s.sessions.cache, revoked...)
+	return user, nil
+}
+
+// complete finishes a first-factor login (password or external identity).
+// Accounts with two-factor authentication get a challenge, others a session.
+func (s *AuthService) complete(ctx context.Context, user *models.User, client Client) (*LoginResult, error) {
+	if user.MFAEnabled() {
+		challenge, err := s.tokens.GeneratePurposeToken(utils.PurposeMFAChallenge, user.ID, user.Email, MFAChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{Challenge: challenge}, nil
+	}
+
+	tokens, err := s.sessions.Start(ctx, user, false, client)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: tokens}, nil
+}
+
+// CheckPassword confirms the password of a signed-in user before a sensitive
+// change. It is throttled and counts failures like Login.
+func (s *AuthService) CheckPassword(ctx context.Context, user *models.User, password string, client Client) error {
+	if err := s.throttled(client); err != nil {
+		return err
+	}
+	return s.checkPassword(ctx, user, password, client)
+}
+
+// CheckSecondFactor confirms a TOTP code or recovery code of a signed-in user
+// before a sensitive change. It is throttled and counts failures like
+// LoginMFA.
+func (s *AuthService) CheckSecondFactor(ctx context.Context, user *models.User, code, recoveryCode string, client Client) error {
+	if err := s.throttled(client); err != nil {
+		return err
+	}
+	return s.checkSecondFactor(ctx, user, code, recoveryCode, client)
+}
+
+// checkPassword verifies the password of user and counts a failure. A locked
+// account gets the same answer whether the password is right or wrong, after
+// the same bcrypt time, so guesses cannot be told apart.
+func (s *AuthService) checkPassword(ctx context.Context, user *models.User, password string, client Client) error {
+	if err := locked(user); err != nil {
+		utils.VerifyPasswordDummy(password)
+		s.fail(ctx, user, client)
+		return err
+	}
+	if err := utils.VerifyPassword(user.Password, password); err != nil {
+		s.fail(ctx, user, client)
+		return ErrPasswordIncorrect
+	}
+	return nil
+}
+
+// checkSecondFactor verifies a code of user. Failed codes count towards the
+// account lockout like failed passwords.
+func (s *AuthService) checkSecondFactor(ctx context.Context, user *models.User, code, recoveryCode string, client Client) error {
+	if err := locked(user); err != nil {
+		s.fail(ctx, user, client)
+		return err
+	}
+	err := verifySecondFactor(ctx, s.store, user, code, recoveryCode)
+	if errors.Is(err, ErrMFACodeInvalid) {
+		s.fail(ctx, user, client)
+	}
+	return err
+}
+
+// throttled refuses clients whose IP has too many recent failures
+func (s *AuthService) throttled(client Client) error {
+	if blocked, wait := s.limiter.Blocked(client.IP); blocked {
+		return &RetryError{Err: ErrTooManyAttempts, Wait: wait}
+	}
+	return nil
+}
+
+// locked refuses logins to an account that is temporarily locked
+func locked(user *models.User) error {
+	if locked, wait := user.Locked(); locked {
+		return &RetryError{Err: ErrAccountLocked, Wait: wait}
+	}
+	return nil
+}
+
+// fail counts a failed attempt for the client IP and, when known, the
+// account. Accounts are locked with exponential backoff once MaxFailures is
+// reached; attempts on a locked account keep counting and extend the lockout.
+func (s *AuthService) fail(ctx context.Context, user *models.User, client Client) {
+	utils.LoginFailures.Inc()
+	s.limiter.Fail(client.IP)
+	if user == nil {
+		return
+	}
+
+	now := time.Now()
+	failures, err := s.store.Users().RecordLoginFailure(ctx, user.ID, now, now.Add(-LoginFailureWindow))
+	if err != nil {
+		utils.Logger(ctx).Error("Failed to record login failure", "error", err)
+		return
+	}
+	if failures < s.policy.MaxFailures {
+		return
+	}
+
+	lockout := time.Duration(float64(s.policy.LockoutBase) * math.Pow(2, float64(failures-s.policy.MaxFailures)))
+	if lockout <= 0 || lockout > s.policy.LockoutMax {
+		lockout = s.policy.LockoutMax
+	}
+	until := now.Add(lockout)
+	if user.LockedUntil != nil && user.LockedUntil.After(until) {
+		return
+	}
+	user.LockedUntil = &until
+	if err := s.store.Users().Update(ctx, user, "locked_until"); err != nil {
+		utils.Logger(ctx).Error("Failed to lock account", "error", err)
+	}
+}
+
+// succeed clears the failure counts after a successful login
+func (s *AuthService) succeed(ctx context.Context, user *models.User, client Client) {
+	s.limiter.Reset(client.IP)
+	if user.FailedLoginCount == 0 && user.LockedUntil == nil {
+		return
+	}
+	user.FailedLoginCount, user.LastFailedLoginAt, user.LockedUntil = 0, nil, nil
+	if err := s.store.Users().Update(ctx, user, "failed_login_count", "last_failed_login_at", "locked_until"); err != nil {
+		utils.Logger(ctx).Error("Failed to reset login failures", "error", err)
+	}
+}
+
+// unusablePassword returns the hash of a random password nobody knows
+func unusablePassword() (string, error) {
+	password, _, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return utils.HashPassword(password)
+}
+
+// resetCredentials marks the email of user as verified and discards the
+// password, two-factor setup, reset links and sessions of the account. It
+// returns the revoked session IDs for markRevoked.
+func resetCredentials(ctx context.Context, store repositories.Store, user *models.User) ([]uint, error) {
+	hashedPassword, err := unusablePassword()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	user.Password, user.EmailVerifiedAt, user.PendingEmail = hashedPassword, &now, ""
+	user.TOTPSecret, user.TOTPEnabledAt, user.TOTPLastStep = "", nil, 0
+	err = store.Users().Update(ctx, user, "password", "email_verified_at", "pending_email",
+		"totp_secret", "totp_enabled_at", "totp_last_step")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.RecoveryCodes().DeleteByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if err := store.PasswordResets().MarkAllUsed(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	return revokeUserSessions(ctx, store, user.ID)
+}
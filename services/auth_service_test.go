@@ -0,0 +1,178 @@
+package services_test
+
+import (
+	"context"
+	"ecommerce-api/repositories/memory"
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newAuth returns the auth and session services on store, with the lockout
+// after three failures and the IP throttle after ten
+func newAuth(t *testing.T, store *memory.Store) (*services.AuthService, *services.SessionService, *utils.TokenService) {
+	t.Helper()
+	tokens, err := utils.NewTokenService(utils.TokenConfig{Issuer: "test", Audience: "test", Secret: "services-test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := services.NewSessionService(store, tokens, nil)
+	auth := services.NewAuthService(store, tokens, sessions, services.LoginPolicy{
+		MaxFailures:   3,
+		LockoutBase:   time.Minute,
+		LockoutMax:    time.Hour,
+		IPMaxFailures: 10,
+		IPWindow:      time.Minute,
+	})
+	return auth, sessions, tokens
+}
+
+// register creates an account with password "correct-horse"
+func register(t *testing.T, store *memory.Store, email string) {
+	t.Helper()
+	if _, err := services.NewUserService(store, nil, nil).Register(context.Background(), email, "correct-horse", false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthServiceLogin(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	auth, sessions, tokens := newAuth(t, store)
+	register(t, store, "ann@example.com")
+	client := services.Client{IP: "192.0.2.1"}
+
+	if _, err := auth.Login(ctx, "nobody@example.com", "correct-horse", client); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Errorf("Login() with an unknown email error = %v, want %v", err, services.ErrInvalidCredentials)
+	}
+
+	result, err := auth.Login(ctx, "ann@example.com", "correct-horse", client)
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if result.Challenge != "" || result.Tokens == nil {
+		t.Fatalf("Login() = %+v, want tokens", result)
+	}
+	claims, err := tokens.VerifyJWT(result.Tokens.Token)
+	if err != nil {
+		t.Fatalf("VerifyJWT() error = %v", err)
+	}
+	if revoked, err := sessions.Revoked(ctx, claims.SessionID); err != nil || revoked {
+		t.Errorf("Revoked() = %v, %v, want an active session", revoked, err)
+	}
+}
+
+func TestAuthServiceLockout(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	auth, _, _ := newAuth(t, store)
+	register(t, store, "ann@example.com")
+	client := services.Client{IP: "192.0.2.1"}
+
+	for i := 0; i < 3; i++ {
+		if _, err := auth.Login(ctx, "ann@example.com", "wrong", client); !errors.Is(err, services.ErrInvalidCredentials) {
+			t.Fatalf("failure %d error = %v, want %v", i+1, err, services.ErrInvalidCredentials)
+		}
+	}
+
+	// The right password no longer helps until the lockout ends
+	_, err := auth.Login(ctx, "ann@example.com", "correct-horse", client)
+	var retry *services.RetryError
+	if !errors.Is(err, services.ErrAccountLocked) || !errors.As(err, &retry) {
+		t.Fatalf("Login() of a locked account error = %v, want %v", err, services.ErrAccountLocked)
+	}
+	if retry.Wait <= 0 || retry.Wait > time.Minute {
+		t.Errorf("Wait = %v, want up to the first lockout of 1m", retry.Wait)
+	}
+}
+
+func TestAuthServiceLockoutHidesPassword(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	auth, _, _ := newAuth(t, store)
+	register(t, store, "ann@example.com")
+	client := services.Client{IP: "192.0.2.1"}
+
+	for i := 0; i < 3; i++ {
+		auth.Login(ctx, "ann@example.com", "wrong", client)
+	}
+
+	// During the lockout a right and a wrong password get the same answer
+	for _, password := range []string{"wrong", "correct-horse"} {
+		_, err := auth.Login(ctx, "ann@example.com", password, client)
+		if !errors.Is(err, services.ErrAccountLocked) {
+			t.Errorf("Login() with %q during the lockout error = %v, want %v", password, err, services.ErrAccountLocked)
+		}
+	}
+
+	// Both attempts counted, so the lockout doubled twice
+	user, err := store.Users().GetByEmail(ctx, "ann@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.FailedLoginCount != 5 {
+		t.Errorf("FailedLoginCount = %d, want 5", user.FailedLoginCount)
+	}
+	if _, wait := user.Locked(); wait <= 3*time.Minute {
+		t.Errorf("lockout = %v, want more than 3m after two more failures", wait)
+	}
+}
+
+func TestAuthServiceThrottlesIP(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	auth, _, _ := newAuth(t, store)
+	register(t, store, "ann@example.com")
+	client := services.Client{IP: "192.0.2.1"}
+
+	// Failures against unknown accounts count towards the IP only
+	for i := 0; i < 10; i++ {
+		auth.Login(ctx, "nobody@example.com", "wrong", client)
+	}
+	if _, err := auth.Login(ctx, "ann@example.com", "correct-horse", client); !errors.Is(err, services.ErrTooManyAttempts) {
+		t.Errorf("Login() from a throttled IP error = %v, want %v", err, services.ErrTooManyAttempts)
+	}
+	if _, err := auth.Login(ctx, "ann@example.com", "correct-horse", services.Client{IP: "192.0.2.2"}); err != nil {
+		t.Errorf("Login() from another IP error = %v", err)
+	}
+}
+
+func TestSessionServiceRefresh(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	auth, sessions, tokens := newAuth(t, store)
+	register(t, store, "ann@example.com")
+	result, err := auth.Login(ctx, "ann@example.com", "correct-horse", services.Client{IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := result.Tokens
+
+	second, err := sessions.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("Refresh() did not rotate the refresh token")
+	}
+	if _, err := sessions.Refresh(ctx, "unknown"); !errors.Is(err, services.ErrRefreshTokenInvalid) {
+		t.Errorf("Refresh() of an unknown token error = %v, want %v", err, services.ErrRefreshTokenInvalid)
+	}
+
+	// Reusing a refresh token revokes the whole session, including its newest tokens
+	if _, err := sessions.Refresh(ctx, first.RefreshToken); !errors.Is(err, services.ErrRefreshTokenReused) {
+		t.Fatalf("Refresh() with a used token error = %v, want %v", err, services.ErrRefreshTokenReused)
+	}
+	if _, err := sessions.Refresh(ctx, second.RefreshToken); !errors.Is(err, services.ErrRefreshTokenInvalid) {
+		t.Errorf("Refresh() in a revoked session error = %v, want %v", err, services.ErrRefreshTokenInvalid)
+	}
+	claims, err := tokens.VerifyJWT(second.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := sessions.Revoked(ctx, claims.SessionID); err != nil || !revoked {
+		t.Errorf("Revoked() = %v, %v, want a revoked session", revoked, err)
+	}
+}
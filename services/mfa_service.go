@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/utils"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Two-factor authentication settings
+var (
+	// MFAIssuer is the account issuer shown in authenticator apps
+	MFAIssuer = "Ecommerce API"
+
+	// recoveryCodeCount is the number of backup codes issued on enrolment
+	recoveryCodeCount = 10
+)
+
+// MFAService enrols users in TOTP two-factor authentication
+type MFAService struct {
+	store repositories.Store
+	cache *utils.SessionRevocationCache
+	auth  *AuthService
+}
+
+// NewMFAService returns a two-factor service on store. Sessions it revokes
+// are recorded in cache, which may be nil. Passwords and codes are checked
+// through auth, under the brute-force protection of logins.
+func NewMFAService(store repositories.Store, cache *utils.SessionRevocationCache, auth *AuthService) *MFAService {
+	return &MFAService{store: store, cache: cache, auth: auth}
+}
+
+// Enroll generates a TOTP secret for a user and returns it with the
+// otpauth URI to scan. It is only enforced once confirmed.
+func (s *MFAService) Enroll(ctx context.Context, userID uint) (secret, uri string, err error) {
+	user, err := s.store.Users().Get(ctx, userID)
+	if err != nil {
+		return "", "", notFound(err, ErrUserNotFound)
+	}
+	if user.MFAEnabled() {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err = utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	user.TOTPSecret, user.TOTPLastStep = secret, 0
+	if err := s.store.Users().Update(ctx, user, "totp_secret", "totp_last_step"); err != nil {
+		return "", "", err
+	}
+	return secret, utils.TOTPURI(MFAIssuer, user.Email, secret), nil
+}
+
+// Confirm enables two-factor authentication with a first code and returns
+// new recovery codes. Every session of the user is revoked: sessions
+// established with the password alone, possibly by whoever prompted the user
+// to turn on 2FA, must not outlive it.
+func (s *MFAService) Confirm(ctx context.Context, userID uint, code string) (*models.User, []string, error) {
+	user, err := s.store.Users().Get(ctx, userID)
+	if err != nil {
+		return nil, nil, notFound(err, ErrUserNotFound)
+	}
+	if user.MFAEnabled() {
+		return nil, nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, nil, ErrMFANotEnrolled
+	}
+
+	var codes []string
+	var revoked []uint
+	err = s.store.Atomic(ctx, func(store repositories.Store) error {
+		if err := verifySecondFactor(ctx, store, user, code, ""); err != nil {
+			return err
+		}
+		now := time.Now()
+		user.TOTPEnabledAt = &now
+		if err := store.Users().Update(ctx, user, "totp_enabled_at"); err != nil {
+			return err
+		}
+		var err error
+		if revoked, err = revokeUserSessions(ctx, store, user.ID); err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(ctx, store, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	markRevoked(s.cache, revoked...)
+	return user, codes, nil
+}
+
+// Disable turns off two-factor authentication after checking the password
+// and a current code or recovery code. Failures count towards the login
+// lockout, so a stolen session cannot be used to guess the password.
+func (s *MFAService) Disable(ctx context.Context, userID uint, password, code, recoveryCode string, client Client) error {
+	user, err := s.store.Users().Get(ctx, userID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if !user.MFAEnabled() {
+		return ErrMFANotEnabled
+	}
+	err = s.auth.CheckPassword(ctx, user, password, client)
+	if err == nil {
+		err = s.auth.CheckSecondFactor(ctx, user, code, recoveryCode, client)
+	}
+	if errors.Is(err, ErrPasswordIncorrect) || errors.Is(err, ErrMFACodeInvalid) {
+		return ErrInvalidCredentials
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.store.Atomic(ctx, func(store repositories.Store) error {
+		if err := store.RecoveryCodes().DeleteByUser(ctx, user.ID); err != nil {
+			return err
+		}
+		user.TOTPSecret, user.TOTPEnabledAt, user.TOTPLastStep = "", nil, 0
+		return store.Users().Update(ctx, user, "totp_secret", "totp_enabled_at", "totp_last_step")
+	})
+}
+
+// verifySecondFactor checks a TOTP code or consumes a recovery code for the user
+func verifySecondFactor(ctx context.Context, store repositories.Store, user *models.User, code, recoveryCode string) error {
+	if code != "" {
+		step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if !ok || step <= user.TOTPLastStep {
+			return ErrMFACodeInvalid
+		}
+		// Remember the step so the same code cannot be replayed
+		stored, err := store.Users().SetTOTPStep(ctx, user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !stored {
+			return ErrMFACodeInvalid
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	if recoveryCode != "" {
+		used, err := store.RecoveryCodes().Use(ctx, user.ID, utils.HashToken(strings.ToLower(strings.TrimSpace(recoveryCode))))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrMFACodeInvalid
+		}
+		return nil
+	}
+
+	return ErrMFACodeInvalid
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and generates a new set
+func replaceRecoveryCodes(ctx context.Context, store repositories.Store, userID uint) ([]string, error) {
+	if err := store.RecoveryCodes().DeleteByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if err := store.RecoveryCodes().Create(ctx, &models.RecoveryCode{UserID: userID, CodeHash: utils.HashToken(code)}); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/utils"
+	"math"
+)
+
+// orderTransitions lists the statuses an order may move to from each status.
+// Delivered and canceled orders are final.
+var orderTransitions = map[string][]string{
+	models.OrderPending: {models.OrderShipped, models.OrderDelivered, models.OrderCanceled},
+	models.OrderShipped: {models.OrderDelivered, models.OrderCanceled},
+}
+
+// OrderService places orders and moves them through their statuses. Stock is
+// reserved when an order is placed and released when it is canceled.
+type OrderService struct {
+	store repositories.Store
+
+	// RequireVerifiedEmail refuses orders from users who have not confirmed
+	// their email address
+	RequireVerifiedEmail bool
+}
+
+// NewOrderService returns an order service on store
+func NewOrderService(store repositories.Store) *OrderService {
+	return &OrderService{store: store}
+}
+
+// MaxItemQuantity is the largest quantity of a single order item
+const MaxItemQuantity = 10000
+
+// OrderItemInput is one line of a new order
+type OrderItemInput struct {
+	ProductID uint
+	Quantity  int
+}
+
+// Place creates an order for userID priced at the current product prices.
+// When total is given it must match that price, so a client never pays an
+// amount it did not see.
+func (s *OrderService) Place(ctx context.Context, userID uint, items []OrderItemInput, total *float64) (*models.Order, error) {
+	// A negative quantity would add stock and a negative total
+	for _, item := range items {
+		if item.Quantity <= 0 || item.Quantity > MaxItemQuantity {
+			return nil, &ItemError{ProductID: item.ProductID, Err: ErrQuantityInvalid}
+		}
+	}
+
+	if s.RequireVerifiedEmail {
+		user, err := s.store.Users().Get(ctx, userID)
+		if err != nil {
+			return nil, notFound(err, ErrUserNotFound)
+		}
+		if !user.EmailVerified() {
+			return nil, ErrEmailNotVerified
+		}
+	}
+
+	order := &models.Order{UserID: userID, Status: models.OrderPending}
+	err := s.store.Atomic(ctx, func(store repositories.Store) error {
+		var cents int64
+		for _, item := range items {
+			product, err := store.Products().Get(ctx, item.ProductID)
+			if err != nil {
+				return &ItemError{ProductID: item.ProductID, Err: notFound(err, ErrProductNotFound)}
+			}
+			reserved, err := store.Products().AdjustStock(ctx, product.ID, -item.Quantity)
+			if err != nil {
+				return err
+			}
+			if !reserved {
+				return &ItemError{ProductID: item.ProductID, Err: ErrInsufficientStock}
+			}
+			cents += toCents(product.Price) * int64(item.Quantity)
+			order.Items = append(order.Items, models.OrderItem{ProductID: product.ID, Quantity: item.Quantity})
+		}
+
+		order.Total = float64(cents) / 100
+		if total != nil && toCents(*total) != cents {
+			return ErrOrderTotalMismatch
+		}
+		return store.Orders().Create(ctx, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	utils.OrdersCreated.Inc()
+	utils.Revenue.Add(order.Total)
+	return order, nil
+}
+
+// ListForUser returns the orders of userID
+func (s *OrderService) ListForUser(ctx context.Context, userID uint) ([]models.Order, error) {
+	return s.store.Orders().ListByUser(ctx, userID)
+}
+
+// Cancel cancels an order on behalf of its owner, which is only possible
+// while it has not shipped
+func (s *OrderService) Cancel(ctx context.Context, userID, orderID uint) (*models.Order, error) {
+	order, err := s.store.Orders().Get(ctx, orderID)
+	if err != nil {
+		return nil, notFound(err, ErrOrderNotFound)
+	}
+	if order.UserID != userID {
+		return nil, ErrOrderNotOwned
+	}
+	if order.Status != models.OrderCanceled && order.Status != models.OrderPending {
+		return nil, &StatusError{From: order.Status, To: models.OrderCanceled}
+	}
+	if err := s.changeStatus(ctx, order, models.OrderCanceled, nil); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// UpdateStatus moves an order to status on behalf of an administrator
+func (s *OrderService) UpdateStatus(ctx context.Context, actor Actor, orderID uint, status string) (*models.Order, error) {
+	if !ValidOrderStatus(status) {
+		return nil, ErrOrderStatusInvalid
+	}
+	order, err := s.store.Orders().Get(ctx, orderID)
+	if err != nil {
+		return nil, notFound(err, ErrOrderNotFound)
+	}
+	if err := s.changeStatus(ctx, order, status, &actor); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// ValidOrderStatus reports whether status is a known order status
+func ValidOrderStatus(status string) bool {
+	switch status {
+	case models.OrderPending, models.OrderShipped, models.OrderDelivered, models.OrderCanceled:
+		return true
+	}
+	return false
+}
+
+// changeStatus applies a status change allowed by orderTransitions, releases
+// the stock of canceled orders and audits changes made by an administrator
+func (s *OrderService) changeStatus(ctx context.Context, order *models.Order, status string, actor *Actor) error {
+	if order.Status == models.OrderCanceled && status == models.OrderCanceled {
+		return ErrOrderAlreadyCanceled
+	}
+	allowed := false
+	for _, next := range orderTransitions[order.Status] {
+		allowed = allowed || next == status
+	}
+	if !allowed {
+		return &StatusError{From: order.Status, To: status}
+	}
+
+	before := *order
+	before.Items = nil
+	order.Status = status
+	err := s.store.Atomic(ctx, func(store repositories.Store) error {
+		// Only one of several concurrent changes wins, so stock is released once
+		changed, err := store.Orders().SetStatus(ctx, order.ID, before.Status, status)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return ErrOrderStatusChanged
+		}
+		if status == models.OrderCanceled {
+			for _, item := range order.Items {
+				// The product may have been deleted since; there is no stock to return then
+				if _, err := store.Products().AdjustStock(ctx, item.ProductID, item.Quantity); err != nil {
+					return err
+				}
+			}
+		}
+		if actor == nil {
+			return nil
+		}
+		after := *order
+		after.Items = nil
+		return audit(ctx, store, *actor, "order.update_status", "order", order.ID, before, after)
+	})
+	if err != nil {
+		order.Status = before.Status
+		return err
+	}
+
+	if status == models.OrderCanceled {
+		utils.OrdersCanceled.Inc()
+		utils.RevenueCanceled.Add(order.Total)
+	}
+	return nil
+}
+
+// toCents converts a price to whole cents, so totals add up exactly
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
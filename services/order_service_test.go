@@ -0,0 +1,232 @@
+package services_test
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories/memory"
+	"ecommerce-api/services"
+	"errors"
+	"testing"
+)
+
+// addProduct stores a product with the given price and stock
+func addProduct(t *testing.T, store *memory.Store, name string, price float64, stock int) *models.Product {
+	t.Helper()
+	product := &models.Product{Name: name, Price: price, Stock: stock}
+	if err := store.Products().Create(context.Background(), product); err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	return product
+}
+
+// stockOf returns the current stock of a product
+func stockOf(t *testing.T, store *memory.Store, id uint) int {
+	t.Helper()
+	product, err := store.Products().Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get product: %v", err)
+	}
+	return product.Stock
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestOrderServicePlace(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     func(a, b *models.Product) []services.OrderItemInput
+		total     *float64
+		wantErr   error
+		wantTotal float64
+		wantStock [2]int
+	}{
+		{
+			name: "prices the items in cents",
+			items: func(a, b *models.Product) []services.OrderItemInput {
+				return []services.OrderItemInput{{ProductID: a.ID, Quantity: 3}, {ProductID: b.ID, Quantity: 1}}
+			},
+			wantTotal: 0.4,
+			wantStock: [2]int{7, 4},
+		},
+		{
+			name: "accepts the total the client saw",
+			items: func(a, b *models.Product) []services.OrderItemInput {
+				return []services.OrderItemInput{{ProductID: a.ID, Quantity: 1}}
+			},
+			total:     floatPtr(0.1),
+			wantTotal: 0.1,
+			wantStock: [2]int{9, 5},
+		},
+		{
+			name: "rejects a total that differs from the prices",
+			items: func(a, b *models.Product) []services.OrderItemInput {
+				return []services.OrderItemInput{{ProductID: a.ID, Quantity: 1}}
+			},
+			total:     floatPtr(0.05),
+			wantErr:   services.ErrOrderTotalMismatch,
+			wantStock: [2]int{10, 5},
+		},
+		{
+			name: "rejects a quantity of zero",
+			items: func(a, b *models.Product) []services.OrderItemInput {
+				return []services.OrderItemInput{{ProductID: a.ID, Quantity: 0}}
+			},
+			wantErr:   services.ErrQuantityInvalid,
+			wantStock: [2]int{10, 5},
+		},
+		{
+			name: "rejects a negative quantity",
+			items: func(a, b *models.Product) []services.OrderItemInput {
+				return []services.OrderItemInput{{ProductID: a.ID, Quantity: -5}}
+			},
+			wantErr:   services.ErrQuantityInvalid,
+			wantStock: [2]int{10, 5},
+		},
+		{
+			name: "rejects a quantity above the maximum",
+			items: func(a, b *models.Product) []services.OrderItemInput {
+				return []services.OrderItemInput{{ProductID: a.ID, Quantity: services.MaxItemQuantity + 1}}
+			},
+			wantErr:   services.ErrQuantityInvalid,
+			wantStock: [2]int{10, 5},
+		},
+		{
+			name: "reserves nothing when one item is out of stock",
+			items: func(a, b *models.Product) []services.OrderItemInput {
+				return []services.OrderItemInput{{ProductID: a.ID, Quantity: 2}, {ProductID: b.ID, Quantity: 6}}
+			},
+			wantErr:   services.ErrInsufficientStock,
+			wantStock: [2]int{10, 5},
+		},
+		{
+			name: "rejects an unknown product",
+			items: func(a, b *models.Product) []services.OrderItemInput {
+				return []services.OrderItemInput{{ProductID: 999, Quantity: 1}}
+			},
+			wantErr:   services.ErrProductNotFound,
+			wantStock: [2]int{10, 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memory.NewStore()
+			a := addProduct(t, store, "A", 0.1, 10)
+			b := addProduct(t, store, "B", 0.1, 5)
+
+			order, err := services.NewOrderService(store).Place(ctx, 1, tt.items(a, b), tt.total)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Place() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && order.Total != tt.wantTotal {
+				t.Errorf("Total = %v, want %v", order.Total, tt.wantTotal)
+			}
+			if got := [2]int{stockOf(t, store, a.ID), stockOf(t, store, b.ID)}; got != tt.wantStock {
+				t.Errorf("stock = %v, want %v", got, tt.wantStock)
+			}
+		})
+	}
+}
+
+func TestOrderServicePlaceRequiresVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	product := addProduct(t, store, "A", 1, 10)
+	user := &models.User{Email: "new@example.com", Password: "x"}
+	if err := store.Users().Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	orders := services.NewOrderService(store)
+	orders.RequireVerifiedEmail = true
+	_, err := orders.Place(ctx, user.ID, []services.OrderItemInput{{ProductID: product.ID, Quantity: 1}}, nil)
+	if !errors.Is(err, services.ErrEmailNotVerified) {
+		t.Fatalf("Place() error = %v, want %v", err, services.ErrEmailNotVerified)
+	}
+}
+
+func TestOrderServiceCancel(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	product := addProduct(t, store, "A", 2, 10)
+	orders := services.NewOrderService(store)
+	order, err := orders.Place(ctx, 1, []services.OrderItemInput{{ProductID: product.ID, Quantity: 4}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := orders.Cancel(ctx, 2, order.ID); !errors.Is(err, services.ErrOrderNotOwned) {
+		t.Fatalf("Cancel() by another user error = %v, want %v", err, services.ErrOrderNotOwned)
+	}
+
+	canceled, err := orders.Cancel(ctx, 1, order.ID)
+	if err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if canceled.Status != models.OrderCanceled {
+		t.Errorf("Status = %q, want %q", canceled.Status, models.OrderCanceled)
+	}
+	if got := stockOf(t, store, product.ID); got != 10 {
+		t.Errorf("stock after cancel = %d, want 10", got)
+	}
+
+	// The stock is returned only once
+	if _, err := orders.Cancel(ctx, 1, order.ID); !errors.Is(err, services.ErrOrderAlreadyCanceled) {
+		t.Fatalf("second Cancel() error = %v, want %v", err, services.ErrOrderAlreadyCanceled)
+	}
+	if got := stockOf(t, store, product.ID); got != 10 {
+		t.Errorf("stock after second cancel = %d, want 10", got)
+	}
+}
+
+func TestOrderServiceUpdateStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    []string
+		wantErr error
+	}{
+		{name: "ships then delivers", path: []string{models.OrderShipped, models.OrderDelivered}},
+		{name: "cancels a shipped order", path: []string{models.OrderShipped, models.OrderCanceled}},
+		{name: "rejects an unknown status", path: []string{"Lost"}, wantErr: services.ErrOrderStatusInvalid},
+		{name: "rejects leaving a final status", path: []string{models.OrderDelivered, models.OrderShipped}, wantErr: services.ErrOrderStatusInvalid},
+		{name: "rejects going back to pending", path: []string{models.OrderShipped, models.OrderPending}, wantErr: services.ErrOrderStatusInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memory.NewStore()
+			product := addProduct(t, store, "A", 2, 10)
+			orders := services.NewOrderService(store)
+			order, err := orders.Place(ctx, 1, []services.OrderItemInput{{ProductID: product.ID, Quantity: 1}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			admin := services.Actor{UserID: 99}
+			for i, status := range tt.path {
+				_, err = orders.UpdateStatus(ctx, admin, order.ID, status)
+				if i < len(tt.path)-1 && err != nil {
+					t.Fatalf("UpdateStatus(%q) error = %v", status, err)
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateStatus() error = %v, want %v", err, tt.wantErr)
+			}
+
+			// Every applied change is audited with its actor
+			applied := len(tt.path)
+			if tt.wantErr != nil {
+				applied--
+			}
+			entries := store.AuditLogEntries()
+			if len(entries) != applied {
+				t.Fatalf("audit log has %d entries, want %d", len(entries), applied)
+			}
+			for _, entry := range entries {
+				if entry.ActorID != admin.UserID || entry.Action != "order.update_status" {
+					t.Errorf("audit entry = %+v", entry)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/utils"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ReauthWindow is how recent the login of a session must be to delete an
+// account that was created through an external identity without its password
+var ReauthWindow = 10 * time.Minute
+
+// UserExport is everything stored about a user. There is no address model:
+// shipping details are not stored yet.
+type UserExport struct {
+	User         models.User
+	Orders       []models.Order
+	Sessions     []models.Session
+	Identities   []models.UserIdentity
+	APIKeys      []models.APIKey
+	DataRequests []models.DataRequest
+}
+
+// PrivacyService exports and erases personal data on request (GDPR articles
+// 15, 17 and 20) and keeps the trail of those requests
+type PrivacyService struct {
+	store repositories.Store
+	cache *utils.SessionRevocationCache
+	auth  *AuthService
+}
+
+// NewPrivacyService returns a privacy service on store. Sessions it revokes
+// are recorded in cache, which may be nil. Erasures are confirmed through
+// auth, under the brute-force protection of logins.
+func NewPrivacyService(store repositories.Store, cache *utils.SessionRevocationCache, auth *AuthService) *PrivacyService {
+	return &PrivacyService{store: store, cache: cache, auth: auth}
+}
+
+// Export collects the data of a user for actor, who is the user themselves
+// or an admin, and records the request
+func (s *PrivacyService) Export(ctx context.Context, actor Actor, userID uint) (*UserExport, error) {
+	user, err := s.store.Users().Get(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	export := &UserExport{User: *user}
+	if export.Orders, err = s.store.Orders().ListByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if export.Sessions, err = s.store.Sessions().ListByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if export.Identities, err = s.store.Identities().ListByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if export.APIKeys, err = s.store.APIKeys().ListByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if export.DataRequests, err = s.store.DataRequests().List(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	if err := recordDataRequest(ctx, s.store, actor, user.ID, models.DataRequestExport); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// ConfirmErasure re-authenticates a user before their own account is erased.
+// Accounts created through an external identity have a random password
+// nobody knows, so when an identity is linked a two-factor code or a login
+// to sessionID within ReauthWindow are accepted as well. Failed passwords
+// and codes count towards the login lockout.
+func (s *PrivacyService) ConfirmErasure(ctx context.Context, user *models.User, sessionID uint, password, code, recoveryCode string, client Client) error {
+	if password != "" {
+		return s.auth.CheckPassword(ctx, user, password, client)
+	}
+
+	identities, err := s.store.Identities().ListByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if len(identities) == 0 {
+		return ErrPasswordIncorrect
+	}
+
+	if code != "" || recoveryCode != "" {
+		if !user.MFAEnabled() {
+			return ErrMFANotEnabled
+		}
+		return s.auth.CheckSecondFactor(ctx, user, code, recoveryCode, client)
+	}
+
+	session, err := s.store.Sessions().Get(ctx, sessionID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrReauthRequired
+	}
+	if err != nil {
+		return err
+	}
+	if time.Since(session.CreatedAt) > ReauthWindow {
+		return ErrReauthRequired
+	}
+	return nil
+}
+
+// Erase anonymises the personal data of a user and signs them out
+// everywhere. Orders are kept for accounting and still reference the
+// (soft-deleted) user row. Erasures by an admin are audited, without the
+// erased data.
+func (s *PrivacyService) Erase(ctx context.Context, actor Actor, userID uint) error {
+	user, err := s.store.Users().Get(ctx, userID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+
+	var revoked []uint
+	err = s.store.Atomic(ctx, func(store repositories.Store) error {
+		var err error
+		if revoked, err = revokeUserSessions(ctx, store, user.ID); err != nil {
+			return err
+		}
+		if err := store.Sessions().Anonymize(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := store.APIKeys().RevokeByUser(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := store.Identities().DeleteByUser(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := store.RecoveryCodes().DeleteByUser(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := store.PasswordResets().DeleteByUser(ctx, user.ID); err != nil {
+			return err
+		}
+
+		// The anonymised email keeps the unique index satisfied; the empty
+		// password hash never matches
+		now := time.Now()
+		user.Email, user.Password = fmt.Sprintf("deleted-user-%d@invalid", user.ID), ""
+		user.Name, user.Phone, user.PendingEmail = "", "", ""
+		user.MarketingConsent, user.MarketingConsentAt = false, nil
+		user.EmailVerifiedAt, user.VerificationSentAt = nil, nil
+		user.TOTPSecret, user.TOTPEnabledAt, user.TOTPLastStep = "", nil, 0
+		user.AnonymizedAt = &now
+		err = store.Users().Update(ctx, user, "email", "password", "name", "phone", "pending_email",
+			"marketing_consent", "marketing_consent_at", "email_verified_at", "verification_sent_at",
+			"totp_secret", "totp_enabled_at", "totp_last_step", "anonymized_at")
+		if err != nil {
+			return err
+		}
+		if err := store.Users().Delete(ctx, user); err != nil {
+			return err
+		}
+
+		if err := recordDataRequest(ctx, store, actor, user.ID, models.DataRequestErasure); err != nil {
+			return err
+		}
+		if actor.UserID != user.ID {
+			return audit(ctx, store, actor, "user.erase", "user", user.ID, nil, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	markRevoked(s.cache, revoked...)
+	return nil
+}
+
+// DataRequests lists the trail of exports and erasures, newest first, for one
+// user or for all users when userID is 0
+func (s *PrivacyService) DataRequests(ctx context.Context, userID uint) ([]models.DataRequest, error) {
+	return s.store.DataRequests().List(ctx, userID)
+}
+
+// recordDataRequest adds an entry to the data request trail
+func recordDataRequest(ctx context.Context, store repositories.Store, actor Actor, userID uint, requestType string) error {
+	return store.DataRequests().Create(ctx, &models.DataRequest{
+		UserID:        userID,
+		Type:          requestType,
+		RequestedByID: actor.UserID,
+		IP:            actor.IP,
+	})
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"ecommerce-api/catalog"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrImportUnreadable is returned when an import file cannot be read to the
+// end, as opposed to rows that are invalid
+var ErrImportUnreadable = errors.New("import file cannot be read")
+
+// ImportMode decides what happens to the valid rows of an import when other
+// rows fail
+type ImportMode string
+
+// Import modes
+const (
+	ImportAtomic     ImportMode = "atomic"      // apply every row or none
+	ImportBestEffort ImportMode = "best-effort" // apply the valid rows and report the others
+)
+
+// ParseImportMode returns the mode named by s, atomic when s is empty
+func ParseImportMode(s string) (ImportMode, error) {
+	switch mode := ImportMode(s); mode {
+	case "":
+		return ImportAtomic, nil
+	case ImportAtomic, ImportBestEffort:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown import mode %q, use %s or %s", s, ImportAtomic, ImportBestEffort)
+}
+
+// ImportOptions controls an import
+type ImportOptions struct {
+	Mode ImportMode
+
+	// DryRun checks every row and reports what would change, changing nothing
+	DryRun bool
+
+	// Progress, when set, is called every ImportProgressInterval rows and
+	// after the last one
+	Progress func(ImportCounts)
+}
+
+// ImportProgressInterval is the number of rows between progress reports
+const ImportProgressInterval = 500
+
+// MaxImportErrors is the number of failed rows an import result lists; the
+// failed count includes all of them
+const MaxImportErrors = 1000
+
+// ImportCounts counts the rows of an import so far
+type ImportCounts struct {
+	Rows      int `json:"rows"`
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+}
+
+// ImportRowError is a row that was not imported
+type ImportRowError struct {
+	Line    int    `json:"line"`
+	SKU     string `json:"sku,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportResult reports an import. In an atomic import or a dry run that
+// found errors, the counts tell what would have changed.
+type ImportResult struct {
+	ImportCounts
+	Mode    ImportMode       `json:"mode"`
+	DryRun  bool             `json:"dry_run"`
+	Applied bool             `json:"applied"` // whether the changes were kept
+	Errors  []ImportRowError `json:"errors"`
+}
+
+// errRollback discards the changes of an atomic import or a dry run
+var errRollback = errors.New("rollback")
+
+// Import creates and updates products from rows. A row matches the product
+// with its SKU, or else the product with its name, which is updated; rows
+// that match no product create one. Invalid rows are reported in the result
+// and do not stop the import; other errors do.
+func (s *ProductService) Import(ctx context.Context, actor Actor, rows catalog.Reader, opts ImportOptions) (*ImportResult, error) {
+	if opts.Mode == "" {
+		opts.Mode = ImportAtomic
+	}
+	result := &ImportResult{Mode: opts.Mode, DryRun: opts.DryRun, Errors: []ImportRowError{}}
+
+	// The line each SKU and name was first seen on; a file must not
+	// describe the same product twice
+	seen := map[string]int{}
+
+	run := func(store repositories.Store) error {
+		products := NewProductService(store)
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			row, err := rows.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			var rowErr *catalog.RowError
+			switch {
+			case errors.As(err, &rowErr):
+				result.fail(row, rowErr.Err)
+			case err != nil:
+				return fmt.Errorf("%w: %w", ErrImportUnreadable, err)
+			case seen["sku:"+row.SKU] > 0 && row.SKU != "":
+				result.fail(row, fmt.Errorf("SKU %s is also on line %d", row.SKU, seen["sku:"+row.SKU]))
+			case seen["name:"+row.Name] > 0:
+				result.fail(row, fmt.Errorf("name %s is also on line %d", row.Name, seen["name:"+row.Name]))
+			default:
+				if row.SKU != "" {
+					seen["sku:"+row.SKU] = row.Line
+				}
+				seen["name:"+row.Name] = row.Line
+				err = products.importRow(ctx, actor, row, &result.ImportCounts)
+				if errors.Is(err, ErrProductNameTaken) || errors.Is(err, ErrProductSKUTaken) ||
+					errors.Is(err, ErrPriceNegative) || errors.Is(err, ErrStockNegative) ||
+					errors.Is(err, ErrInsufficientStock) {
+					result.fail(row, err)
+				} else if err != nil {
+					return fmt.Errorf("line %d: %w", row.Line, err)
+				}
+			}
+
+			result.Rows++
+			if opts.Progress != nil && result.Rows%ImportProgressInterval == 0 {
+				opts.Progress(result.ImportCounts)
+			}
+		}
+		if opts.Progress != nil && result.Rows%ImportProgressInterval != 0 {
+			opts.Progress(result.ImportCounts)
+		}
+
+		if opts.DryRun || (opts.Mode == ImportAtomic && result.Failed > 0) {
+			return errRollback
+		}
+		return nil
+	}
+
+	// A best-effort import keeps each row as it goes. The others run in one
+	// transaction, so a dry run also sees the effect of earlier rows, such as
+	// a name used twice in the file.
+	var err error
+	if opts.Mode == ImportBestEffort && !opts.DryRun {
+		err = run(s.store)
+		result.Applied = true
+	} else {
+		err = s.store.Atomic(ctx, run)
+		result.Applied = err == nil
+	}
+	if err != nil && !errors.Is(err, errRollback) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importRow creates or updates the product of row and counts the outcome
+func (s *ProductService) importRow(ctx context.Context, actor Actor, row catalog.Row, counts *ImportCounts) error {
+	input := ProductInput{Name: row.Name, Price: row.Price, Stock: row.Stock}
+	if row.SKU != "" {
+		input.SKU = &row.SKU
+	}
+	if row.Description != nil {
+		input.Description = *row.Description
+	}
+
+	existing, err := s.match(ctx, row)
+	if err != nil {
+		return err
+	}
+	if existing != nil && row.Description == nil {
+		// Files without descriptions leave them as they are
+		input.Description = existing.Description
+	}
+	if existing == nil {
+		if _, err := s.Create(ctx, actor, input); err != nil {
+			return err
+		}
+		counts.Created++
+		return nil
+	}
+
+	if existing.Name == input.Name && existing.Description == input.Description &&
+		existing.Price == input.Price && existing.Stock == input.Stock &&
+		(input.SKU == nil || (existing.SKU != nil && *existing.SKU == *input.SKU)) {
+		counts.Unchanged++
+		return nil
+	}
+	if _, err := s.Update(ctx, actor, existing.ID, input); err != nil {
+		return err
+	}
+	counts.Updated++
+	return nil
+}
+
+// match returns the product a row updates, or nil when it creates one
+func (s *ProductService) match(ctx context.Context, row catalog.Row) (*models.Product, error) {
+	if row.SKU != "" {
+		product, err := s.store.Products().GetBySKU(ctx, row.SKU)
+		if !errors.Is(err, repositories.ErrNotFound) {
+			return product, err
+		}
+	}
+
+	product, err := s.store.Products().GetByName(ctx, row.Name)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A product with another SKU is a different product that has this name
+	if row.SKU != "" && product.SKU != nil {
+		return nil, nil
+	}
+	return product, nil
+}
+
+// fail records a row that was not imported
+func (r *ImportResult) fail(row catalog.Row, err error) {
+	r.Failed++
+	if len(r.Errors) < MaxImportErrors {
+		r.Errors = append(r.Errors, ImportRowError{Line: row.Line, SKU: row.SKU, Name: row.Name, Message: err.Error()})
+	}
+}
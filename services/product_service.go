@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"errors"
+)
+
+// ProductService manages the catalog
+type ProductService struct {
+	store repositories.Store
+}
+
+// NewProductService returns a product service on store
+func NewProductService(store repositories.Store) *ProductService {
+	return &ProductService{store: store}
+}
+
+// ProductInput holds the editable fields of a product
+type ProductInput struct {
+	Name        string
+	Description string
+	Price       float64
+	Stock       int
+
+	// SKU is left unchanged by updates when nil and removed when empty
+	SKU *string
+}
+
+// sku returns the SKU to store for input, nil for none
+func (input ProductInput) sku() *string {
+	if input.SKU == nil || *input.SKU == "" {
+		return nil
+	}
+	sku := *input.SKU
+	return &sku
+}
+
+// validate checks the values every product must have
+func (input ProductInput) validate() error {
+	switch {
+	case input.Price < 0:
+		return ErrPriceNegative
+	case input.Stock < 0:
+		return ErrStockNegative
+	}
+	return nil
+}
+
+// List returns every product
+func (s *ProductService) List(ctx context.Context) ([]models.Product, error) {
+	return s.store.Products().List(ctx)
+}
+
+// Each calls fn for every product, reading the catalog in batches
+func (s *ProductService) Each(ctx context.Context, fn func(*models.Product) error) error {
+	return s.store.Products().Each(ctx, fn)
+}
+
+// Create adds a product. Product names are unique.
+func (s *ProductService) Create(ctx context.Context, actor Actor, input ProductInput) (*models.Product, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkNameFree(ctx, input.Name, 0); err != nil {
+		return nil, err
+	}
+	if err := s.checkSKUFree(ctx, input.sku(), 0); err != nil {
+		return nil, err
+	}
+
+	product := &models.Product{Name: input.Name, Description: input.Description, Price: input.Price, Stock: input.Stock, SKU: input.sku()}
+	err := s.store.Atomic(ctx, func(store repositories.Store) error {
+		if err := store.Products().Create(ctx, product); err != nil {
+			return err
+		}
+		return audit(ctx, store, actor, "product.create", "product", product.ID, nil, product)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// Update replaces the editable fields of a product
+func (s *ProductService) Update(ctx context.Context, actor Actor, id uint, input ProductInput) (*models.Product, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	product, err := s.store.Products().Get(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrProductNotFound)
+	}
+	if input.Name != product.Name {
+		if err := s.checkNameFree(ctx, input.Name, id); err != nil {
+			return nil, err
+		}
+	}
+	if input.SKU != nil {
+		if err := s.checkSKUFree(ctx, input.sku(), id); err != nil {
+			return nil, err
+		}
+	}
+
+	before := *product
+	product.Name = input.Name
+	product.Description = input.Description
+	product.Price = input.Price
+	columns := []string{"name", "description", "price"}
+	if input.SKU != nil {
+		product.SKU = input.sku()
+		columns = append(columns, "sku")
+	}
+	err = s.store.Atomic(ctx, func(store repositories.Store) error {
+		if err := store.Products().Update(ctx, product, columns...); err != nil {
+			return err
+		}
+		// Apply the stock change as a delta, so stock reserved by orders
+		// placed since the product was read is not given back
+		if delta := input.Stock - before.Stock; delta != 0 {
+			adjusted, err := store.Products().AdjustStock(ctx, id, delta)
+			if err != nil {
+				return err
+			}
+			if !adjusted {
+				return ErrInsufficientStock
+			}
+		}
+		if product, err = store.Products().Get(ctx, id); err != nil {
+			return err
+		}
+		return audit(ctx, store, actor, "product.update", "product", product.ID, before, product)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// Delete removes a product from the catalog
+func (s *ProductService) Delete(ctx context.Context, actor Actor, id uint) error {
+	product, err := s.store.Products().Get(ctx, id)
+	if err != nil {
+		return notFound(err, ErrProductNotFound)
+	}
+	before := *product
+	return s.store.Atomic(ctx, func(store repositories.Store) error {
+		// Deleted products keep their row, so free the SKU for a new product
+		if product.SKU != nil {
+			product.SKU = nil
+			if err := store.Products().Update(ctx, product, "sku"); err != nil {
+				return err
+			}
+		}
+		if err := store.Products().Delete(ctx, product); err != nil {
+			return err
+		}
+		return audit(ctx, store, actor, "product.delete", "product", product.ID, before, nil)
+	})
+}
+
+// checkSKUFree fails when a product other than id already has sku
+func (s *ProductService) checkSKUFree(ctx context.Context, sku *string, id uint) error {
+	if sku == nil {
+		return nil
+	}
+	existing, err := s.store.Products().GetBySKU(ctx, *sku)
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case existing.ID != id:
+		return ErrProductSKUTaken
+	}
+	return nil
+}
+
+// checkNameFree fails when a product other than id already has name
+func (s *ProductService) checkNameFree(ctx context.Context, name string, id uint) error {
+	existing, err := s.store.Products().GetByName(ctx, name)
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case existing.ID != id:
+		return ErrProductNameTaken
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package services_test
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/repositories/memory"
+	"ecommerce-api/services"
+	"errors"
+	"testing"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestProductServiceCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   services.ProductInput
+		wantErr error
+	}{
+		{name: "creates a product", input: services.ProductInput{Name: "Mug", Price: 4.5, Stock: 3, SKU: strPtr("MUG-2")}},
+		{name: "allows a free product", input: services.ProductInput{Name: "Sticker"}},
+		{name: "rejects a negative price", input: services.ProductInput{Name: "Mug", Price: -1}, wantErr: services.ErrPriceNegative},
+		{name: "rejects a negative stock", input: services.ProductInput{Name: "Mug", Stock: -1}, wantErr: services.ErrStockNegative},
+		{name: "rejects a name in use", input: services.ProductInput{Name: "Cup"}, wantErr: services.ErrProductNameTaken},
+		{name: "rejects a SKU in use", input: services.ProductInput{Name: "Mug", SKU: strPtr("CUP-1")}, wantErr: services.ErrProductSKUTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memory.NewStore()
+			products := services.NewProductService(store)
+			if _, err := products.Create(ctx, services.Actor{UserID: 1}, services.ProductInput{Name: "Cup", Price: 2, SKU: strPtr("CUP-1")}); err != nil {
+				t.Fatal(err)
+			}
+
+			product, err := products.Create(ctx, services.Actor{UserID: 1}, tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+
+			// Only created products are audited
+			want := 1
+			if err == nil {
+				want = 2
+				if product.Name != tt.input.Name || product.Price != tt.input.Price || product.Stock != tt.input.Stock {
+					t.Errorf("product = %+v, want the input %+v", product, tt.input)
+				}
+			}
+			if got := len(store.AuditLogEntries()); got != want {
+				t.Errorf("audit log has %d entries, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestProductServiceUpdate(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	products := services.NewProductService(store)
+	actor := services.Actor{UserID: 1}
+	cup, err := products.Create(ctx, actor, services.ProductInput{Name: "Cup", Price: 2, SKU: strPtr("CUP-1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := products.Create(ctx, actor, services.ProductInput{Name: "Mug", Price: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := products.Update(ctx, actor, cup.ID, services.ProductInput{Name: "Mug", Price: 2}); !errors.Is(err, services.ErrProductNameTaken) {
+		t.Errorf("Update() to a taken name error = %v, want %v", err, services.ErrProductNameTaken)
+	}
+	if _, err := products.Update(ctx, actor, cup.ID, services.ProductInput{Name: "Cup", Price: -2}); !errors.Is(err, services.ErrPriceNegative) {
+		t.Errorf("Update() to a negative price error = %v, want %v", err, services.ErrPriceNegative)
+	}
+	if _, err := products.Update(ctx, actor, 999, services.ProductInput{Name: "Cup"}); !errors.Is(err, services.ErrProductNotFound) {
+		t.Errorf("Update() of an unknown product error = %v, want %v", err, services.ErrProductNotFound)
+	}
+
+	// A nil SKU keeps the current one
+	updated, err := products.Update(ctx, actor, cup.ID, services.ProductInput{Name: "Cup", Price: 2.5, Stock: 7})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated.Price != 2.5 || updated.Stock != 7 || updated.SKU == nil || *updated.SKU != "CUP-1" {
+		t.Errorf("updated product = %+v", updated)
+	}
+}
+
+// reservingStore takes reserve items from stock right after a product is
+// read, like an order placed while an update is in flight
+type reservingStore struct {
+	*memory.Store
+	reserve int
+}
+
+func (s *reservingStore) Products() repositories.ProductRepository {
+	return reservingProducts{ProductRepository: s.Store.Products(), s: s}
+}
+
+type reservingProducts struct {
+	repositories.ProductRepository
+	s *reservingStore
+}
+
+func (r reservingProducts) Get(ctx context.Context, id uint) (*models.Product, error) {
+	product, err := r.ProductRepository.Get(ctx, id)
+	if err == nil && r.s.reserve > 0 {
+		if _, err := r.ProductRepository.AdjustStock(ctx, id, -r.s.reserve); err != nil {
+			return nil, err
+		}
+		r.s.reserve = 0
+	}
+	return product, err
+}
+
+func TestProductServiceUpdateKeepsReservedStock(t *testing.T) {
+	tests := []struct {
+		name      string
+		stock     int
+		wantStock int
+		wantPrice float64
+		wantErr   error
+	}{
+		{name: "keeps the stock when it is unchanged", stock: 10, wantStock: 7, wantPrice: 2.5},
+		{name: "adds to the reserved stock", stock: 12, wantStock: 9, wantPrice: 2.5},
+		{name: "refuses to take more than is left", stock: 2, wantStock: 7, wantPrice: 2, wantErr: services.ErrInsufficientStock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := &reservingStore{Store: memory.NewStore()}
+			products := services.NewProductService(store)
+			actor := services.Actor{UserID: 1}
+			cup, err := products.Create(ctx, actor, services.ProductInput{Name: "Cup", Price: 2, Stock: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Three items are ordered between reading and writing the product
+			store.reserve = 3
+			_, err = products.Update(ctx, actor, cup.ID, services.ProductInput{Name: "Cup", Price: 2.5, Stock: tt.stock})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Update() error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, err := store.Store.Products().Get(ctx, cup.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Stock != tt.wantStock {
+				t.Errorf("stock = %d, want %d", stored.Stock, tt.wantStock)
+			}
+			if stored.Price != tt.wantPrice {
+				t.Errorf("price = %v, want %v", stored.Price, tt.wantPrice)
+			}
+		})
+	}
+}
+
+func TestProductServiceDeleteFreesSKU(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	products := services.NewProductService(store)
+	actor := services.Actor{UserID: 1}
+	cup, err := products.Create(ctx, actor, services.ProductInput{Name: "Cup", SKU: strPtr("CUP-1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := products.Delete(ctx, actor, cup.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := products.Delete(ctx, actor, cup.ID); !errors.Is(err, services.ErrProductNotFound) {
+		t.Errorf("second Delete() error = %v, want %v", err, services.ErrProductNotFound)
+	}
+	if _, err := products.Create(ctx, actor, services.ProductInput{Name: "New cup", SKU: strPtr("CUP-1")}); err != nil {
+		t.Errorf("Create() with the SKU of a deleted product error = %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+// Package services holds the business rules of the catalog, orders and
+// accounts. Services load and store data only through repositories, so they
+// run the same against the database and against the in-memory fakes.
+package services
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned by the services. Handlers map them to responses.
+var (
+	ErrProductNotFound      = errors.New("product not found")
+	ErrProductNameTaken     = errors.New("product name already taken")
+	ErrProductSKUTaken      = errors.New("product SKU already taken")
+	ErrPriceNegative        = errors.New("price must not be negative")
+	ErrStockNegative        = errors.New("stock must not be negative")
+	ErrInsufficientStock    = errors.New("insufficient stock")
+	ErrQuantityInvalid      = errors.New("invalid quantity")
+	ErrOrderNotFound        = errors.New("order not found")
+	ErrOrderNotOwned        = errors.New("order belongs to another user")
+	ErrOrderAlreadyCanceled = errors.New("order is already canceled")
+	ErrOrderStatusInvalid   = errors.New("invalid order status")
+	ErrOrderStatusChanged   = errors.New("order status was changed concurrently")
+	ErrOrderTotalMismatch   = errors.New("order total does not match current prices")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrEmailTaken           = errors.New("email already registered")
+	ErrEmailNotVerified     = errors.New("email address not verified")
+	ErrSameEmail            = errors.New("new email equals the current one")
+	ErrMailNotSent          = errors.New("email could not be sent")
+
+	ErrInvalidCredentials       = errors.New("invalid credentials")
+	ErrPasswordIncorrect        = errors.New("password is incorrect")
+	ErrAccountLocked            = errors.New("account temporarily locked")
+	ErrTooManyAttempts          = errors.New("too many failed attempts")
+	ErrReauthRequired           = errors.New("recent login required")
+	ErrRefreshTokenInvalid      = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused       = errors.New("refresh token reuse detected")
+	ErrSessionNotFound          = errors.New("session not found")
+	ErrVerificationTokenInvalid = errors.New("invalid or expired verification token")
+	ErrResetTokenInvalid        = errors.New("invalid or expired reset token")
+
+	ErrMFAChallengeInvalid = errors.New("invalid or expired MFA token")
+	ErrMFACodeInvalid      = errors.New("invalid two-factor code")
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnabled       = errors.New("two-factor authentication not enabled")
+	ErrMFANotEnrolled      = errors.New("two-factor enrolment not started")
+
+	ErrLoginProviderNotFound    = errors.New("unknown login provider")
+	ErrLoginProviderUnavailable = errors.New("login provider unavailable")
+	ErrLoginStateInvalid        = errors.New("invalid login state")
+	ErrLoginStateExpired        = errors.New("login state expired")
+	ErrLoginRejected            = errors.New("login rejected")
+	ErrIdentityEmailUnverified  = errors.New("provider did not return a verified email")
+
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrAPIKeyInvalid  = errors.New("invalid API key")
+	ErrScopeUnknown   = errors.New("unknown scope")
+	ErrExpiryInPast   = errors.New("expiry must be in the future")
+)
+
+// ItemError reports which order item failed, wrapping ErrProductNotFound,
+// ErrInsufficientStock or ErrQuantityInvalid
+type ItemError struct {
+	ProductID uint
+	Err       error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("product %d: %v", e.ProductID, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError reports a status change the order rules do not allow
+type StatusError struct {
+	From, To string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("cannot change order status from %s to %s", e.From, e.To)
+}
+
+func (e *StatusError) Unwrap() error {
+	return ErrOrderStatusInvalid
+}
+
+// RetryError reports a refused login that may be retried after Wait,
+// wrapping ErrTooManyAttempts or ErrAccountLocked
+type RetryError struct {
+	Err  error
+	Wait time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v, retry in %s", e.Err, e.Wait)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// ScopeError reports an API key scope that does not exist, wrapping ErrScopeUnknown
+type ScopeError struct {
+	Scope string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("unknown scope %q", e.Scope)
+}
+
+func (e *ScopeError) Unwrap() error {
+	return ErrScopeUnknown
+}
+
+// Actor identifies who makes an administrative change, for the audit log
+type Actor struct {
+	UserID    uint
+	APIKeyID  *uint // set when the actor used an API key
+	IP        string
+	RequestID string
+}
+
+// audit records an administrative change in the store that applies it, so
+// the entry is only kept if the change is
+func audit(ctx context.Context, store repositories.Store, actor Actor, action, targetType string, targetID uint, before, after interface{}) error {
+	changes, err := models.DiffAuditChanges(before, after)
+	if err != nil {
+		return err
+	}
+	return store.AuditLogs().Create(ctx, &models.AuditLog{
+		ActorID:    actor.UserID,
+		APIKeyID:   actor.APIKeyID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    changes,
+		IP:         actor.IP,
+		RequestID:  actor.RequestID,
+	})
+}
+
+// notFound replaces repositories.ErrNotFound with the service's own error
+func notFound(err, replacement error) error {
+	if errors.Is(err, repositories.ErrNotFound) {
+		return replacement
+	}
+	return err
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/utils"
+	"errors"
+	"time"
+)
+
+// Client describes the device a login comes from
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// Tokens are the credentials handed out for a login session
+type Tokens struct {
+	Token        string `json:"token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// SessionService starts, refreshes and revokes login sessions. Revocations
+// are recorded in cache once committed, so the auth middleware rejects the
+// session's access tokens right away.
+type SessionService struct {
+	store  repositories.Store
+	tokens *utils.TokenService
+	cache  *utils.SessionRevocationCache
+}
+
+// NewSessionService returns a session service on store that signs access
+// tokens with tokens. cache may be nil.
+func NewSessionService(store repositories.Store, tokens *utils.TokenService, cache *utils.SessionRevocationCache) *SessionService {
+	return &SessionService{store: store, tokens: tokens, cache: cache}
+}
+
+// Start opens a new session for the user and returns an access token together
+// with the first refresh token of the session. mfa records whether the login
+// was completed with a second factor.
+func (s *SessionService) Start(ctx context.Context, user *models.User, mfa bool, client Client) (*Tokens, error) {
+	var tokens *Tokens
+	err := s.store.Atomic(ctx, func(store repositories.Store) error {
+		session := &models.Session{
+			UserID:    user.ID,
+			UserAgent: truncate(client.UserAgent, 255),
+			IP:        client.IP,
+			ExpiresAt: time.Now().Add(utils.RefreshTokenTTL),
+			MFA:       mfa,
+		}
+		if err := store.Sessions().Create(ctx, session); err != nil {
+			return err
+		}
+
+		var err error
+		tokens, err = s.rotateTokens(ctx, store, user, session)
+		return err
+	})
+	return tokens, err
+}
+
+// Refresh exchanges a refresh token for new tokens of the same session.
+// Refresh tokens are single-use: presenting a used one revokes the session.
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	record, err := s.store.RefreshTokens().GetByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, notFound(err, ErrRefreshTokenInvalid)
+	}
+
+	var tokens *Tokens
+	err = s.store.Atomic(ctx, func(store repositories.Store) error {
+		session, err := store.Sessions().Get(ctx, record.SessionID)
+		if err != nil {
+			return notFound(err, ErrRefreshTokenInvalid)
+		}
+		if !session.Active() || time.Now().After(record.ExpiresAt) {
+			return ErrRefreshTokenInvalid
+		}
+
+		// A concurrent or repeated use loses this race
+		used, err := store.RefreshTokens().MarkUsed(ctx, record.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrRefreshTokenReused
+		}
+
+		// The session list shows updated_at as the time the session was last used
+		if err := store.Sessions().Touch(ctx, session.ID); err != nil {
+			return err
+		}
+
+		user, err := store.Users().Get(ctx, record.UserID)
+		if err != nil {
+			return notFound(err, ErrRefreshTokenInvalid)
+		}
+		tokens, err = s.rotateTokens(ctx, store, user, session)
+		return err
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		// The token family is compromised: revoke the whole session
+		if err := s.store.Sessions().Revoke(ctx, record.SessionID); err != nil {
+			return nil, err
+		}
+		markRevoked(s.cache, record.SessionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Revoked reports whether a session has been revoked or has expired,
+// consulting the cache before the store. Unknown sessions count as revoked.
+func (s *SessionService) Revoked(ctx context.Context, id uint) (bool, error) {
+	if id == 0 {
+		return true, nil
+	}
+	if s.cache != nil {
+		if revoked, ok := s.cache.Get(id); ok {
+			return revoked, nil
+		}
+	}
+
+	revoked := true
+	session, err := s.store.Sessions().Get(ctx, id)
+	switch {
+	case err == nil:
+		revoked = !session.Active()
+	case !errors.Is(err, repositories.ErrNotFound):
+		return false, err
+	}
+	if s.cache != nil {
+		s.cache.Set(id, revoked)
+	}
+	return revoked, nil
+}
+
+// List returns the active sessions of a user, newest first
+func (s *SessionService) List(ctx context.Context, userID uint) ([]models.Session, error) {
+	sessions, err := s.store.Sessions().ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	active := make([]models.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.Active() {
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+// Revoke signs out one session of a user
+func (s *SessionService) Revoke(ctx context.Context, userID, sessionID uint) error {
+	session, err := s.store.Sessions().Get(ctx, sessionID)
+	if err != nil {
+		return notFound(err, ErrSessionNotFound)
+	}
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	if err := s.store.Sessions().Revoke(ctx, session.ID); err != nil {
+		return err
+	}
+	markRevoked(s.cache, session.ID)
+	return nil
+}
+
+// RevokeAll signs out every session of a user
+func (s *SessionService) RevokeAll(ctx context.Context, userID uint) error {
+	var revoked []uint
+	err := s.store.Atomic(ctx, func(store repositories.Store) error {
+		var err error
+		revoked, err = revokeUserSessions(ctx, store, userID)
+		return err
+	})
+	if err == nil {
+		markRevoked(s.cache, revoked...)
+	}
+	return err
+}
+
+// rotateTokens stores a new refresh token for the session and signs a matching access token
+func (s *SessionService) rotateTokens(ctx context.Context, store repositories.Store, user *models.User, session *models.Session) (*Tokens, error) {
+	refreshToken, refreshHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	err = store.RefreshTokens().Create(ctx, &models.RefreshToken{
+		SessionID: session.ID,
+		UserID:    user.ID,
+		TokenHash: refreshHash,
+		ExpiresAt: session.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.tokens.GenerateJWT(user.ID, user.IsAdmin, session.ID, session.MFA)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{
+		Token:        accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// revokeUserSessions revokes every active session of a user and returns
+// their IDs, to pass to markRevoked once the change is committed
+func revokeUserSessions(ctx context.Context, store repositories.Store, userID uint) ([]uint, error) {
+	ids, err := store.Sessions().ActiveIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return ids, store.Sessions().Revoke(ctx, ids...)
+}
+
+// markRevoked records committed revocations in the session cache. It must not
+// run inside Atomic: a rollback would leave the cache rejecting sessions that
+// are still active.
+func markRevoked(cache *utils.SessionRevocationCache, sessionIDs ...uint) {
+	if cache == nil {
+		return
+	}
+	for _, id := range sessionIDs {
+		cache.Set(id, true)
+	}
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/repositories"
+	"ecommerce-api/utils"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Account email settings
+var (
+	// VerificationTokenTTL is how long a verification link stays valid
+	VerificationTokenTTL = 24 * time.Hour
+
+	// VerificationResendInterval is the minimum time between two verification emails
+	VerificationResendInterval = time.Minute
+
+	// PasswordResetTokenTTL is how long a password reset link stays valid
+	PasswordResetTokenTTL = time.Hour
+)
+
+// UserService manages user accounts, their email address and password
+type UserService struct {
+	store  repositories.Store
+	tokens *utils.TokenService
+	cache  *utils.SessionRevocationCache
+
+	// Mailer sends the verification and password reset emails
+	Mailer utils.Mailer
+
+	// BaseURL is used to build the links sent by email
+	BaseURL string
+}
+
+// NewUserService returns a user service on store. tokens signs the email
+// verification links and may be nil for callers that send none. Sessions it
+// revokes are recorded in cache, which may be nil. Emails are logged until
+// Mailer is set.
+func NewUserService(store repositories.Store, tokens *utils.TokenService, cache *utils.SessionRevocationCache) *UserService {
+	return &UserService{store: store, tokens: tokens, cache: cache, Mailer: utils.LogMailer{}, BaseURL: "http://localhost:8080"}
+}
+
+// Get returns a user
+func (s *UserService) Get(ctx context.Context, id uint) (*models.User, error) {
+	user, err := s.store.Users().Get(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	return user, nil
+}
+
+// Register creates an account. The email address stays unverified until the
+// user confirms it.
+func (s *UserService) Register(ctx context.Context, email, password string, isAdmin bool) (*models.User, error) {
+	if _, err := s.store.Users().GetByEmail(ctx, email); err == nil {
+		return nil, ErrEmailTaken
+	} else if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{Email: email, Password: hashedPassword, IsAdmin: isAdmin}
+	if err := s.store.Users().Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Unlock clears the failed login count and lockout of an account
+func (s *UserService) Unlock(ctx context.Context, actor Actor, id uint) error {
+	user, err := s.store.Users().Get(ctx, id)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+
+	before := *user
+	user.FailedLoginCount = 0
+	user.LastFailedLoginAt = nil
+	user.LockedUntil = nil
+	return s.store.Atomic(ctx, func(store repositories.Store) error {
+		if err := store.Users().Update(ctx, user, "failed_login_count", "last_failed_login_at", "locked_until"); err != nil {
+			return err
+		}
+		return audit(ctx, store, actor, "user.unlock", "user", user.ID, before, user)
+	})
+}
+
+// SetPassword stores a new password for a user and revokes every session of
+// the account, so whoever knew the old password is signed out
+func (s *UserService) SetPassword(ctx context.Context, userID uint, password string) error {
+	var revoked []uint
+	err := s.store.Atomic(ctx, func(store repositories.Store) error {
+		var err error
+		revoked, err = setPassword(ctx, store, userID, password)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	markRevoked(s.cache, revoked...)
+	return nil
+}
+
+// ChangePassword replaces the password of a user after checking the current
+// one. Every session is revoked; the caller starts a new one for the user.
+func (s *UserService) ChangePassword(ctx context.Context, userID uint, current, password string) (*models.User, error) {
+	user, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.VerifyPassword(user.Password, current); err != nil {
+		return nil, ErrPasswordIncorrect
+	}
+	if err := s.SetPassword(ctx, user.ID, password); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// RequestPasswordReset emails a single-use password reset link. Unknown
+// addresses are ignored, so callers cannot tell which emails are registered.
+func (s *UserService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.store.Users().GetByEmail(ctx, email)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	err = s.store.Atomic(ctx, func(store repositories.Store) error {
+		// Only the most recent reset link is valid
+		if err := store.PasswordResets().MarkAllUsed(ctx, user.ID); err != nil {
+			return err
+		}
+		return store.PasswordResets().Create(ctx, &models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: tokenHash,
+			ExpiresAt: time.Now().Add(PasswordResetTokenTTL),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.BaseURL, url.QueryEscape(token))
+	return s.send(ctx, utils.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("A password reset was requested for your account.\n\n%s\n\n"+
+			"Your reset token is: %s\n\nThe link expires in %s. If you did not request it, ignore this email.",
+			link, token, PasswordResetTokenTTL),
+	})
+}
+
+// ResetPassword sets a new password with a token from RequestPasswordReset
+// and revokes every session of the account
+func (s *UserService) ResetPassword(ctx context.Context, token, password string) error {
+	var revoked []uint
+	err := s.store.Atomic(ctx, func(store repositories.Store) error {
+		record, err := store.PasswordResets().GetByHash(ctx, utils.HashToken(token))
+		if err != nil {
+			return notFound(err, ErrResetTokenInvalid)
+		}
+		if record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+			return ErrResetTokenInvalid
+		}
+
+		// Consume the token; a concurrent reset with the same token loses this race
+		used, err := store.PasswordResets().MarkUsed(ctx, record.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrResetTokenInvalid
+		}
+
+		revoked, err = setPassword(ctx, store, record.UserID, password)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	markRevoked(s.cache, revoked...)
+	return nil
+}
+
+// ProfileInput holds the profile fields to change; nil fields are left unchanged
+type ProfileInput struct {
+	Name             *string
+	Phone            *string
+	Locale           *string
+	MarketingConsent *bool
+}
+
+// UpdateProfile changes the profile of a user
+func (s *UserService) UpdateProfile(ctx context.Context, userID uint, input ProfileInput) (*models.User, error) {
+	user, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []string
+	if input.Name != nil {
+		user.Name = strings.TrimSpace(*input.Name)
+		columns = append(columns, "name")
+	}
+	if input.Phone != nil {
+		user.Phone = *input.Phone
+		columns = append(columns, "phone")
+	}
+	if input.Locale != nil {
+		user.Locale = *input.Locale
+		columns = append(columns, "locale")
+	}
+	if input.MarketingConsent != nil && *input.MarketingConsent != user.MarketingConsent {
+		// Record when consent was given, for compliance
+		user.MarketingConsent = *input.MarketingConsent
+		user.MarketingConsentAt = nil
+		if user.MarketingConsent {
+			now := time.Now()
+			user.MarketingConsentAt = &now
+		}
+		columns = append(columns, "marketing_consent", "marketing_consent_at")
+	}
+
+	if len(columns) > 0 {
+		if err := s.store.Users().Update(ctx, user, columns...); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// ChangeEmail starts a change of email address after checking the password.
+// The new address is confirmed through the emailed link.
+func (s *UserService) ChangeEmail(ctx context.Context, userID uint, newEmail, password string) error {
+	user, err := s.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := utils.VerifyPassword(user.Password, password); err != nil {
+		return ErrPasswordIncorrect
+	}
+	newEmail = utils.NormalizeEmail(newEmail)
+	if newEmail == user.Email {
+		return ErrSameEmail
+	}
+	if err := s.checkEmailFree(ctx, newEmail); err != nil {
+		return err
+	}
+
+	user.PendingEmail = newEmail
+	if err := s.store.Users().Update(ctx, user, "pending_email"); err != nil {
+		return err
+	}
+	return s.SendVerification(ctx, user, newEmail)
+}
+
+// SendVerification mails a signed verification link for address, which is
+// either the user's current email or a pending new one
+func (s *UserService) SendVerification(ctx context.Context, user *models.User, address string) error {
+	token, err := s.tokens.GeneratePurposeToken(utils.PurposeEmailVerification, user.ID, address, VerificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/v1/auth/verify-email?token=%s", s.BaseURL, url.QueryEscape(token))
+	err = s.send(ctx, utils.Message{
+		To:      address,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Please confirm your email address by opening the link below.\n\n%s\n\n"+
+			"The link expires in %s.", link, VerificationTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user.VerificationSentAt = &now
+	return s.store.Users().Update(ctx, user, "verification_sent_at")
+}
+
+// ResendVerification sends a new verification link to an unverified account.
+// Unknown and verified addresses are ignored and resends are throttled per
+// account, silently so callers cannot tell which addresses belong to
+// unverified accounts.
+func (s *UserService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.store.Users().GetByEmail(ctx, email)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < VerificationResendInterval {
+		return nil
+	}
+	return s.SendVerification(ctx, user, user.Email)
+}
+
+// VerifyEmail confirms the address a verification token was issued for:
+// either the current email of the user or a pending change of address
+func (s *UserService) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := s.tokens.VerifyPurposeToken(token, utils.PurposeEmailVerification)
+	if err != nil {
+		return ErrVerificationTokenInvalid
+	}
+	user, err := s.store.Users().Get(ctx, claims.UserID)
+	if err != nil {
+		return notFound(err, ErrVerificationTokenInvalid)
+	}
+
+	now := time.Now()
+	switch {
+	case user.Email == claims.Email:
+		if user.EmailVerified() {
+			return nil
+		}
+		user.EmailVerifiedAt = &now
+		return s.store.Users().Update(ctx, user, "email_verified_at")
+	case user.PendingEmail != "" && user.PendingEmail == claims.Email:
+		if err := s.checkEmailFree(ctx, claims.Email); err != nil {
+			return err
+		}
+		user.Email, user.PendingEmail, user.EmailVerifiedAt = claims.Email, "", &now
+		return s.store.Users().Update(ctx, user, "email", "pending_email", "email_verified_at")
+	}
+	return ErrVerificationTokenInvalid
+}
+
+// checkEmailFree fails when an account already uses email
+func (s *UserService) checkEmailFree(ctx context.Context, email string) error {
+	_, err := s.store.Users().GetByEmail(ctx, email)
+	switch {
+	case err == nil:
+		return ErrEmailTaken
+	case errors.Is(err, repositories.ErrNotFound):
+		return nil
+	}
+	return err
+}
+
+// send delivers an email, wrapping failures in ErrMailNotSent
+func (s *UserService) send(ctx context.Context, msg utils.Message) error {
+	if err := s.Mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("%w: %v", ErrMailNotSent, err)
+	}
+	return nil
+}
+
+// setPassword stores a new password hash and revokes every session of the
+// user, returning the revoked session IDs for markRevoked
+func setPassword(ctx context.Context, store repositories.Store, userID uint, password string) ([]uint, error) {
+	user, err := store.Users().Get(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = hashedPassword
+	if err := store.Users().Update(ctx, user, "password"); err != nil {
+		return nil, err
+	}
+	return revokeUserSessions(ctx, store, user.ID)
+}
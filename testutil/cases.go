@@ -0,0 +1,47 @@
+package testutil
+
+import (
+	"ecommerce-api/config"
+	"ecommerce-api/utils"
+	"testing"
+)
+
+// Case is one row of a table-driven endpoint test. Setup prepares the data
+// in a new Env and returns the request to send. The response must have
+// Status and, when Code is set, be an error with that code.
+type Case struct {
+	Name   string
+	Setup  func(env *Env) Request
+	Status int
+	Code   utils.ErrorCode
+
+	// Configure adjusts the configuration of the case's Env
+	Configure func(*config.Config)
+
+	// Check inspects the response further
+	Check func(t *testing.T, env *Env, res *Response)
+}
+
+// Run runs each case as a subtest against its own Env
+func Run(t *testing.T, cases []Case) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var configure []func(*config.Config)
+			if tc.Configure != nil {
+				configure = append(configure, tc.Configure)
+			}
+			env := New(t, configure...)
+
+			res := env.Do(tc.Setup(env))
+			if tc.Code != "" {
+				res.AssertError(tc.Status, tc.Code)
+			} else {
+				res.AssertStatus(tc.Status)
+			}
+			if tc.Check != nil {
+				tc.Check(t, env, res)
+			}
+		})
+	}
+}
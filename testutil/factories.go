@@ -0,0 +1,138 @@
+package testutil
+
+import (
+	"context"
+	"ecommerce-api/models"
+	"ecommerce-api/services"
+	"ecommerce-api/utils"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultPassword is the password of users created without one
+const DefaultPassword = "Password123!"
+
+// UserAttrs describes a user to create. Email is generated when empty.
+type UserAttrs struct {
+	Email    string
+	Password string
+	Admin    bool
+	Verified bool
+}
+
+// User creates a user with a hashed password
+func (e *Env) User(attrs UserAttrs) *models.User {
+	e.t.Helper()
+	if attrs.Email == "" {
+		attrs.Email = fmt.Sprintf("user%d@example.com", e.next())
+	}
+	if attrs.Password == "" {
+		attrs.Password = DefaultPassword
+	}
+	hash, err := utils.HashPassword(attrs.Password)
+	if err != nil {
+		e.t.Fatalf("testutil: hash password: %v", err)
+	}
+
+	user := &models.User{Email: attrs.Email, Password: hash, IsAdmin: attrs.Admin}
+	if attrs.Verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := e.DB.Create(user).Error; err != nil {
+		e.t.Fatalf("testutil: create user: %v", err)
+	}
+	return user
+}
+
+// Admin creates a verified admin
+func (e *Env) Admin() *models.User {
+	e.t.Helper()
+	return e.User(UserAttrs{Admin: true, Verified: true})
+}
+
+// Customer creates a verified customer
+func (e *Env) Customer() *models.User {
+	e.t.Helper()
+	return e.User(UserAttrs{Verified: true})
+}
+
+// Product creates a product. Empty fields get a unique name, a price of 10
+// and a stock of 100.
+func (e *Env) Product(product models.Product) *models.Product {
+	e.t.Helper()
+	if product.Name == "" {
+		product.Name = fmt.Sprintf("Product %d", e.next())
+	}
+	if product.Price == 0 {
+		product.Price = 10
+	}
+	if product.Stock == 0 {
+		product.Stock = 100
+	}
+	if err := e.DB.Create(&product).Error; err != nil {
+		e.t.Fatalf("testutil: create product: %v", err)
+	}
+	return &product
+}
+
+// Item returns an order line for quantity of product
+func Item(product *models.Product, quantity int) services.OrderItemInput {
+	return services.OrderItemInput{ProductID: product.ID, Quantity: quantity}
+}
+
+// Order places an order for user through the order service, so the total is
+// computed and the stock reserved as for an order placed over the API
+func (e *Env) Order(user *models.User, items ...services.OrderItemInput) *models.Order {
+	e.t.Helper()
+	order, err := services.NewOrderService(e.Store).Place(context.Background(), user.ID, items, nil)
+	if err != nil {
+		e.t.Fatalf("testutil: place order: %v", err)
+	}
+	return order
+}
+
+// Token returns an access token for a new login session of user
+func (e *Env) Token(user *models.User) string {
+	e.t.Helper()
+	return e.SessionToken(user, false)
+}
+
+// SessionToken returns an access token for a new login session of user. mfa
+// marks the session as completed with a second factor, which admin routes
+// require when Auth.RequireAdminMFA is set.
+func (e *Env) SessionToken(user *models.User, mfa bool) string {
+	e.t.Helper()
+	session := &models.Session{UserID: user.ID, ExpiresAt: time.Now().Add(utils.RefreshTokenTTL), MFA: mfa}
+	if err := e.DB.Create(session).Error; err != nil {
+		e.t.Fatalf("testutil: create session: %v", err)
+	}
+	token, err := e.Tokens.GenerateJWT(user.ID, user.IsAdmin, session.ID, mfa)
+	if err != nil {
+		e.t.Fatalf("testutil: sign token: %v", err)
+	}
+	return token
+}
+
+// APIKey returns a new API key acting as user with the scopes. Send it in
+// the X-API-Key header.
+func (e *Env) APIKey(user *models.User, scopes ...string) string {
+	e.t.Helper()
+	key, prefix, hash, err := utils.GenerateAPIKey()
+	if err != nil {
+		e.t.Fatalf("testutil: generate API key: %v", err)
+	}
+	record := &models.APIKey{
+		Name:        fmt.Sprintf("key %d", e.next()),
+		Prefix:      prefix,
+		KeyHash:     hash,
+		Scopes:      strings.Join(scopes, " "),
+		UserID:      user.ID,
+		CreatedByID: user.ID,
+	}
+	if err := e.DB.Create(record).Error; err != nil {
+		e.t.Fatalf("testutil: create API key: %v", err)
+	}
+	return key
+}
@@ -0,0 +1,139 @@
+package testutil
+
+import (
+	"bytes"
+	"ecommerce-api/utils"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Request describes a call to the API. Body is sent as is when it is a
+// string or []byte and encoded as JSON otherwise. Token is sent as a Bearer
+// access token and APIKey in the X-API-Key header.
+type Request struct {
+	Method string
+	Path   string
+	Body   interface{}
+	Token  string
+	APIKey string
+	Header http.Header
+}
+
+// Response is the recorded answer to a Request
+type Response struct {
+	*httptest.ResponseRecorder
+	t testing.TB
+}
+
+// Do sends req through the router
+func (e *Env) Do(req Request) *Response {
+	e.t.Helper()
+
+	var body io.Reader
+	switch b := req.Body.(type) {
+	case nil:
+	case string:
+		body = bytes.NewBufferString(b)
+	case []byte:
+		body = bytes.NewBuffer(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			e.t.Fatalf("testutil: encode body: %v", err)
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	r := httptest.NewRequest(req.Method, req.Path, body)
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if req.Token != "" {
+		r.Header.Set("Authorization", "Bearer "+req.Token)
+	}
+	if req.APIKey != "" {
+		r.Header.Set("X-API-Key", req.APIKey)
+	}
+	for name, values := range req.Header {
+		r.Header[name] = values
+	}
+
+	w := httptest.NewRecorder()
+	e.Router.ServeHTTP(w, r)
+	return &Response{ResponseRecorder: w, t: e.t}
+}
+
+// GET sends a GET request, authenticated when token is not empty
+func (e *Env) GET(path, token string) *Response {
+	e.t.Helper()
+	return e.Do(Request{Method: http.MethodGet, Path: path, Token: token})
+}
+
+// POST sends body as JSON
+func (e *Env) POST(path, token string, body interface{}) *Response {
+	e.t.Helper()
+	return e.Do(Request{Method: http.MethodPost, Path: path, Token: token, Body: body})
+}
+
+// PUT sends body as JSON
+func (e *Env) PUT(path, token string, body interface{}) *Response {
+	e.t.Helper()
+	return e.Do(Request{Method: http.MethodPut, Path: path, Token: token, Body: body})
+}
+
+// DELETE sends a DELETE request
+func (e *Env) DELETE(path, token string) *Response {
+	e.t.Helper()
+	return e.Do(Request{Method: http.MethodDelete, Path: path, Token: token})
+}
+
+// Decode decodes the whole body into v
+func (r *Response) Decode(v interface{}) {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("testutil: decode response %q: %v", r.Body.String(), err)
+	}
+}
+
+// Data decodes the data field of a success response into v
+func (r *Response) Data(v interface{}) {
+	r.t.Helper()
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	r.Decode(&envelope)
+	if err := json.Unmarshal(envelope.Data, v); err != nil {
+		r.t.Fatalf("testutil: decode data %q: %v", envelope.Data, err)
+	}
+}
+
+// ErrorBody decodes an error response
+func (r *Response) ErrorBody() utils.ErrorResponse {
+	r.t.Helper()
+	var res utils.ErrorResponse
+	r.Decode(&res)
+	return res
+}
+
+// AssertStatus fails the test unless the response has the status code
+func (r *Response) AssertStatus(status int) *Response {
+	r.t.Helper()
+	if r.Code != status {
+		r.t.Fatalf("status = %d, want %d; body: %s", r.Code, status, r.Body.String())
+	}
+	return r
+}
+
+// AssertError fails the test unless the response is an error with the
+// status and code
+func (r *Response) AssertError(status int, code utils.ErrorCode) *Response {
+	r.t.Helper()
+	r.AssertStatus(status)
+	if got := r.ErrorBody().Code; got != code {
+		r.t.Fatalf("error code = %s, want %s; body: %s", got, code, r.Body.String())
+	}
+	return r
+}
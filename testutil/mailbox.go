@@ -0,0 +1,62 @@
+package testutil
+
+import (
+	"context"
+	"ecommerce-api/utils"
+	"net/url"
+	"regexp"
+	"sync"
+)
+
+// Mailbox is a utils.Mailer that keeps every message instead of sending it
+type Mailbox struct {
+	mu       sync.Mutex
+	messages []utils.Message
+}
+
+// Send implements utils.Mailer
+func (m *Mailbox) Send(_ context.Context, msg utils.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first
+func (m *Mailbox) Messages() []utils.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]utils.Message(nil), m.messages...)
+}
+
+// Last returns the latest message sent to address, and false if there is none
+func (m *Mailbox) Last(address string) (utils.Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == address {
+			return m.messages[i], true
+		}
+	}
+	return utils.Message{}, false
+}
+
+var tokenParam = regexp.MustCompile(`token=([^\s&"]+)`)
+
+// Token returns the token in the link of the latest message sent to address,
+// such as a verification or password reset token, or "" if there is none
+func (m *Mailbox) Token(address string) string {
+	msg, ok := m.Last(address)
+	if !ok {
+		return ""
+	}
+	match := tokenParam.FindStringSubmatch(msg.Body)
+	if match == nil {
+		return ""
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		return ""
+	}
+	return token
+}
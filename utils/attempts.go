@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// AttemptLimiter counts failures per key (e.g. client IP) in a fixed window
+// and blocks the key once the limit is reached, until the window expires.
+type AttemptLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	entries map[string]*attemptEntry
+}
+
+type attemptEntry struct {
+	failures int
+	resetAt  time.Time
+}
+
+// NewAttemptLimiter allows limit failures per key within window
+func NewAttemptLimiter(limit int, window time.Duration) *AttemptLimiter {
+	return &AttemptLimiter{limit: limit, window: window, entries: make(map[string]*attemptEntry)}
+}
+
+// Blocked reports whether the key has exhausted its failures and for how long
+func (l *AttemptLimiter) Blocked(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[key]
+	if !ok {
+		return false, 0
+	}
+	now := time.Now()
+	if now.After(entry.resetAt) {
+		delete(l.entries, key)
+		return false, 0
+	}
+	if entry.failures >= l.limit {
+		return true, entry.resetAt.Sub(now)
+	}
+	return false, 0
+}
+
+// Fail records a failure for the key
+func (l *AttemptLimiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := l.entries[key]
+	if !ok || now.After(entry.resetAt) {
+		entry = &attemptEntry{resetAt: now.Add(l.window)}
+		l.entries[key] = entry
+	}
+	entry.failures++
+
+	// Drop expired keys once the map grows
+	if len(l.entries) > 10000 {
+		for k, e := range l.entries {
+			if now.After(e.resetAt) {
+				delete(l.entries, k)
+			}
+		}
+	}
+}
+
+// Reset forgets the failures of a key
+func (l *AttemptLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorCode is a stable, machine-readable error identifier. Clients should
+// branch on the code rather than on the message, which may change.
+type ErrorCode string
+
+// Generic error codes, used when no more specific code applies
+const (
+	CodeBadRequest         ErrorCode = "BAD_REQUEST"
+	CodeValidationFailed   ErrorCode = "VALIDATION_FAILED"
+	CodeUnauthorized       ErrorCode = "UNAUTHORIZED"
+	CodeForbidden          ErrorCode = "FORBIDDEN"
+	CodeNotFound           ErrorCode = "NOT_FOUND"
+	CodeMethodNotAllowed   ErrorCode = "METHOD_NOT_ALLOWED"
+	CodeConflict           ErrorCode = "CONFLICT"
+	CodeTooManyRequests    ErrorCode = "RATE_LIMITED"
+	CodeInternal           ErrorCode = "INTERNAL_ERROR"
+	CodeBadGateway         ErrorCode = "BAD_GATEWAY"
+	CodeServiceUnavailable ErrorCode = "SERVICE_UNAVAILABLE"
+)
+
+// Authentication and authorisation error codes
+const (
+	CodeTokenMissing             ErrorCode = "TOKEN_MISSING"
+	CodeTokenInvalid             ErrorCode = "TOKEN_INVALID"
+	CodeTokenRevoked             ErrorCode = "TOKEN_REVOKED"
+	CodeInvalidCredentials       ErrorCode = "INVALID_CREDENTIALS"
+	CodeInvalidPassword          ErrorCode = "INVALID_PASSWORD"
+	CodeAccountLocked            ErrorCode = "ACCOUNT_LOCKED"
+	CodeReauthRequired           ErrorCode = "REAUTHENTICATION_REQUIRED"
+	CodeRefreshTokenInvalid      ErrorCode = "REFRESH_TOKEN_INVALID"
+	CodeRefreshTokenReused       ErrorCode = "REFRESH_TOKEN_REUSED"
+	CodeVerificationTokenInvalid ErrorCode = "VERIFICATION_TOKEN_INVALID"
+	CodeResetTokenInvalid        ErrorCode = "RESET_TOKEN_INVALID"
+	CodeEmailNotVerified         ErrorCode = "EMAIL_NOT_VERIFIED"
+	CodeEmailTaken               ErrorCode = "EMAIL_TAKEN"
+	CodeMFARequired              ErrorCode = "MFA_REQUIRED"
+	CodeMFATokenInvalid          ErrorCode = "MFA_TOKEN_INVALID"
+	CodeMFACodeInvalid           ErrorCode = "MFA_CODE_INVALID"
+	CodeMFAAlreadyEnabled        ErrorCode = "MFA_ALREADY_ENABLED"
+	CodeMFANotEnabled            ErrorCode = "MFA_NOT_ENABLED"
+	CodeMFANotEnrolled           ErrorCode = "MFA_NOT_ENROLLED"
+	CodeLoginProviderNotFound    ErrorCode = "LOGIN_PROVIDER_NOT_FOUND"
+	CodeLoginProviderUnavailable ErrorCode = "LOGIN_PROVIDER_UNAVAILABLE"
+	CodeLoginStateInvalid        ErrorCode = "LOGIN_STATE_INVALID"
+	CodeLoginRejected            ErrorCode = "LOGIN_REJECTED"
+	CodeAdminRequired            ErrorCode = "ADMIN_REQUIRED"
+	CodeAPIKeyInvalid            ErrorCode = "API_KEY_INVALID"
+	CodeAPIKeyNotAllowed         ErrorCode = "API_KEY_NOT_ALLOWED"
+	CodeInsufficientScope        ErrorCode = "INSUFFICIENT_SCOPE"
+)
+
+// Resource error codes
+const (
+	CodeUserNotFound         ErrorCode = "USER_NOT_FOUND"
+	CodeProductNotFound      ErrorCode = "PRODUCT_NOT_FOUND"
+	CodeProductNameTaken     ErrorCode = "PRODUCT_NAME_TAKEN"
+	CodeProductSKUTaken      ErrorCode = "PRODUCT_SKU_TAKEN"
+	CodeImportFileInvalid    ErrorCode = "IMPORT_FILE_INVALID"
+	CodeImportRowsInvalid    ErrorCode = "IMPORT_ROWS_INVALID"
+	CodeInsufficientStock    ErrorCode = "INSUFFICIENT_STOCK"
+	CodeOrderNotFound        ErrorCode = "ORDER_NOT_FOUND"
+	CodeOrderAlreadyCanceled ErrorCode = "ORDER_ALREADY_CANCELED"
+	CodeOrderStatusInvalid   ErrorCode = "ORDER_STATUS_INVALID"
+	CodeOrderStatusConflict  ErrorCode = "ORDER_STATUS_CONFLICT"
+	CodeOrderTotalMismatch   ErrorCode = "ORDER_TOTAL_MISMATCH"
+	CodeSessionNotFound      ErrorCode = "SESSION_NOT_FOUND"
+	CodeAPIKeyNotFound       ErrorCode = "API_KEY_NOT_FOUND"
+	CodeScopeUnknown         ErrorCode = "SCOPE_UNKNOWN"
+)
+
+// problemTypeKey holds the problem type base URL of a request in its gin context
+const problemTypeKey = "problem_type_base_url"
+
+// SetProblemTypeBaseURL makes the error responses of a request turn error codes
+// into RFC 7807 type URIs under baseURL (e.g.
+// https://example.com/problems/product-not-found). Without one, problem
+// responses use "about:blank".
+func SetProblemTypeBaseURL(c *gin.Context, baseURL string) {
+	c.Set(problemTypeKey, baseURL)
+}
+
+// APIError is the error returned by every endpoint
+type APIError struct {
+	Status  int
+	Code    ErrorCode
+	Message string
+	Fields  map[string]string // per-field validation messages
+}
+
+// NewAPIError creates an APIError
+func NewAPIError(status int, code ErrorCode, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+func (e *APIError) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+// RespondError sends an error response
+func RespondError(c *gin.Context, statusCode int, code ErrorCode, message string) {
+	WriteError(c, NewAPIError(statusCode, code, message))
+}
+
+// AbortWithError sends an error response and stops the handler chain
+func AbortWithError(c *gin.Context, statusCode int, code ErrorCode, message string) {
+	WriteError(c, NewAPIError(statusCode, code, message))
+	c.Abort()
+}
+
+// WriteError sends err as {status, code, message, errors}, or as an RFC 7807
+// problem document when the client accepts application/problem+json
+func WriteError(c *gin.Context, err *APIError) {
+	if wantsProblem(c) {
+		problem := ProblemDetails{
+			Type:     problemType(c.GetString(problemTypeKey), err.Code),
+			Title:    http.StatusText(err.Status),
+			Status:   err.Status,
+			Detail:   err.Message,
+			Instance: c.Request.URL.Path,
+			Code:     err.Code,
+			Errors:   err.Fields,
+		}
+		c.Header("Content-Type", "application/problem+json")
+		c.JSON(err.Status, problem)
+		return
+	}
+
+	c.JSON(err.Status, ErrorResponse{
+		Status:  "error",
+		Code:    err.Code,
+		Message: err.Message,
+		Errors:  err.Fields,
+	})
+}
+
+func wantsProblem(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), "application/problem+json")
+}
+
+func problemType(baseURL string, code ErrorCode) string {
+	if baseURL == "" {
+		return "about:blank"
+	}
+	slug := strings.ReplaceAll(strings.ToLower(string(code)), "_", "-")
+	return strings.TrimSuffix(baseURL, "/") + "/" + slug
+}
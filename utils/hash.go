@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"sync"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -14,3 +16,18 @@ func HashPassword(password string) (string, error) {
 func VerifyPassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// VerifyPasswordDummy performs a bcrypt comparison against a fixed hash so a
+// login for an unknown email takes as long as one for a known email
+func VerifyPasswordDummy(password string) {
+	dummyHashOnce.Do(func() {
+		hash, _ := bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+		dummyHash = string(hash)
+	})
+	_ = bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HealthCheck reports whether a subsystem the API depends on is usable
+type HealthCheck func(ctx context.Context) error
+
+// HealthResult is the outcome of one check
+type HealthResult struct {
+	Status     string  `json:"status"` // "ok" or "failing"
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// HealthRegistry holds the named checks that make up readiness
+type HealthRegistry struct {
+	mu       sync.RWMutex
+	checks   map[string]HealthCheck
+	draining atomic.Bool
+}
+
+// NewHealthRegistry creates an empty registry
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{checks: make(map[string]HealthCheck)}
+}
+
+// Register adds a check, replacing any check of the same name
+func (r *HealthRegistry) Register(name string, check HealthCheck) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// SetDraining makes readiness fail while the server shuts down, so load
+// balancers stop sending it new requests
+func (r *HealthRegistry) SetDraining(draining bool) {
+	r.draining.Store(draining)
+}
+
+// Check runs every check concurrently, each bounded by timeout, and reports
+// whether all of them passed
+func (r *HealthRegistry) Check(ctx context.Context, timeout time.Duration) (bool, map[string]HealthResult) {
+	r.mu.RLock()
+	checks := make(map[string]HealthCheck, len(r.checks))
+	for name, check := range r.checks {
+		checks[name] = check
+	}
+	r.mu.RUnlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	healthy := true
+	results := make(map[string]HealthResult, len(checks))
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+			result := runHealthCheck(ctx, check, timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = result
+			if result.Status != "ok" {
+				healthy = false
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	if r.draining.Load() {
+		healthy = false
+		results["shutdown"] = HealthResult{Status: "failing", Error: "server is shutting down"}
+	}
+	return healthy, results
+}
+
+// runHealthCheck gives up on a check that ignores its context once the
+// timeout has passed
+func runHealthCheck(ctx context.Context, check HealthCheck, timeout time.Duration) HealthResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				done <- fmt.Errorf("check panicked: %v", recovered)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := HealthResult{Status: "ok", DurationMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = "failing"
+		result.Error = err.Error()
+	}
+	return result
+}
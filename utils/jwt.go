@@ -1,49 +1,293 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"ecommerce-api/config"
 	"errors"
+	"fmt"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var jwtSecret = []byte("your_secret_key") // Replace with your actual secret key
+// Token lifetimes. Access tokens are short-lived; clients renew them with the
+// rotating refresh token issued alongside.
+var (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
 
 // Claims structure for JWT
 type Claims struct {
-	UserID  uint `json:"user_id"`
-	IsAdmin bool `json:"is_admin"`
+	UserID    uint `json:"user_id"`
+	IsAdmin   bool `json:"is_admin"`
+	SessionID uint `json:"sid,omitempty"`
+	MFA       bool `json:"mfa,omitempty"`
+
+	// Purpose marks single-purpose tokens (e.g. email verification) that must
+	// never be accepted as access tokens
+	Purpose string `json:"purpose,omitempty"`
+	Email   string `json:"email,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT generates a JWT for the user
-func GenerateJWT(userID uint, isAdmin bool) (string, error) {
-	claims := &jwt.MapClaims{
-		"user_id":  userID,
-		"is_admin": isAdmin,
-		"exp":      time.Now().Add(time.Hour * 72).Unix(),
+// Token purposes
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposeMFAChallenge      = "mfa_challenge"
+)
+
+// TokenConfig configures the token service
+type TokenConfig struct {
+	Issuer   string
+	Audience string
+
+	// Secret enables HS256. It signs tokens when no private key is configured,
+	// and otherwise keeps verifying tokens that carry no kid.
+	Secret string
+
+	// PrivateKeyFile is a PEM encoded RSA, ECDSA or Ed25519 private key used
+	// for signing. KeyID defaults to the RFC 7638 thumbprint of the key.
+	PrivateKeyFile string
+	KeyID          string
+
+	// VerificationKeys maps a kid to a PEM file with a public (or private)
+	// key that is still accepted, e.g. the previous key during a rotation.
+	VerificationKeys map[string]string
+}
+
+// tokenKey is a key the service can verify with, and sign with if signer is set
+type tokenKey struct {
+	id     string
+	method jwt.SigningMethod
+	verify interface{}
+	signer interface{}
+	public crypto.PublicKey // nil for symmetric keys
+}
+
+// TokenService signs and verifies every JWT issued by the API
+type TokenService struct {
+	issuer   string
+	audience string
+	signing  *tokenKey
+	keys     map[string]*tokenKey
+	hmac     *tokenKey
+}
+
+// LoadTokenService builds the token service from the configuration
+func LoadTokenService(cfg config.JWTConfig) (*TokenService, error) {
+	return NewTokenService(TokenConfig{
+		Issuer:           cfg.Issuer,
+		Audience:         cfg.Audience,
+		Secret:           cfg.Secret,
+		PrivateKeyFile:   cfg.PrivateKeyFile,
+		KeyID:            cfg.KeyID,
+		VerificationKeys: cfg.VerificationKeys,
+	})
+}
+
+// NewTokenService loads the configured signing and verification keys
+func NewTokenService(cfg TokenConfig) (*TokenService, error) {
+	s := &TokenService{issuer: cfg.Issuer, audience: cfg.Audience, keys: map[string]*tokenKey{}}
+
+	if cfg.Secret != "" {
+		s.hmac = &tokenKey{method: jwt.SigningMethodHS256, verify: []byte(cfg.Secret), signer: []byte(cfg.Secret)}
+	}
+
+	if cfg.PrivateKeyFile != "" {
+		key, err := loadTokenKey(cfg.KeyID, cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("signing key: %w", err)
+		}
+		if key.signer == nil {
+			return nil, fmt.Errorf("signing key: %s does not contain a private key", cfg.PrivateKeyFile)
+		}
+		s.signing = key
+		s.keys[key.id] = key
+	} else if s.hmac != nil {
+		s.signing = s.hmac
+	} else {
+		return nil, errors.New("either JWT_SECRET or JWT_PRIVATE_KEY_FILE must be set")
+	}
+
+	for kid, path := range cfg.VerificationKeys {
+		key, err := loadTokenKey(kid, path)
+		if err != nil {
+			return nil, fmt.Errorf("verification key %q: %w", kid, err)
+		}
+		if _, exists := s.keys[key.id]; !exists {
+			key.signer = nil
+			s.keys[key.id] = key
+		}
+	}
+
+	return s, nil
+}
+
+// loadTokenKey reads an asymmetric key from a PEM file and picks its algorithm
+func loadTokenKey(kid, path string) (*tokenKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	public, signer, err := ParsePEMKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &tokenKey{public: public, verify: public}
+	if signer != nil {
+		key.signer = signer
+	}
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			key.method = jwt.SigningMethodES256
+		case 384:
+			key.method = jwt.SigningMethodES384
+		default:
+			key.method = jwt.SigningMethodES512
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+
+	key.id = kid
+	if key.id == "" {
+		jwk, err := NewJWK("", key.method.Alg(), public)
+		if err != nil {
+			return nil, err
+		}
+		key.id = jwk.Thumbprint()
 	}
-	key := os.Getenv("JWT_SECRET")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	secret := []byte(key) // Same key used in middleware
-	return token.SignedString(secret)
+	return key, nil
 }
 
-// VerifyJWT verifies the token and returns the claims
-func VerifyJWT(tokenString string) (*Claims, error) {
+// Sign fills in the issuer, audience, issue time and token ID and signs the claims
+func (s *TokenService) Sign(claims *Claims, ttl time.Duration) (string, error) {
+	jti, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.Issuer = s.issuer
+	claims.Audience = jwt.ClaimStrings{s.audience}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	claims.ID = jti
+
+	token := jwt.NewWithClaims(s.signing.method, claims)
+	if s.signing.id != "" {
+		token.Header["kid"] = s.signing.id
+	}
+	return token.SignedString(s.signing.signer)
+}
+
+// Parse verifies the signature, expiry, issuer and audience of a token
+func (s *TokenService) Parse(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
+		key, err := s.keyFor(token)
+		if err != nil {
+			return nil, err
+		}
+		// Only accept the algorithm the key was configured for
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.verify, nil
 	})
-
 	if err != nil {
 		return nil, err
 	}
 
 	claims, ok := token.Claims.(*Claims)
 	if !ok || !token.Valid {
-		return nil, errors.New("invalid token2 ")
+		return nil, errors.New("invalid token")
+	}
+	if !claims.VerifyIssuer(s.issuer, true) {
+		return nil, errors.New("invalid token issuer")
+	}
+	if !claims.VerifyAudience(s.audience, true) {
+		return nil, errors.New("invalid token audience")
+	}
+	return claims, nil
+}
+
+// keyFor selects the verification key named by the token's kid header
+func (s *TokenService) keyFor(token *jwt.Token) (*tokenKey, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if s.hmac == nil {
+			return nil, errors.New("token has no kid")
+		}
+		return s.hmac, nil
+	}
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	return key, nil
+}
+
+// JWKS returns the public keys clients can use to verify our tokens.
+// Symmetric keys are never published.
+func (s *TokenService) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range s.keys {
+		if key.public == nil {
+			continue
+		}
+		jwk, err := NewJWK(key.id, key.method.Alg(), key.public)
+		if err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
 	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
 
+// GenerateJWT generates a short-lived access token bound to a login session.
+// mfa records whether the session was established with a second factor.
+func (s *TokenService) GenerateJWT(userID uint, isAdmin bool, sessionID uint, mfa bool) (string, error) {
+	return s.Sign(&Claims{UserID: userID, IsAdmin: isAdmin, SessionID: sessionID, MFA: mfa}, AccessTokenTTL)
+}
+
+// VerifyJWT verifies an access token and returns the claims
+func (s *TokenService) VerifyJWT(tokenString string) (*Claims, error) {
+	claims, err := s.Parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("not an access token")
+	}
+	return claims, nil
+}
+
+// GeneratePurposeToken signs a token that is only valid for the given purpose
+func (s *TokenService) GeneratePurposeToken(purpose string, userID uint, email string, ttl time.Duration) (string, error) {
+	return s.Sign(&Claims{UserID: userID, Purpose: purpose, Email: email}, ttl)
+}
+
+// VerifyPurposeToken verifies a token issued by GeneratePurposeToken for the given purpose
+func (s *TokenService) VerifyPurposeToken(tokenString, purpose string) (*Claims, error) {
+	claims, err := s.Parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose {
+		return nil, errors.New("invalid token purpose")
+	}
 	return claims, nil
 }
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"ecommerce-api/config"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type loggerKey struct{}
+
+// LogLevel is the minimum level of the default logger; it can be changed at runtime
+var LogLevel = new(slog.LevelVar)
+
+// InitLogger configures the default slog logger
+func InitLogger(cfg config.LogConfig) error {
+	if err := LogLevel.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+
+	opts := &slog.HandlerOptions{Level: LogLevel}
+	var handler slog.Handler
+	switch format := strings.ToLower(cfg.Format); format {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	case "text":
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// WithLogger returns a context carrying a request-scoped logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger of the request ctx belongs to, or the default logger
+func Logger(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// NewRequestID generates a random request ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
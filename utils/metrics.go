@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics is the Prometheus registry exposed at /metrics
+var Metrics = prometheus.NewRegistry()
+
+// HTTP metrics, recorded by middlewares.Metrics
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ecommerce",
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "ecommerce",
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Business metrics
+var (
+	OrdersCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ecommerce",
+		Name:      "orders_created_total",
+		Help:      "Orders placed.",
+	})
+
+	OrdersCanceled = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ecommerce",
+		Name:      "orders_canceled_total",
+		Help:      "Orders canceled by customers or admins.",
+	})
+
+	Revenue = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ecommerce",
+		Name:      "revenue_total",
+		Help:      "Gross sum of the totals of placed orders, including orders canceled later.",
+	})
+
+	RevenueCanceled = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ecommerce",
+		Name:      "revenue_canceled_total",
+		Help:      "Sum of the totals of canceled orders; net revenue is revenue_total minus this.",
+	})
+
+	LoginFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ecommerce",
+		Name:      "login_failures_total",
+		Help:      "Failed login attempts (password or two-factor code).",
+	})
+)
+
+func init() {
+	Metrics.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPRequestDuration,
+		OrdersCreated, OrdersCanceled, Revenue, RevenueCanceled, LoginFailures,
+	)
+}
+
+// RegisterDBStats exposes the connection pool statistics of db
+func RegisterDBStats(db *sql.DB, name string) error {
+	return Metrics.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// MetricsHandler serves the registry in the Prometheus text format. When
+// token is set, scrapers must send it as a Bearer token.
+func MetricsHandler(token string) http.Handler {
+	handler := promhttp.HandlerFor(Metrics, promhttp.HandlerOpts{})
+	if token == "" {
+		return handler
+	}
+
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
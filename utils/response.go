@@ -15,30 +15,32 @@ func RespondSuccess(c *gin.Context, message string, data interface{}) {
 	})
 }
 
-// RespondError sends an error response
-func RespondError(c *gin.Context, statusCode int, message string) {
-	c.JSON(statusCode, gin.H{
-		"status":  "error",
-		"message": message,
-	})
-}
-
-// RespondValidationError sends a validation error response
-func RespondValidationError(c *gin.Context, errors map[string]string) {
-	c.JSON(http.StatusBadRequest, gin.H{
-		"status":  "error",
-		"message": "Validation failed",
-		"errors":  errors,
-	})
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Status  string            `json:"status"`           // always "error"
+	Code    ErrorCode         `json:"code"`             // stable machine-readable code
+	Message string            `json:"message"`          // human-readable message
+	Errors  map[string]string `json:"errors,omitempty"` // per-field validation messages
 }
 
-type ErrorResponse struct {
-	Message string `json:"message"` // Message field to hold the error message
+// ProblemDetails is the RFC 7807 form of an error, sent when the client
+// accepts application/problem+json
+type ProblemDetails struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail"`
+	Instance string            `json:"instance,omitempty"`
+	Code     ErrorCode         `json:"code"`
+	Errors   map[string]string `json:"errors,omitempty"`
 }
 type SuccessResponse struct {
 	Message string `json:"message"` // Message field to hold the error message
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
 }
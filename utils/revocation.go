@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// SessionRevocationCache remembers whether a session has been revoked so the
+// auth middleware does not hit the database on every request. Entries expire
+// after ttl, which bounds how long another instance may keep accepting a
+// session revoked elsewhere.
+type SessionRevocationCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[uint]revocationEntry
+}
+
+type revocationEntry struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+// NewSessionRevocationCache creates an empty cache
+func NewSessionRevocationCache(ttl time.Duration) *SessionRevocationCache {
+	return &SessionRevocationCache{ttl: ttl, entries: make(map[uint]revocationEntry)}
+}
+
+// Get returns the cached state of a session and whether it was found
+func (c *SessionRevocationCache) Get(sessionID uint) (revoked bool, ok bool) {
+	c.mu.RLock()
+	entry, found := c.entries[sessionID]
+	c.mu.RUnlock()
+	if !found || time.Now().After(entry.expiresAt) {
+		return false, false
+	}
+	return entry.revoked, true
+}
+
+// Set records the state of a session. Revocations are permanent, so they are
+// kept for a day rather than the cache ttl; a session revoked that long ago is
+// found revoked in the database again.
+func (c *SessionRevocationCache) Set(sessionID uint, revoked bool) {
+	ttl := c.ttl
+	if revoked {
+		ttl = 24 * time.Hour
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[sessionID] = revocationEntry{revoked: revoked, expiresAt: time.Now().Add(ttl)}
+	c.evictExpired()
+}
+
+// evictExpired drops stale entries; callers must hold the write lock
+func (c *SessionRevocationCache) evictExpired() {
+	if len(c.entries) < 1024 {
+		return
+	}
+	now := time.Now()
+	for id, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, id)
+		}
+	}
+}
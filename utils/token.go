@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// GenerateOpaqueToken returns a random URL-safe token and its SHA-256 hash.
+// The plain token is handed to the client, only the hash is persisted.
+func GenerateOpaqueToken() (token string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken hashes an opaque token for storage and lookup
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// API keys look like "ek_<12 hex prefix>_<secret>". The prefix is stored in
+// clear text for lookup, the whole key only as a hash.
+const (
+	apiKeyMarker    = "ek_"
+	apiKeyPrefixLen = 12
+)
+
+// GenerateAPIKey returns a new API key, its lookup prefix and its hash
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	buf := make([]byte, apiKeyPrefixLen/2)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	prefix = hex.EncodeToString(buf)
+
+	secret, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	key = apiKeyMarker + prefix + "_" + secret
+	return key, prefix, HashToken(key), nil
+}
+
+// APIKeyPrefix extracts the lookup prefix from an API key
+func APIKeyPrefix(key string) (string, bool) {
+	if !strings.HasPrefix(key, apiKeyMarker) || len(key) < len(apiKeyMarker)+apiKeyPrefixLen+2 {
+		return "", false
+	}
+	rest := key[len(apiKeyMarker):]
+	if rest[apiKeyPrefixLen] != '_' {
+		return "", false
+	}
+	return rest[:apiKeyPrefixLen], true
+}
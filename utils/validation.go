@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Report fields by their JSON name rather than the Go field name
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// NormalizeEmail returns the form in which email addresses are stored and
+// looked up. Addresses are compared case-insensitively, as users expect.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// RespondValidationError sends a 400 response for a failed ShouldBind call,
+// with a message per invalid field
+func RespondValidationError(c *gin.Context, err error) {
+	WriteError(c, ValidationError(err))
+}
+
+// ValidationError translates binding errors into an APIError
+func ValidationError(err error) *APIError {
+	apiErr := NewAPIError(http.StatusBadRequest, CodeValidationFailed, "Invalid request data")
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &validationErrs):
+		apiErr.Fields = make(map[string]string, len(validationErrs))
+		for _, fe := range validationErrs {
+			apiErr.Fields[fieldPath(fe)] = fieldMessage(fe)
+		}
+	case errors.As(err, &typeErr):
+		apiErr.Fields = map[string]string{typeErr.Field: "must be a " + jsonTypeName(typeErr.Type)}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		apiErr.Message = "Request body is not valid JSON"
+	}
+	return apiErr
+}
+
+// fieldPath returns the JSON path of a field, e.g. items[0].product_id. The
+// namespace starts with the type name for named structs (models.Product) but
+// not for the anonymous input structs used by most handlers.
+func fieldPath(fe validator.FieldError) string {
+	path := fe.Namespace()
+	if i := strings.Index(path, "."); i > 0 {
+		if first := path[:i]; !strings.Contains(first, "[") && unicode.IsUpper(rune(first[0])) {
+			path = path[i+1:]
+		}
+	}
+	return path
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	isString := fe.Kind() == reflect.String
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "e164":
+		return "must be a phone number in E.164 format, e.g. +14155552671"
+	case "bcp47_language_tag":
+		return "must be a BCP 47 language tag, e.g. en-GB"
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "min":
+		if isString {
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		}
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("must contain at least %s items", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if isString {
+			return fmt.Sprintf("must be at most %s characters long", fe.Param())
+		}
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("must contain at most %s items", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "len":
+		if isString {
+			return fmt.Sprintf("must be exactly %s characters long", fe.Param())
+		}
+		return "must have length " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be greater than or equal to " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "lte":
+		return "must be less than or equal to " + fe.Param()
+	case "numeric", "number":
+		return "must be a number"
+	default:
+		return "is invalid"
+	}
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "whole number"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	default:
+		return "object"
+	}
+}